@@ -2,46 +2,132 @@ package main
 
 import (
 	"context"
+	"fmt"
+	"log"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 
+	"github.com/alukart32/tmp-weather/internal/pkg/db/migrate"
 	"github.com/alukart32/tmp-weather/internal/pkg/db/postgres"
+	"github.com/alukart32/tmp-weather/internal/pkg/otelx"
 	"github.com/alukart32/tmp-weather/internal/pkg/zerologx"
+	"github.com/alukart32/tmp-weather/internal/tmpweather/archive"
+	"github.com/alukart32/tmp-weather/internal/tmpweather/collector"
+	"github.com/alukart32/tmp-weather/internal/tmpweather/config"
 	"github.com/alukart32/tmp-weather/internal/tmpweather/storage"
 	"github.com/alukart32/tmp-weather/internal/tmpweather/telegram"
 	"github.com/alukart32/tmp-weather/internal/tmpweather/weather"
 )
 
+const usage = `Usage:
+  tmpweather                        run the telegram bot
+  tmpweather migrate up             apply all migrations
+  tmpweather migrate status         print the migration version
+  tmpweather migrate down [N]       roll back N migrations, 1 by default
+  tmpweather migrate force VERSION  set the migration version without migrating`
+
 func main() {
-	logger := zerologx.Get()
+	os.Exit(run(os.Args[1:]))
+}
+
+// run runs the command and returns the exit code.
+func run(args []string) int {
+	if len(args) == 0 {
+		return runBot()
+	}
+
+	switch args[0] {
+	case "migrate":
+		return runMigrate(args[1:])
+	case "help", "-h", "-help", "--help":
+		fmt.Println(usage)
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n%s\n", args[0], usage)
+		return 2
+	}
+}
+
+// runBot runs the telegram bot and returns the exit code.
+func runBot() int {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Printf("load config: %v", err)
+		return 1
+	}
+	logger := zerologx.Init(cfg.Log)
 
 	appCtx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	logger.Info().Msg("prepare postgres pool")
-	pgxPool, err := postgres.Get()
+	logger.Info().Msg("prepare tracing")
+	shutdownTracing, err := otelx.Setup(appCtx, "tmpweather", cfg.Tracing)
 	if err != nil {
-		logger.Panic().Err(err).Msg("prepare postgres pool")
+		logger.Error().Err(err).Msg("prepare tracing")
+		return 1
 	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			logger.Error().Err(err).Msg("shutdown tracing")
+		}
+	}()
 
-	logger.Info().Msg("prepare forecast repo")
-	forecastRepo, err := storage.NewWeatherForecastRepo(pgxPool)
+	logger.Info().Str("driver", cfg.Storage.Driver).Msg("prepare forecast repo")
+	forecastRepo, closeRepo, err := openRepository(cfg)
 	if err != nil {
-		logger.Panic().Err(err).Msg("prepare forecast repo")
+		logger.Error().Err(err).Msg("prepare forecast repo")
+		return 1
 	}
+	defer closeRepo()
+	forecastWriter := storage.NewBatchWriter(forecastRepo, cfg.Storage)
 
 	logger.Info().Msg("prepare forecaster")
-	forecaster := weather.NewCityForecaster(appCtx)
+	forecaster := weather.NewCityForecaster(appCtx, cfg.Weather)
 
 	logger.Info().Msg("prepare telegram bot msgs handler")
 	msgsHandler, err := telegram.NewMsgHandler(
+		cfg.Telegram,
 		forecaster,
+		archive.NewClient(cfg.Archive),
+		forecastWriter,
+		forecastRepo,
+		forecastRepo,
 		forecastRepo,
-		false,
 	)
 	if err != nil {
-		logger.Panic().Err(err).Msg("prepare telegram bot msgs handler")
+		logger.Error().Err(err).Msg("prepare telegram bot msgs handler")
+		return 1
+	}
+
+	var jobs sync.WaitGroup
+	if pgRepo, ok := forecastRepo.(*storage.WeatherForecastRepo); ok && cfg.Storage.Retention.Age > 0 {
+		logger.Info().Msg("start retention job")
+		retention, err := storage.NewRetentionJob(pgRepo, cfg.Storage.Retention)
+		if err != nil {
+			logger.Error().Err(err).Msg("start retention job")
+			return 1
+		}
+		jobs.Add(1)
+		go func() {
+			defer jobs.Done()
+			retention.Run(appCtx)
+		}()
+	}
+
+	if len(cfg.Collector.Cities) != 0 {
+		logger.Info().Strs("cities", cfg.Collector.Cities).Msg("start observations collector")
+		obsCollector, err := collector.New(&forecaster, forecastRepo, cfg.Collector)
+		if err != nil {
+			logger.Error().Err(err).Msg("start observations collector")
+			return 1
+		}
+		jobs.Add(1)
+		go func() {
+			defer jobs.Done()
+			obsCollector.Run(appCtx)
+		}()
 	}
 
 	logger.Info().Msg("start telegram bot msgs handler")
@@ -52,4 +138,74 @@ func main() {
 	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
 	s := <-interrupt
 	logger.Info().Msg(s.String())
+
+	// Shutdown in order: stop receiving updates and drain in-flight commands,
+	// flush the queued forecasts, then stop the forecaster and jobs.
+	// The forecast repo and tracing are closed by the defers above.
+	code := 0
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancelShutdown()
+
+	logger.Info().Msg("shutdown telegram bot msgs handler")
+	if err := msgsHandler.Shutdown(shutdownCtx); err != nil {
+		logger.Error().Err(err).Msg("shutdown telegram bot msgs handler")
+		code = 1
+	}
+
+	// The flush has its own deadline, so a slow drain does not drop the queued forecasts.
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), cfg.FlushTimeout)
+	defer cancelFlush()
+
+	logger.Info().Msg("flush forecast writer")
+	if err := forecastWriter.Close(flushCtx); err != nil {
+		logger.Error().Err(err).Msg("flush forecast writer")
+		code = 1
+	}
+
+	logger.Info().Msg("shutdown forecaster and jobs")
+	cancel()
+	jobs.Wait()
+
+	return code
+}
+
+// repository stores forecasts, observations and chat preferences.
+type repository interface {
+	storage.BatchRepository
+	storage.ObservationRepository
+	storage.ChatRepository
+}
+
+// openRepository opens the forecast repository of the storage driver.
+// The returned func closes the repository.
+func openRepository(cfg config.Config) (repository, func(), error) {
+	switch cfg.Storage.Driver {
+	case storage.DriverMemory:
+		return storage.NewMemoryRepo(), func() {}, nil
+	case storage.DriverSQLite:
+		repo, err := storage.NewSQLiteRepo(cfg.Storage.SQLitePath)
+		if err != nil {
+			return nil, nil, err
+		}
+		return repo, func() { repo.Close() }, nil
+	case storage.DriverPostgres:
+		pgxPool, err := postgres.Get(cfg.Postgres)
+		if err != nil {
+			return nil, nil, fmt.Errorf("prepare postgres pool: %w", err)
+		}
+
+		if err := migrate.Up(cfg.Postgres.URI, storage.Migrations()); err != nil {
+			pgxPool.Close()
+			return nil, nil, fmt.Errorf("migrate postgres: %w", err)
+		}
+
+		repo, err := storage.NewWeatherForecastRepo(pgxPool)
+		if err != nil {
+			pgxPool.Close()
+			return nil, nil, err
+		}
+		return repo, pgxPool.Close, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown storage driver %q", cfg.Storage.Driver)
+	}
 }
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/alukart32/tmp-weather/internal/pkg/db/migrate"
+	"github.com/alukart32/tmp-weather/internal/tmpweather/config"
+	"github.com/alukart32/tmp-weather/internal/tmpweather/storage"
+)
+
+// runMigrate runs the migrate subcommand and returns the exit code.
+func runMigrate(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, usage)
+		return 2
+	}
+
+	cfg, err := config.Parse()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "load config: %v\n", err)
+		return 1
+	}
+	if len(cfg.Postgres.URI) == 0 {
+		fmt.Fprintln(os.Stderr, "load config: postgres.uri (POSTGRES_URI): is required")
+		return 1
+	}
+
+	m, err := migrate.New(cfg.Postgres.URI, storage.Migrations())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer m.Close()
+
+	switch cmd, params := args[0], args[1:]; {
+	case cmd == "up" && len(params) == 0:
+		err = m.Up()
+	case cmd == "status" && len(params) == 0:
+		var (
+			version uint
+			dirty   bool
+		)
+		version, dirty, err = m.Status()
+		if errors.Is(err, migrate.ErrNilVersion) {
+			fmt.Println("version: none")
+			return 0
+		}
+		if err == nil {
+			fmt.Printf("version: %d, dirty: %t\n", version, dirty)
+		}
+	case cmd == "down" && len(params) <= 1:
+		steps := 1
+		if len(params) == 1 {
+			if steps, err = strconv.Atoi(params[0]); err != nil {
+				fmt.Fprintf(os.Stderr, "invalid steps number %q\n", params[0])
+				return 2
+			}
+		}
+		err = m.Down(steps)
+	case cmd == "force" && len(params) == 1:
+		var version int
+		if version, err = strconv.Atoi(params[0]); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid version %q\n", params[0])
+			return 2
+		}
+		err = m.Force(version)
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		return 2
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
@@ -1,84 +1,127 @@
 // Package migrate provides postgres db migration.
 //
-// Migration engine is a golang-migrate project. Sql files are in /migrations directory.
-// If the filepath is empty, the nearest migrations directory will be selected.
+// Migration engine is a golang-migrate project. Sql files are read from the fs.FS,
+// e.g. the embedded migrations of the repository. Every migration runs under
+// a postgres advisory lock, so only one App replica migrates the db at a time.
 package migrate
 
 import (
 	"errors"
 	"fmt"
+	"io/fs"
 	"log"
 	"net/url"
 	"time"
 
 	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
 )
 
 const (
 	_defaultAttempts = 5
 	_defaultTimeout  = time.Second
-	_driverName      = "postgres"
-	_migrations      = "migrations"
 )
 
-// Up starts db migrations. If the filepath is empty, the nearest migrations folder will be selected.
-func Up(uri string, filepath string) (err error) {
-	defer func() {
-		if err != nil {
-			err = fmt.Errorf("migrate up: %w", err)
-		}
-	}()
+// ErrNilVersion is returned by Status if no migration has been applied.
+var ErrNilVersion = migrate.ErrNilVersion
+
+// Migrator migrates the db schema.
+type Migrator struct {
+	m *migrate.Migrate
+}
+
+// New returns a new Migrator of the db with the sql files of fsys.
+func New(uri string, fsys fs.FS) (*Migrator, error) {
 	if len(uri) == 0 {
-		return fmt.Errorf("empty uri")
+		return nil, fmt.Errorf("empty uri")
 	}
-	if len(filepath) == 0 {
-		filepath = _migrations
+
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("invalid uri: %q", uri)
+	}
+	queryValues := u.Query()
+	if !queryValues.Has("sslmode") {
+		queryValues.Set("sslmode", "disable")
 	}
+	u.RawQuery = queryValues.Encode()
 
-	errCh := make(chan error)
-	go func() {
-		var (
-			attempts = _defaultAttempts
-			path     = filepath
-			err      error
-			m        *migrate.Migrate
-		)
-
-		u, err := url.Parse(uri)
-		if err != nil {
-			errCh <- fmt.Errorf("invalid uri: %q", uri)
-			return
-		}
-		queryValues := u.Query()
-		if !queryValues.Has("sslmode") {
-			queryValues.Set("sslmode", "disable")
+	src, err := iofs.New(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("read migrations: %w", err)
+	}
+
+	var (
+		attempts = _defaultAttempts
+		m        *migrate.Migrate
+	)
+	for attempts > 0 {
+		m, err = migrate.NewWithSourceInstance("iofs", src, u.String())
+		if err == nil {
+			break
 		}
-		u.RawQuery = queryValues.Encode()
 
-		for attempts > 0 {
-			m, err = migrate.New("file://"+path, u.String())
-			if err == nil {
-				break
-			}
+		log.Printf("migrate: trying to connect, attempts left: %d", attempts)
+		time.Sleep(_defaultTimeout)
+		attempts--
+	}
+	if m == nil {
+		return nil, fmt.Errorf("unable to create migration: %w", err)
+	}
 
-			log.Printf("migrate: trying to connect, attempts left: %d", attempts)
-			time.Sleep(_defaultTimeout)
-			attempts--
-		}
-		if m == nil {
-			errCh <- fmt.Errorf("unable to create migration")
-			return
-		}
+	return &Migrator{m: m}, nil
+}
 
-		err = m.Up()
-		defer m.Close()
-		if err != nil && errors.Is(err, migrate.ErrNoChange) {
-			err = nil
-		}
-		errCh <- err
-	}()
+// Up applies all up migrations.
+func (m *Migrator) Up() error {
+	if err := m.m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("migrate up: %w", err)
+	}
+	return nil
+}
+
+// Down rolls back n migrations.
+func (m *Migrator) Down(n int) error {
+	if n <= 0 {
+		return fmt.Errorf("migrate down: invalid steps number: %d", n)
+	}
+	if err := m.m.Steps(-n); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("migrate down: %w", err)
+	}
+	return nil
+}
+
+// Force sets the migration version and clears the dirty state. It does not run migrations.
+func (m *Migrator) Force(version int) error {
+	if err := m.m.Force(version); err != nil {
+		return fmt.Errorf("migrate force: %w", err)
+	}
+	return nil
+}
+
+// Status returns the current migration version and whether it is dirty.
+func (m *Migrator) Status() (version uint, dirty bool, err error) {
+	version, dirty, err = m.m.Version()
+	if err != nil {
+		return 0, false, fmt.Errorf("migrate status: %w", err)
+	}
+	return version, dirty, nil
+}
+
+// Close closes the source and the db connections.
+func (m *Migrator) Close() error {
+	srcErr, dbErr := m.m.Close()
+	return errors.Join(srcErr, dbErr)
+}
+
+// Up applies all up migrations of fsys to the db.
+func Up(uri string, fsys fs.FS) error {
+	m, err := New(uri, fsys)
+	if err != nil {
+		return fmt.Errorf("migrate up: %w", err)
+	}
+	defer m.Close()
 
-	return <-errCh
+	return m.Up()
 }
@@ -5,7 +5,6 @@ import (
 	"context"
 	"errors"
 	"sync"
-	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 )
@@ -15,25 +14,25 @@ var (
 	once sync.Once
 )
 
-// Get returns an instance of pgxpool.Pool.
-func Get() (*pgxpool.Pool, error) {
+// Get returns an instance of pgxpool.Pool. The pool is created with the config of the first call.
+func Get(cfg Config) (*pgxpool.Pool, error) {
 	var err error
 
 	once.Do(func() {
-		var cfg *pgxpool.Config
+		var poolConf *pgxpool.Config
 
-		cfg, err = prepareConf()
+		poolConf, err = prepareConf(cfg)
 		if err != nil {
 			return
 		}
 
-		pool, err = pgxpool.NewWithConfig(context.Background(), cfg)
+		pool, err = pgxpool.NewWithConfig(context.Background(), poolConf)
 		if err != nil {
 			return
 		}
 
 		// Ping a new pool.
-		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.PingTimeout)
 		defer cancel()
 		err = pool.Ping(ctx)
 		if err != nil {
@@ -45,20 +44,16 @@ func Get() (*pgxpool.Pool, error) {
 }
 
 // prepareConf prepares pgxpool.Config.
-func prepareConf() (*pgxpool.Config, error) {
-	cfg, err := newPoolConfig()
-	if err != nil {
-		return nil, err
-	}
-
-	if len(cfg.DSN) == 0 {
-		return nil, errors.New("DSN is empty")
+func prepareConf(cfg Config) (*pgxpool.Config, error) {
+	if len(cfg.URI) == 0 {
+		return nil, errors.New("URI is empty")
 	}
-	conf, err := pgxpool.ParseConfig(cfg.DSN)
+	conf, err := pgxpool.ParseConfig(cfg.URI)
 	if err != nil {
 		return nil, err
 	}
-	conf.MaxConns = int32(cfg.MaxConns)
+	conf.MaxConns = cfg.MaxConns
+	conf.ConnConfig.Tracer = newQueryTracer()
 
 	return conf, nil
 }
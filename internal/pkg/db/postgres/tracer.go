@@ -0,0 +1,43 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/alukart32/tmp-weather/internal/pkg/otelx"
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// queryTracer traces pgx queries with OpenTelemetry spans.
+type queryTracer struct {
+	tracer trace.Tracer
+}
+
+// newQueryTracer returns a new queryTracer.
+func newQueryTracer() *queryTracer {
+	return &queryTracer{
+		tracer: otelx.Tracer("github.com/alukart32/tmp-weather/internal/pkg/db/postgres"),
+	}
+}
+
+// TraceQueryStart starts a query span.
+func (t *queryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	ctx, _ = t.tracer.Start(ctx, "postgres.query",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBStatement(data.SQL),
+			attribute.Int("db.args", len(data.Args)),
+		),
+	)
+	return ctx
+}
+
+// TraceQueryEnd ends the query span.
+func (t *queryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attribute.Int64("db.rows_affected", data.CommandTag.RowsAffected()))
+	otelx.End(span, data.Err)
+}
@@ -0,0 +1,46 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestQueryTracer(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	qt := &queryTracer{tracer: sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)).Tracer("test")}
+
+	ctx := qt.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{SQL: "SELECT 1", Args: []any{1, 2}})
+	qt.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{CommandTag: pgconn.NewCommandTag("SELECT 1")})
+
+	ctx = qt.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{SQL: "SELECT broken"})
+	qt.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{Err: errors.New("syntax error")})
+
+	spans := rec.Ended()
+	require.Len(t, spans, 2)
+
+	attrs := attribute.NewSet(spans[0].Attributes()...)
+	assert.Equal(t, "postgres.query", spans[0].Name())
+	assert.Equal(t, codes.Unset, spans[0].Status().Code)
+	v, ok := attrs.Value("db.statement")
+	require.True(t, ok)
+	assert.Equal(t, "SELECT 1", v.AsString())
+	v, ok = attrs.Value("db.args")
+	require.True(t, ok)
+	assert.Equal(t, int64(2), v.AsInt64())
+	v, ok = attrs.Value("db.rows_affected")
+	require.True(t, ok)
+	assert.Equal(t, int64(1), v.AsInt64())
+
+	assert.Equal(t, codes.Error, spans[1].Status().Code)
+	assert.Equal(t, "syntax error", spans[1].Status().Description)
+}
@@ -0,0 +1,175 @@
+// Package ratelimit provides token bucket rate limiters.
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Limit defines the rate of events: N events per Per with bursts of up to Burst events.
+type Limit struct {
+	N     int
+	Per   time.Duration
+	Burst int
+}
+
+// Bucket is a token bucket rate limiter. It is safe for concurrent use.
+type Bucket struct {
+	limit Limit
+	now   func() time.Time
+
+	mtx    sync.Mutex
+	tokens float64
+	last   time.Time // the time tokens were updated
+}
+
+// NewBucket returns a new full Bucket of the limit.
+func NewBucket(limit Limit) *Bucket {
+	return newBucket(limit, time.Now)
+}
+
+func newBucket(limit Limit, now func() time.Time) *Bucket {
+	if limit.Burst <= 0 {
+		limit.Burst = 1
+	}
+	return &Bucket{
+		limit:  limit,
+		now:    now,
+		tokens: float64(limit.Burst),
+		last:   now(),
+	}
+}
+
+// Allow takes a token if it is available and reports whether it is taken.
+func (b *Bucket) Allow() bool {
+	ok, _ := b.AllowAt()
+	return ok
+}
+
+// AllowAt takes a token if it is available. Otherwise, it returns the time to wait for the token.
+func (b *Bucket) AllowAt() (bool, time.Duration) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	b.refill()
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, b.delay()
+}
+
+// Reserve takes a token, possibly in advance, and returns the time to wait until it is available.
+// The following events wait for the reserved tokens, so Reserve queues them by the limit.
+func (b *Bucket) Reserve() time.Duration {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	b.refill()
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	// The reserved token is available when the tokens are refilled to zero.
+	return time.Duration(-b.tokens / b.rate() * float64(time.Second))
+}
+
+// full reports whether the bucket is full.
+func (b *Bucket) full() bool {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	b.refill()
+	return b.tokens >= float64(b.limit.Burst)
+}
+
+// refill adds the tokens of the time passed since the last update.
+func (b *Bucket) refill() {
+	now := b.now()
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * b.rate()
+		if burst := float64(b.limit.Burst); b.tokens > burst {
+			b.tokens = burst
+		}
+	}
+	b.last = now
+}
+
+// delay returns the time until the bucket has a whole token.
+func (b *Bucket) delay() time.Duration {
+	missing := 1 - b.tokens
+	return time.Duration(missing / b.rate() * float64(time.Second))
+}
+
+// rate returns the number of tokens per second.
+func (b *Bucket) rate() float64 {
+	return float64(b.limit.N) / b.limit.Per.Seconds()
+}
+
+// keyedEvictLen is the number of keys after which the full buckets are evicted.
+const keyedEvictLen = 1024
+
+// Keyed is a set of the token bucket rate limiters by key, e.g. a chat ID.
+// The idle buckets are evicted, so keys can be unbounded. It is safe for concurrent use.
+type Keyed struct {
+	limit Limit
+	now   func() time.Time
+
+	mtx      sync.Mutex
+	buckets  map[int64]*Bucket
+	evictLen int // the number of keys when the idle buckets are evicted next time
+}
+
+// NewKeyed returns a new Keyed of the limit.
+func NewKeyed(limit Limit) *Keyed {
+	return newKeyed(limit, time.Now)
+}
+
+func newKeyed(limit Limit, now func() time.Time) *Keyed {
+	return &Keyed{
+		limit:    limit,
+		now:      now,
+		buckets:  make(map[int64]*Bucket),
+		evictLen: keyedEvictLen,
+	}
+}
+
+// AllowAt takes a token of the key if it is available.
+// Otherwise, it returns the time to wait for the token.
+func (k *Keyed) AllowAt(key int64) (bool, time.Duration) {
+	return k.bucket(key).AllowAt()
+}
+
+// Reserve takes a token of the key, possibly in advance, and returns the time to wait until it is available.
+func (k *Keyed) Reserve(key int64) time.Duration {
+	return k.bucket(key).Reserve()
+}
+
+// bucket returns the bucket of the key.
+func (k *Keyed) bucket(key int64) *Bucket {
+	k.mtx.Lock()
+	defer k.mtx.Unlock()
+
+	b, ok := k.buckets[key]
+	if ok {
+		return b
+	}
+
+	if len(k.buckets) >= k.evictLen {
+		// A full bucket is the same as a new one.
+		for key, b := range k.buckets {
+			if b.full() {
+				delete(k.buckets, key)
+			}
+		}
+		// Do not scan the active buckets on each new key.
+		k.evictLen = 2 * len(k.buckets)
+		if k.evictLen < keyedEvictLen {
+			k.evictLen = keyedEvictLen
+		}
+	}
+
+	b = newBucket(k.limit, k.now)
+	k.buckets[key] = b
+	return b
+}
@@ -0,0 +1,87 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeClock is the manually advanced time.
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func TestBucket_AllowAt(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	// 60 per minute with bursts of 3.
+	b := newBucket(Limit{N: 60, Per: time.Minute, Burst: 3}, clock.now)
+
+	for i := 0; i < 3; i++ {
+		assert.True(t, b.Allow(), "burst %d", i)
+	}
+	ok, wait := b.AllowAt()
+	assert.False(t, ok)
+	assert.Equal(t, time.Second, wait)
+
+	clock.advance(500 * time.Millisecond)
+	ok, wait = b.AllowAt()
+	assert.False(t, ok)
+	assert.Equal(t, 500*time.Millisecond, wait)
+
+	clock.advance(500 * time.Millisecond)
+	assert.True(t, b.Allow())
+	assert.False(t, b.Allow())
+
+	// The bucket is refilled up to the burst.
+	clock.advance(time.Hour)
+	for i := 0; i < 3; i++ {
+		assert.True(t, b.Allow(), "burst %d", i)
+	}
+	assert.False(t, b.Allow())
+}
+
+func TestBucket_Reserve(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	// 2 per second with bursts of 1.
+	b := newBucket(Limit{N: 2, Per: time.Second}, clock.now)
+
+	assert.Equal(t, time.Duration(0), b.Reserve())
+	assert.Equal(t, 500*time.Millisecond, b.Reserve())
+	assert.Equal(t, time.Second, b.Reserve())
+
+	// The reserved tokens are not available to Allow.
+	clock.advance(time.Second)
+	assert.False(t, b.Allow())
+	clock.advance(500 * time.Millisecond)
+	assert.True(t, b.Allow())
+}
+
+func TestKeyed(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	k := newKeyed(Limit{N: 1, Per: time.Second}, clock.now)
+
+	ok, _ := k.AllowAt(1)
+	assert.True(t, ok)
+	ok, wait := k.AllowAt(1)
+	assert.False(t, ok)
+	assert.Equal(t, time.Second, wait)
+
+	// Keys have their own buckets.
+	ok, _ = k.AllowAt(2)
+	assert.True(t, ok)
+	assert.Equal(t, time.Second, k.Reserve(2))
+
+	// The idle buckets are evicted.
+	for key := int64(3); len(k.buckets) < keyedEvictLen; key++ {
+		k.AllowAt(key)
+	}
+	clock.advance(10 * time.Second)
+	k.AllowAt(-1)
+	assert.Len(t, k.buckets, 1)
+	assert.Equal(t, keyedEvictLen, k.evictLen)
+}
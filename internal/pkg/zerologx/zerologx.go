@@ -2,9 +2,9 @@
 package zerologx
 
 import (
+	"context"
 	"io"
 	"os"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -13,19 +13,33 @@ import (
 	"github.com/rs/zerolog/pkgerrors"
 )
 
+// Log output formats.
+const (
+	FormatConsole = "console"
+	FormatJSON    = "json"
+)
+
+// Config is the representation of logger settings.
+type Config struct {
+	// Level is a zerolog level number.
+	Level int8 `yaml:"level" toml:"level" env:"LOG_LEVEL"`
+	// Format is the output format: console or json.
+	Format string `yaml:"format" toml:"format" env:"LOG_FORMAT"`
+	// SampleBurst is the number of DEBUG and INFO events allowed per SamplePeriod,
+	// the rest are dropped. Zero disables sampling.
+	SampleBurst  uint32        `yaml:"sample_burst" toml:"sample_burst" env:"LOG_SAMPLE_BURST"`
+	SamplePeriod time.Duration `yaml:"sample_period" toml:"sample_period" env:"LOG_SAMPLE_PERIOD"`
+}
+
 var (
 	once sync.Once
 	log  zerolog.Logger
 )
 
-// Get returns zerolog.Logger.
-func Get() zerolog.Logger {
+// Init configures and returns the global zerolog.Logger.
+// It must be called before the first Get, otherwise the default settings are used.
+func Init(cfg Config) zerolog.Logger {
 	once.Do(func() {
-		logLevel, err := strconv.Atoi(os.Getenv("LOG_LEVEL"))
-		if err != nil {
-			logLevel = int(zerolog.InfoLevel) // default to INFO
-		}
-
 		zerolog.LevelFieldName = "lvl"
 		zerolog.MessageFieldName = "msg"
 		zerolog.ErrorStackMarshaler = pkgerrors.MarshalStack
@@ -34,17 +48,68 @@ func Get() zerolog.Logger {
 			return strings.ToUpper(l.String())
 		}
 
-		var output io.Writer = zerolog.ConsoleWriter{
-			Out:        os.Stdout,
-			TimeFormat: time.RFC3339,
+		var output io.Writer = os.Stdout
+		if !strings.EqualFold(cfg.Format, FormatJSON) {
+			output = zerolog.ConsoleWriter{
+				Out:        os.Stdout,
+				TimeFormat: time.RFC3339,
+			}
 		}
 
 		log = zerolog.New(output).
-			Level(zerolog.Level(logLevel)).
+			Level(zerolog.Level(cfg.Level)).
 			With().
 			Timestamp().
 			Logger()
+
+		if sampler := newSampler(cfg); sampler != nil {
+			log = log.Sample(sampler)
+		}
 	})
 
 	return log
 }
+
+// Get returns zerolog.Logger.
+func Get() zerolog.Logger {
+	return Init(Config{
+		Level:  int8(zerolog.InfoLevel),
+		Format: FormatConsole,
+	})
+}
+
+// newSampler returns a sampler of DEBUG and INFO events or nil if sampling is off.
+func newSampler(cfg Config) zerolog.Sampler {
+	if cfg.SampleBurst == 0 {
+		return nil
+	}
+	period := cfg.SamplePeriod
+	if period <= 0 {
+		period = time.Second
+	}
+
+	s := &zerolog.BurstSampler{
+		Burst:  cfg.SampleBurst,
+		Period: period,
+	}
+	return zerolog.LevelSampler{
+		DebugSampler: s,
+		InfoSampler:  s,
+	}
+}
+
+// ctxKey is the context key of the request-scoped logger.
+type ctxKey struct{}
+
+// WithContext returns a copy of ctx that carries the logger.
+func WithContext(ctx context.Context, logger zerolog.Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, logger)
+}
+
+// Ctx returns the logger carried by ctx. If there is none, the global logger is returned.
+func Ctx(ctx context.Context) zerolog.Logger {
+	if logger, ok := ctx.Value(ctxKey{}).(zerolog.Logger); ok {
+		return logger
+	}
+	return Get()
+}
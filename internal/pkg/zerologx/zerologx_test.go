@@ -0,0 +1,45 @@
+package zerologx
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCtx(t *testing.T) {
+	var buf bytes.Buffer
+	logger := zerolog.New(&buf).With().Int64("chatID", 42).Str("cmd", "info").Logger()
+
+	ctx := WithContext(context.Background(), logger)
+	l := Ctx(ctx)
+	l.Info().Msg("forecast")
+
+	out := buf.String()
+	assert.Contains(t, out, `"chatID":42`)
+	assert.Contains(t, out, `"cmd":"info"`)
+	assert.Contains(t, out, "forecast")
+}
+
+func TestNewSampler(t *testing.T) {
+	assert.Nil(t, newSampler(Config{}), "sampling is off")
+
+	var buf bytes.Buffer
+	logger := zerolog.New(&buf).Sample(newSampler(Config{SampleBurst: 2, SamplePeriod: time.Hour}))
+	for i := 0; i < 5; i++ {
+		logger.Info().Int("i", i).Send()
+		logger.Debug().Int("i", i).Send()
+	}
+	for i := 0; i < 3; i++ {
+		logger.Error().Int("i", i).Send()
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	// The DEBUG and INFO events share the burst, errors are not sampled.
+	assert.Len(t, lines, 2+3)
+	assert.Equal(t, 3, strings.Count(buf.String(), `"level":"error"`))
+}
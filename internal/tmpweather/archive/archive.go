@@ -0,0 +1,269 @@
+// Package archive provides the observed weather of past days.
+//
+// The city is resolved by https://open-meteo.com/en/docs/geocoding-api and the daily
+// weather is taken from https://open-meteo.com/en/docs/historical-weather-api.
+// The archive is updated with a delay of a few days, the last days may have no data.
+package archive
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/alukart32/tmp-weather/internal/pkg/otelx"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var tracer = otelx.Tracer("github.com/alukart32/tmp-weather/internal/tmpweather/archive")
+
+// Open-Meteo APIs.
+const (
+	geocodingAPI = "https://geocoding-api.open-meteo.com/v1/search"
+	archiveAPI   = "https://archive-api.open-meteo.com/v1/archive"
+)
+
+// dailyVariables are the requested daily weather variables: https://open-meteo.com/en/docs/historical-weather-api.
+const dailyVariables = "weather_code,temperature_2m_max,temperature_2m_min,temperature_2m_mean," +
+	"precipitation_sum,rain_sum,snowfall_sum,wind_speed_10m_max"
+
+// FirstDate is the first date of the archive.
+var FirstDate = time.Date(1940, 1, 1, 0, 0, 0, 0, time.UTC)
+
+var (
+	ErrCityNotFound = errors.New("city not found")
+	ErrNoData       = errors.New("no archive data")
+	ErrExternal     = errors.New("archive provider error")
+)
+
+// Config is the representation of the archive client settings.
+type Config struct {
+	Timeout time.Duration `yaml:"timeout" toml:"timeout" env:"ARCHIVE_TIMEOUT"`
+}
+
+// Client is the Open-Meteo archive client. It is safe for concurrent use.
+type Client struct {
+	http         *http.Client
+	geocodingAPI string
+	archiveAPI   string
+}
+
+// NewClient returns a new Client of the config.
+func NewClient(cfg Config) *Client {
+	return &Client{
+		http: &http.Client{
+			Timeout:   cfg.Timeout,
+			Transport: otelhttp.NewTransport(http.DefaultTransport),
+		},
+		geocodingAPI: geocodingAPI,
+		archiveAPI:   archiveAPI,
+	}
+}
+
+// Location represents the geocoded city.
+type Location struct {
+	Name        string
+	CountryCode string  `json:"country_code"`
+	Lat         float64 `json:"latitude"`
+	Lon         float64 `json:"longitude"`
+	Timezone    string  // IANA time zone, e.g. Europe/Berlin
+}
+
+// TimeLocation returns the city time zone, UTC if it is unknown.
+func (l Location) TimeLocation() *time.Location {
+	if len(l.Timezone) == 0 {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(l.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// Geocode returns the location of the city name.
+func (c *Client) Geocode(ctx context.Context, cityName string) (loc Location, err error) {
+	ctx, span := tracer.Start(ctx, "Client.Geocode")
+	span.SetAttributes(attribute.String("city", cityName))
+	defer func() { otelx.End(span, err) }()
+
+	q := url.Values{}
+	q.Set("name", cityName)
+	q.Set("count", "1")
+
+	var resp struct {
+		Results []Location
+	}
+	if err := c.get(ctx, c.geocodingAPI, q, &resp); err != nil {
+		return Location{}, err
+	}
+	if len(resp.Results) == 0 {
+		return Location{}, ErrCityNotFound
+	}
+	return resp.Results[0], nil
+}
+
+// Day represents the observed weather of the day.
+type Day struct {
+	Location      Location
+	Date          time.Time // the day start in the city time zone
+	WeatherCode   int       // WMO weather code
+	TempMax       float64   // C
+	TempMin       float64   // C
+	TempMean      float64   // C
+	Precipitation float64   // mm
+	Rain          float64   // mm
+	Snowfall      float64   // cm
+	WindMax       float64   // m/s
+}
+
+// Day returns the observed weather of the date at the location, ErrNoData if the archive has no data yet.
+func (c *Client) Day(ctx context.Context, loc Location, date time.Time) (day Day, err error) {
+	ctx, span := tracer.Start(ctx, "Client.Day")
+	span.SetAttributes(attribute.String("city", loc.Name), attribute.String("date", date.Format(time.DateOnly)))
+	defer func() { otelx.End(span, err) }()
+
+	q := url.Values{}
+	q.Set("latitude", strconv.FormatFloat(loc.Lat, 'f', -1, 64))
+	q.Set("longitude", strconv.FormatFloat(loc.Lon, 'f', -1, 64))
+	q.Set("start_date", date.Format(time.DateOnly))
+	q.Set("end_date", date.Format(time.DateOnly))
+	q.Set("daily", dailyVariables)
+	q.Set("wind_speed_unit", "ms")
+	q.Set("timezone", "auto")
+
+	// The values of the days without data are null.
+	var resp struct {
+		Daily struct {
+			Time          []string
+			WeatherCode   []*int     `json:"weather_code"`
+			TempMax       []*float64 `json:"temperature_2m_max"`
+			TempMin       []*float64 `json:"temperature_2m_min"`
+			TempMean      []*float64 `json:"temperature_2m_mean"`
+			Precipitation []*float64 `json:"precipitation_sum"`
+			Rain          []*float64 `json:"rain_sum"`
+			Snowfall      []*float64 `json:"snowfall_sum"`
+			WindMax       []*float64 `json:"wind_speed_10m_max"`
+		}
+	}
+	if err := c.get(ctx, c.archiveAPI, q, &resp); err != nil {
+		return Day{}, err
+	}
+
+	d := resp.Daily
+	if len(d.Time) == 0 || len(d.WeatherCode) == 0 || d.WeatherCode[0] == nil ||
+		len(d.TempMax) == 0 || d.TempMax[0] == nil || len(d.TempMin) == 0 || d.TempMin[0] == nil {
+		return Day{}, ErrNoData
+	}
+	first := func(v []*float64) float64 {
+		if len(v) == 0 || v[0] == nil {
+			return 0
+		}
+		return *v[0]
+	}
+
+	y, m, dd := date.Date()
+	return Day{
+		Location:      loc,
+		Date:          time.Date(y, m, dd, 0, 0, 0, 0, loc.TimeLocation()),
+		WeatherCode:   *d.WeatherCode[0],
+		TempMax:       *d.TempMax[0],
+		TempMin:       *d.TempMin[0],
+		TempMean:      first(d.TempMean),
+		Precipitation: first(d.Precipitation),
+		Rain:          first(d.Rain),
+		Snowfall:      first(d.Snowfall),
+		WindMax:       first(d.WindMax),
+	}, nil
+}
+
+// get calls the API and decodes the JSON response into v.
+func (c *Client) get(ctx context.Context, api string, q url.Values, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, api+"?"+q.Encode(), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrExternal, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrExternal, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		// https://open-meteo.com/en/docs: {"error": true, "reason": "..."}
+		var apiErr struct {
+			Reason string
+		}
+		_ = json.Unmarshal(body, &apiErr)
+		return fmt.Errorf("%w: %d %s", ErrExternal, resp.StatusCode, apiErr.Reason)
+	}
+
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("%w: invalid response: %v", ErrExternal, err)
+	}
+	return nil
+}
+
+// wmoDescriptions are the descriptions of WMO weather codes: https://open-meteo.com/en/docs#weathervariables.
+var wmoDescriptions = map[int]string{
+	0:  "clear sky",
+	1:  "mainly clear",
+	2:  "partly cloudy",
+	3:  "overcast",
+	45: "fog",
+	48: "depositing rime fog",
+	51: "light drizzle",
+	53: "moderate drizzle",
+	55: "dense drizzle",
+	56: "light freezing drizzle",
+	57: "dense freezing drizzle",
+	61: "slight rain",
+	63: "moderate rain",
+	65: "heavy rain",
+	66: "light freezing rain",
+	67: "heavy freezing rain",
+	71: "slight snow fall",
+	73: "moderate snow fall",
+	75: "heavy snow fall",
+	77: "snow grains",
+	80: "slight rain showers",
+	81: "moderate rain showers",
+	82: "violent rain showers",
+	85: "slight snow showers",
+	86: "heavy snow showers",
+	95: "thunderstorm",
+	96: "thunderstorm with slight hail",
+	99: "thunderstorm with heavy hail",
+}
+
+// Description returns the weather description of the day.
+func (d Day) Description() string {
+	if desc, ok := wmoDescriptions[d.WeatherCode]; ok {
+		return desc
+	}
+	return "unknown weather"
+}
+
+// MarshalZerologObject adds Day to the logger as an object.
+func (d Day) MarshalZerologObject(e *zerolog.Event) {
+	e.Str("city", d.Location.Name).
+		Str("country", d.Location.CountryCode).
+		Str("date", d.Date.Format(time.DateOnly)).
+		Int("weatherCode", d.WeatherCode).
+		Float64("tempMin", d.TempMin).
+		Float64("tempMax", d.TempMax).
+		Float64("precipitation", d.Precipitation).
+		Float64("windMax", d.WindMax)
+}
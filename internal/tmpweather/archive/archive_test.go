@@ -0,0 +1,72 @@
+package archive
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		switch r.URL.Path {
+		case "/geocoding":
+			if q.Get("name") != "Berlin" {
+				_, _ = w.Write([]byte(`{"generationtime_ms":0.5}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"results":[{"id":2950159,"name":"Berlin","latitude":52.52437,"longitude":13.41053,` +
+				`"country_code":"DE","timezone":"Europe/Berlin","country":"Germany"}]}`))
+		case "/archive":
+			assert.Equal(t, "52.52437", q.Get("latitude"))
+			assert.Equal(t, q.Get("start_date"), q.Get("end_date"))
+			switch q.Get("start_date") {
+			case "2024-01-01":
+				_, _ = w.Write([]byte(`{"latitude":52.52,"longitude":13.41,"timezone":"Europe/Berlin","daily":{` +
+					`"time":["2024-01-01"],"weather_code":[61],"temperature_2m_max":[9.1],"temperature_2m_min":[3.2],` +
+					`"temperature_2m_mean":[5.9],"precipitation_sum":[4.2],"rain_sum":[4.2],"snowfall_sum":[0.0],` +
+					`"wind_speed_10m_max":[7.55]}}`))
+			case "2030-01-01":
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"error":true,"reason":"Parameter 'start_date' is out of allowed range"}`))
+			default:
+				_, _ = w.Write([]byte(`{"daily":{"time":["2024-06-01"],"weather_code":[null],"temperature_2m_max":[null],` +
+					`"temperature_2m_min":[null]}}`))
+			}
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClient(Config{Timeout: time.Second})
+	c.geocodingAPI, c.archiveAPI = srv.URL+"/geocoding", srv.URL+"/archive"
+	ctx := context.Background()
+
+	_, err := c.Geocode(ctx, "Atlantis")
+	assert.ErrorIs(t, err, ErrCityNotFound)
+
+	loc, err := c.Geocode(ctx, "Berlin")
+	require.NoError(t, err)
+	assert.Equal(t, "DE", loc.CountryCode)
+	assert.Equal(t, "Europe/Berlin", loc.TimeLocation().String())
+
+	day, err := c.Day(ctx, loc, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Equal(t, "slight rain", day.Description())
+	assert.Equal(t, 9.1, day.TempMax)
+	assert.Equal(t, 3.2, day.TempMin)
+	assert.Equal(t, 4.2, day.Precipitation)
+	assert.Equal(t, 7.55, day.WindMax)
+	assert.Equal(t, "2024-01-01T00:00:00+01:00", day.Date.Format(time.RFC3339))
+
+	_, err = c.Day(ctx, loc, time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC))
+	assert.ErrorIs(t, err, ErrNoData)
+
+	_, err = c.Day(ctx, loc, time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+	assert.ErrorIs(t, err, ErrExternal)
+	assert.Contains(t, err.Error(), "400 Parameter 'start_date' is out of allowed range")
+}
@@ -0,0 +1,160 @@
+// Package astro computes the sun position and the sun events of a day locally
+// by the sunrise equation: https://en.wikipedia.org/wiki/Sunrise_equation.
+// The times are accurate to about a minute between the polar circles.
+package astro
+
+import (
+	"math"
+	"time"
+)
+
+// Sun altitudes of the day events, degrees.
+const (
+	// SunriseAltitude is the altitude of the sun center at sunrise and sunset:
+	// the upper limb touches the horizon, corrected for the atmospheric refraction.
+	SunriseAltitude = -0.833
+	// CivilTwilightAltitude is the altitude at civil dawn and dusk.
+	CivilTwilightAltitude = -6.0
+	// GoldenHourAltitude is the altitude the golden hour starts in the evening and ends in the morning.
+	GoldenHourAltitude = 6.0
+)
+
+// Julian date constants.
+const (
+	j2000     = 2451545.0 // Julian date of 2000-01-01 12:00 TT
+	unixEpoch = 2440587.5 // Julian date of 1970-01-01 00:00 UTC
+	secPerDay = 24 * 60 * 60
+)
+
+// Day represents the sun events of the day at the location. The times are in the date location,
+// the events that do not happen on the day are zero.
+type Day struct {
+	Noon time.Time // solar noon
+
+	Sunrise time.Time
+	Sunset  time.Time
+
+	CivilDawn time.Time // civil twilight start
+	CivilDusk time.Time // civil twilight end
+
+	GoldenMorningEnd     time.Time // the morning golden hour is from sunrise to GoldenMorningEnd
+	GoldenEveningStart   time.Time // the evening golden hour is from GoldenEveningStart to sunset
+	PolarDay, PolarNight bool      // the sun does not set or rise
+}
+
+// DayLength returns the time between sunrise and sunset: 24h on a polar day and 0 on a polar night.
+func (d Day) DayLength() time.Duration {
+	switch {
+	case d.PolarDay:
+		return 24 * time.Hour
+	case d.PolarNight:
+		return 0
+	}
+	return d.Sunset.Sub(d.Sunrise)
+}
+
+// SunDay returns the sun events of the date at the latitude and longitude, degrees (east and north are positive).
+// The calendar date is taken in the date location.
+func SunDay(date time.Time, lat, lon float64) Day {
+	loc := date.Location()
+	s := newSolarDay(date, lon)
+
+	at := func(j float64) time.Time {
+		return julianTime(j).In(loc)
+	}
+	// event returns the morning and evening times of the altitude,
+	// ok is false if the sun does not reach it, above is true if it stays above it.
+	event := func(altitude float64) (morning, evening time.Time, ok, above bool) {
+		w, ok, above := s.hourAngle(lat, altitude)
+		if !ok {
+			return time.Time{}, time.Time{}, false, above
+		}
+		return at(s.transit - w/360), at(s.transit + w/360), true, above
+	}
+
+	d := Day{Noon: at(s.transit)}
+
+	var ok, above bool
+	d.Sunrise, d.Sunset, ok, above = event(SunriseAltitude)
+	if !ok {
+		d.PolarDay, d.PolarNight = above, !above
+	}
+	d.CivilDawn, d.CivilDusk, _, _ = event(CivilTwilightAltitude)
+	d.GoldenMorningEnd, d.GoldenEveningStart, _, _ = event(GoldenHourAltitude)
+	return d
+}
+
+// Altitude returns the sun altitude above the horizon at the time and location, degrees.
+func Altitude(t time.Time, lat, lon float64) float64 {
+	s := newSolarDay(t.In(time.UTC), lon)
+	// The sun hour angle moves 360 degrees a day from the transit.
+	h := (julianDate(t) - s.transit) * 360
+
+	phi := rad(lat)
+	sinAlt := math.Sin(phi)*math.Sin(s.decl) + math.Cos(phi)*math.Cos(s.decl)*math.Cos(rad(h))
+	return deg(math.Asin(sinAlt))
+}
+
+// IsDay reports whether the sun is up at the time and location.
+func IsDay(t time.Time, lat, lon float64) bool {
+	return Altitude(t, lat, lon) > SunriseAltitude
+}
+
+// solarDay is the sun position of the day.
+type solarDay struct {
+	transit float64 // Julian date of the solar noon
+	decl    float64 // declination, radians
+}
+
+// newSolarDay returns the sun position of the calendar date at the longitude.
+func newSolarDay(date time.Time, lon float64) solarDay {
+	y, m, d := date.Date()
+	// Days since J2000 at the noon of the date, 0.0008 is the TT and UTC difference.
+	n := julianDate(time.Date(y, m, d, 12, 0, 0, 0, time.UTC)) - j2000 + 0.0008
+	// Mean solar time at the longitude.
+	jStar := n - lon/360
+
+	// Solar mean anomaly and the equation of the center.
+	ma := math.Mod(357.5291+0.98560028*jStar, 360)
+	mr := rad(ma)
+	c := 1.9148*math.Sin(mr) + 0.0200*math.Sin(2*mr) + 0.0003*math.Sin(3*mr)
+	// Ecliptic longitude.
+	lambda := rad(math.Mod(ma+c+180+102.9372, 360))
+
+	return solarDay{
+		transit: j2000 + jStar + 0.0053*math.Sin(mr) - 0.0069*math.Sin(2*lambda),
+		decl:    math.Asin(math.Sin(lambda) * math.Sin(rad(23.4397))),
+	}
+}
+
+// hourAngle returns the hour angle of the altitude at the latitude, degrees.
+// ok is false if the sun does not reach the altitude, above is true if it stays above it all day.
+func (s solarDay) hourAngle(lat, altitude float64) (w float64, ok, above bool) {
+	phi := rad(lat)
+	cosW := (math.Sin(rad(altitude)) - math.Sin(phi)*math.Sin(s.decl)) / (math.Cos(phi) * math.Cos(s.decl))
+	switch {
+	case cosW > 1:
+		return 0, false, false
+	case cosW < -1:
+		return 0, false, true
+	}
+	return deg(math.Acos(cosW)), true, false
+}
+
+// julianDate returns the Julian date of the time.
+func julianDate(t time.Time) float64 {
+	return float64(t.UnixNano())/1e9/secPerDay + unixEpoch
+}
+
+// julianTime returns the time of the Julian date rounded to seconds.
+func julianTime(j float64) time.Time {
+	return time.Unix(int64(math.Round((j-unixEpoch)*secPerDay)), 0)
+}
+
+func rad(d float64) float64 {
+	return d * math.Pi / 180
+}
+
+func deg(r float64) float64 {
+	return r * 180 / math.Pi
+}
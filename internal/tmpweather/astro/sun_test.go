@@ -0,0 +1,147 @@
+package astro
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// tolerance is the max difference from the published times.
+const tolerance = 2 * time.Minute
+
+func TestSunDay(t *testing.T) {
+	tests := []struct {
+		name        string
+		date        time.Time
+		lat, lon    float64
+		wantNoon    string
+		wantSunrise string
+		wantSunset  string
+	}{
+		{
+			// https://openweathermap.org/current#current_JSON example.
+			name:        "Zocca",
+			date:        time.Date(2022, 8, 30, 0, 0, 0, 0, time.FixedZone("CEST", 2*3600)),
+			lat:         44.34,
+			lon:         10.99,
+			wantSunrise: "06:36",
+			wantSunset:  "19:57",
+		},
+		{
+			name:        "London summer solstice",
+			date:        time.Date(2023, 6, 21, 0, 0, 0, 0, time.FixedZone("BST", 3600)),
+			lat:         51.5074,
+			lon:         -0.1278,
+			wantSunrise: "04:43",
+			wantSunset:  "21:21",
+		},
+		{
+			name:        "New York winter solstice",
+			date:        time.Date(2023, 12, 21, 0, 0, 0, 0, time.FixedZone("EST", -5*3600)),
+			lat:         40.7128,
+			lon:         -74.006,
+			wantSunrise: "07:16",
+			wantSunset:  "16:32",
+		},
+		{
+			name:        "Sydney summer solstice",
+			date:        time.Date(2023, 12, 21, 0, 0, 0, 0, time.FixedZone("AEDT", 11*3600)),
+			lat:         -33.8688,
+			lon:         151.2093,
+			wantSunrise: "05:41",
+			wantSunset:  "20:05",
+		},
+		{
+			// The equation of time is at its max in early November.
+			name:     "Greenwich noon in November",
+			date:     time.Date(2023, 11, 3, 0, 0, 0, 0, time.UTC),
+			lat:      51.4779,
+			wantNoon: "11:43",
+		},
+		{
+			// The equation of time is at its min in mid-February.
+			name:     "Greenwich noon in February",
+			date:     time.Date(2023, 2, 11, 0, 0, 0, 0, time.UTC),
+			lat:      51.4779,
+			wantNoon: "12:14",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := SunDay(tt.date, tt.lat, tt.lon)
+			assert.False(t, d.PolarDay || d.PolarNight)
+
+			assertTime := func(want string, got time.Time) {
+				t.Helper()
+				if len(want) == 0 {
+					return
+				}
+				w, err := time.ParseInLocation("15:04", want, tt.date.Location())
+				assert.NoError(t, err)
+				y, m, day := tt.date.Date()
+				w = w.AddDate(y, int(m)-1, day-1)
+				assert.WithinDuration(t, w, got, tolerance, "want %s, got %s", want, got.Format("15:04:05"))
+			}
+			assertTime(tt.wantNoon, d.Noon)
+			assertTime(tt.wantSunrise, d.Sunrise)
+			assertTime(tt.wantSunset, d.Sunset)
+
+			// The events are ordered through the day.
+			assert.True(t, d.CivilDawn.Before(d.Sunrise))
+			assert.True(t, d.Sunrise.Before(d.GoldenMorningEnd))
+			assert.True(t, d.GoldenMorningEnd.Before(d.Noon))
+			assert.True(t, d.Noon.Before(d.GoldenEveningStart))
+			assert.True(t, d.GoldenEveningStart.Before(d.Sunset))
+			assert.True(t, d.Sunset.Before(d.CivilDusk))
+			assert.Equal(t, d.Sunset.Sub(d.Sunrise), d.DayLength())
+		})
+	}
+}
+
+func TestSunDay_polar(t *testing.T) {
+	const lat, lon = 69.6496, 18.956 // Tromsø
+
+	night := SunDay(time.Date(2023, 12, 21, 0, 0, 0, 0, time.UTC), lat, lon)
+	assert.True(t, night.PolarNight)
+	assert.False(t, night.PolarDay)
+	assert.True(t, night.Sunrise.IsZero())
+	assert.Zero(t, night.DayLength())
+	assert.False(t, night.CivilDawn.IsZero(), "civil twilight at noon")
+
+	day := SunDay(time.Date(2023, 6, 21, 0, 0, 0, 0, time.UTC), lat, lon)
+	assert.True(t, day.PolarDay)
+	assert.False(t, day.PolarNight)
+	assert.True(t, day.Sunset.IsZero())
+	assert.Equal(t, 24*time.Hour, day.DayLength())
+}
+
+func TestIsDay(t *testing.T) {
+	const lat, lon = 51.5074, -0.1278 // London
+
+	tests := []struct {
+		name string
+		t    time.Time
+		want bool
+	}{
+		{name: "Noon", t: time.Date(2023, 12, 21, 12, 0, 0, 0, time.UTC), want: true},
+		{name: "Midnight", t: time.Date(2023, 6, 21, 0, 0, 0, 0, time.UTC), want: false},
+		{name: "Before sunrise", t: time.Date(2023, 12, 21, 7, 50, 0, 0, time.UTC), want: false},
+		{name: "After sunrise", t: time.Date(2023, 12, 21, 8, 15, 0, 0, time.UTC), want: true},
+		{name: "Summer evening", t: time.Date(2023, 6, 21, 20, 0, 0, 0, time.UTC), want: true},
+		{name: "After sunset", t: time.Date(2023, 12, 21, 16, 15, 0, 0, time.UTC), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsDay(tt.t, lat, lon))
+		})
+	}
+}
+
+func TestAltitude(t *testing.T) {
+	// The noon altitude is 90 - latitude + declination, the declination is about 23.44 at the solstice.
+	noon := SunDay(time.Date(2023, 6, 21, 0, 0, 0, 0, time.UTC), 51.5074, -0.1278).Noon
+	assert.InDelta(t, 90-51.5074+23.44, Altitude(noon, 51.5074, -0.1278), 0.1)
+}
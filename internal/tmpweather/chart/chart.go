@@ -0,0 +1,114 @@
+// Package chart renders weather trend charts as PNG images.
+package chart
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image/color"
+	"time"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+	"gonum.org/v1/plot/vg/vgimg"
+)
+
+// Chart image size.
+const (
+	width  = 16 * vg.Centimeter
+	height = 18 * vg.Centimeter
+)
+
+// ErrNoPoints is returned when there is nothing to render.
+var ErrNoPoints = errors.New("no points")
+
+// Point represents the weather at the moment.
+type Point struct {
+	Time time.Time
+	Temp float64
+	Hum  float64
+	Wind float64
+}
+
+// series defines the chart of the point value.
+type series struct {
+	label string
+	color color.Color
+	value func(Point) float64
+}
+
+var allSeries = []series{
+	{
+		label: "temp, C",
+		color: color.RGBA{R: 214, G: 39, B: 40, A: 255},
+		value: func(p Point) float64 { return p.Temp },
+	},
+	{
+		label: "hum, %",
+		color: color.RGBA{R: 31, G: 119, B: 180, A: 255},
+		value: func(p Point) float64 { return p.Hum },
+	},
+	{
+		label: "wind, m/s",
+		color: color.RGBA{R: 44, G: 160, B: 44, A: 255},
+		value: func(p Point) float64 { return p.Wind },
+	},
+}
+
+// Render renders the temperature, humidity and wind charts of the points ordered by time.
+// The charts share the time axis in loc and are stacked vertically in a PNG image.
+func Render(title string, points []Point, loc *time.Location) ([]byte, error) {
+	if len(points) == 0 {
+		return nil, ErrNoPoints
+	}
+
+	plots := make([][]*plot.Plot, len(allSeries))
+	for i, s := range allSeries {
+		p := plot.New()
+		if i == 0 {
+			p.Title.Text = title
+		}
+		p.Y.Label.Text = s.label
+		p.X.Tick.Marker = plot.TimeTicks{Format: "Jan 2\n15:04", Time: plot.UnixTimeIn(loc)}
+
+		xys := make(plotter.XYs, len(points))
+		for j, pt := range points {
+			xys[j].X = float64(pt.Time.Unix())
+			xys[j].Y = s.value(pt)
+		}
+		line, scatter, err := plotter.NewLinePoints(xys)
+		if err != nil {
+			return nil, fmt.Errorf("plot %s: %w", s.label, err)
+		}
+		line.Color = s.color
+		scatter.Color = s.color
+		scatter.Radius = vg.Points(2)
+
+		p.Add(plotter.NewGrid(), line, scatter)
+		plots[i] = []*plot.Plot{p}
+	}
+
+	img := vgimg.New(width, height)
+	dc := draw.New(img)
+	tiles := draw.Tiles{
+		Rows:      len(allSeries),
+		Cols:      1,
+		PadTop:    vg.Millimeter,
+		PadBottom: vg.Millimeter,
+		PadLeft:   vg.Millimeter,
+		PadRight:  4 * vg.Millimeter,
+		PadY:      2 * vg.Millimeter,
+	}
+	canvases := plot.Align(plots, tiles, dc)
+	for i := range plots {
+		plots[i][0].Draw(canvases[i][0])
+	}
+
+	var buf bytes.Buffer
+	if _, err := (vgimg.PngCanvas{Canvas: img}).WriteTo(&buf); err != nil {
+		return nil, fmt.Errorf("encode png: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,53 @@
+package chart
+
+import (
+	"bytes"
+	"image/png"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRender(t *testing.T) {
+	start := time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		points  []Point
+		wantErr error
+	}{
+		{
+			name: "Several points",
+			points: []Point{
+				{Time: start, Temp: 10, Hum: 50, Wind: 2},
+				{Time: start.Add(time.Hour), Temp: 12.5, Hum: 45, Wind: 3.5},
+				{Time: start.Add(2 * time.Hour), Temp: 11, Hum: 60, Wind: 1},
+			},
+		},
+		{
+			name:   "Single point",
+			points: []Point{{Time: start, Temp: -5, Hum: 80, Wind: 7}},
+		},
+		{
+			name:    "No points",
+			wantErr: ErrNoPoints,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := Render("Berlin", tt.points, time.UTC)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			img, err := png.Decode(bytes.NewReader(data))
+			require.NoError(t, err)
+			assert.False(t, img.Bounds().Empty())
+		})
+	}
+}
@@ -0,0 +1,169 @@
+// Package collector provides the periodic collection of the current weather
+// of the city watchlist, so weather trends are stored independently of user requests.
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alukart32/tmp-weather/internal/pkg/otelx"
+	"github.com/alukart32/tmp-weather/internal/pkg/zerologx"
+	"github.com/alukart32/tmp-weather/internal/tmpweather/storage"
+	"github.com/alukart32/tmp-weather/internal/tmpweather/weather"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var tracer = otelx.Tracer("github.com/alukart32/tmp-weather/internal/tmpweather/collector")
+
+var meter = otelx.Meter("github.com/alukart32/tmp-weather/internal/tmpweather/collector")
+
+var observationsCounter = newObservationsCounter()
+
+func newObservationsCounter() metric.Int64Counter {
+	c, err := meter.Int64Counter("tmpweather.collector.observations",
+		metric.WithDescription("City observations fetched by the collector by result"))
+	if err != nil {
+		otel.Handle(err)
+	}
+	return c
+}
+
+// Config is the representation of collector settings.
+type Config struct {
+	// Cities is the watchlist, the collector is disabled if it is empty.
+	Cities []string `yaml:"cities" toml:"cities" env:"COLLECTOR_CITIES" envSeparator:","`
+	// Interval is the collection period.
+	Interval time.Duration `yaml:"interval" toml:"interval" env:"COLLECTOR_INTERVAL"`
+}
+
+// Forecaster defines the source of the current weather by city name.
+type Forecaster interface {
+	Forecast(ctx context.Context, cityName string) (weather.Forecast, error)
+}
+
+// Collector periodically fetches the current weather of the watchlist cities
+// and stores it as observations.
+type Collector struct {
+	forecaster Forecaster
+	repo       storage.ObservationRepository
+	cfg        Config
+}
+
+// New returns a new Collector.
+func New(forecaster Forecaster, repo storage.ObservationRepository, cfg Config) (*Collector, error) {
+	if len(cfg.Cities) == 0 {
+		return nil, fmt.Errorf("empty city watchlist")
+	}
+	if cfg.Interval <= 0 {
+		return nil, fmt.Errorf("invalid collector interval: %v", cfg.Interval)
+	}
+
+	return &Collector{
+		forecaster: forecaster,
+		repo:       repo,
+		cfg:        cfg,
+	}, nil
+}
+
+// Run collects observations at once and then every interval until ctx is done.
+func (c *Collector) Run(ctx context.Context) {
+	logger := zerologx.Ctx(ctx)
+
+	ticker := time.NewTicker(c.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := c.Collect(ctx)
+		if err != nil && ctx.Err() == nil {
+			logger.Error().
+				Str("op", "collect observations").
+				Int("stored", n).
+				Err(err).Send()
+		} else {
+			logger.Info().
+				Str("op", "collect observations").
+				Int("stored", n).Send()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Collect fetches the current weather of the watchlist cities and stores it.
+// It returns the number of stored observations. A failed city does not stop the others.
+func (c *Collector) Collect(ctx context.Context) (n int, err error) {
+	ctx, span := tracer.Start(ctx, "Collector.Collect")
+	defer func() {
+		span.SetAttributes(attribute.Int("observations", n))
+		otelx.End(span, err)
+	}()
+
+	var (
+		obs  []storage.Observation
+		errs []error
+	)
+	for _, city := range c.cfg.Cities {
+		f, err := c.forecast(ctx, city)
+		if err != nil {
+			observationsCounter.Add(ctx, 1, metric.WithAttributes(attribute.String("result", "error")))
+			if ctx.Err() != nil {
+				return 0, ctx.Err()
+			}
+			errs = append(errs, fmt.Errorf("%s: %w", city, err))
+			continue
+		}
+		observationsCounter.Add(ctx, 1, metric.WithAttributes(attribute.String("result", "ok")))
+
+		o := storage.Observation{
+			City:       city,
+			ObservedAt: f.ObservedAt(),
+			Temp:       f.Main.Temp,
+			FeelsLike:  f.Main.FeelsLike,
+			Hum:        f.Main.Humidity,
+			Wind:       f.Wind.Speed,
+		}
+		if len(f.Weather) != 0 {
+			o.Desc = f.Weather[0].Description
+		}
+		obs = append(obs, o)
+	}
+
+	if len(obs) != 0 {
+		if err := c.repo.InsertObservations(ctx, obs); err != nil {
+			return 0, errors.Join(append(errs, fmt.Errorf("store observations: %w", err))...)
+		}
+	}
+	return len(obs), errors.Join(errs...)
+}
+
+// rateLimitDelay is the delay before the next try of the rate limited forecast.
+var rateLimitDelay = time.Second
+
+// rateLimitMaxTries is the max number of tries of the rate limited forecast.
+const rateLimitMaxTries = 60
+
+// forecast returns the city forecast. It waits up to rateLimitMaxTries delays until the local calls limit
+// allows the forecast, so the collector does not skip the cities at a busy time. The provider quota errors
+// are returned at once: the quota is not refilled in seconds and the tries would take the users calls.
+func (c *Collector) forecast(ctx context.Context, city string) (weather.Forecast, error) {
+	for try := 1; ; try++ {
+		f, err := c.forecaster.Forecast(ctx, city)
+		if !errors.Is(err, weather.ErrCallsLimited) || try >= rateLimitMaxTries {
+			return f, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return weather.Forecast{}, ctx.Err()
+		case <-time.After(rateLimitDelay):
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package collector
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alukart32/tmp-weather/internal/tmpweather/storage"
+	"github.com/alukart32/tmp-weather/internal/tmpweather/weather"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeObservedAt is the time of the fake forecasts weather data.
+var fakeObservedAt = time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+
+// fakeForecaster returns the forecast of the known cities.
+type fakeForecaster map[string]float64
+
+func (f fakeForecaster) Forecast(ctx context.Context, cityName string) (weather.Forecast, error) {
+	temp, ok := f[cityName]
+	if !ok {
+		return weather.Forecast{}, weather.ErrCityNotFound
+	}
+
+	var forecast weather.Forecast
+	forecast.MadeAt = time.Now()
+	forecast.Dt = fakeObservedAt.Unix()
+	forecast.Main.Temp = temp
+	forecast.Main.Humidity = 50
+	forecast.Wind.Speed = 3
+	return forecast, nil
+}
+
+func TestCollector_Collect(t *testing.T) {
+	tests := []struct {
+		name      string
+		cities    []string
+		wantN     int
+		wantErr   bool
+		wantTemps map[string]float64
+	}{
+		{
+			name:      "All cities",
+			cities:    []string{"Berlin", "Paris"},
+			wantN:     2,
+			wantTemps: map[string]float64{"Berlin": 10, "Paris": 20},
+		},
+		{
+			name:      "Failed city does not stop others",
+			cities:    []string{"Unknown", "Paris"},
+			wantN:     1,
+			wantErr:   true,
+			wantTemps: map[string]float64{"Paris": 20},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := storage.NewMemoryRepo()
+			c, err := New(fakeForecaster{"Berlin": 10, "Paris": 20}, repo, Config{
+				Cities:   tt.cities,
+				Interval: time.Hour,
+			})
+			require.NoError(t, err)
+
+			n, err := c.Collect(context.TODO())
+			if tt.wantErr {
+				require.ErrorIs(t, err, weather.ErrCityNotFound)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantN, n)
+
+			for city, temp := range tt.wantTemps {
+				obs, err := repo.Observations(context.TODO(), storage.CityQuery{City: city})
+				require.NoError(t, err)
+				require.Len(t, obs, 1)
+				assert.Equal(t, temp, obs[0].Temp)
+				assert.True(t, fakeObservedAt.Equal(obs[0].ObservedAt), "provider time is stored")
+			}
+		})
+	}
+}
+
+func TestCollector_Collect_sameObservation(t *testing.T) {
+	repo := storage.NewMemoryRepo()
+	c, err := New(fakeForecaster{"Berlin": 10}, repo, Config{Cities: []string{"Berlin"}, Interval: time.Hour})
+	require.NoError(t, err)
+
+	// The provider has not updated the data between the collections.
+	for i := 0; i < 2; i++ {
+		_, err := c.Collect(context.TODO())
+		require.NoError(t, err)
+	}
+
+	obs, err := repo.Observations(context.TODO(), storage.CityQuery{City: "Berlin"})
+	require.NoError(t, err)
+	assert.Len(t, obs, 1)
+}
+
+// limitedForecaster is rate limited on every other call.
+type limitedForecaster struct {
+	fakeForecaster
+	calls int
+}
+
+func (f *limitedForecaster) Forecast(ctx context.Context, cityName string) (weather.Forecast, error) {
+	f.calls++
+	if f.calls%2 == 1 {
+		return weather.Forecast{}, weather.ErrCallsLimited
+	}
+	return f.fakeForecaster.Forecast(ctx, cityName)
+}
+
+// failingForecaster always fails with the error.
+type failingForecaster struct {
+	err   error
+	calls int
+}
+
+func (f *failingForecaster) Forecast(ctx context.Context, cityName string) (weather.Forecast, error) {
+	f.calls++
+	return weather.Forecast{}, f.err
+}
+
+func TestCollector_Collect_limitNotLifted(t *testing.T) {
+	delay := rateLimitDelay
+	rateLimitDelay = time.Millisecond
+	defer func() { rateLimitDelay = delay }()
+
+	tests := []struct {
+		name      string
+		err       error
+		wantCalls int
+	}{
+		{
+			name:      "Provider quota skips the cities at once",
+			err:       &weather.APIError{StatusCode: 429, Err: weather.ErrRateLimited},
+			wantCalls: 2,
+		},
+		{
+			name:      "Calls limit tries are capped",
+			err:       weather.ErrCallsLimited,
+			wantCalls: 2 * rateLimitMaxTries,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forecaster := &failingForecaster{err: tt.err}
+			c, err := New(forecaster, storage.NewMemoryRepo(), Config{
+				Cities:   []string{"Berlin", "Paris"},
+				Interval: time.Hour,
+			})
+			require.NoError(t, err)
+
+			n, err := c.Collect(context.TODO())
+			assert.ErrorIs(t, err, weather.ErrRateLimited)
+			assert.Zero(t, n)
+			assert.Equal(t, tt.wantCalls, forecaster.calls)
+		})
+	}
+}
@@ -0,0 +1,261 @@
+// Package config provides the application configuration.
+//
+// The configuration is loaded in the following order, each step overrides the previous one:
+//
+//  1. default values;
+//  2. the optional YAML or TOML file set by the CONFIG_PATH env parameter;
+//  3. env parameters.
+//
+// Any env parameter X can be read from a file, e.g. a docker secret, whose path is set by X_FILE.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/alukart32/tmp-weather/internal/pkg/db/postgres"
+	"github.com/alukart32/tmp-weather/internal/pkg/otelx"
+	"github.com/alukart32/tmp-weather/internal/pkg/zerologx"
+	"github.com/alukart32/tmp-weather/internal/tmpweather/archive"
+	"github.com/alukart32/tmp-weather/internal/tmpweather/collector"
+	"github.com/alukart32/tmp-weather/internal/tmpweather/storage"
+	"github.com/alukart32/tmp-weather/internal/tmpweather/telegram"
+	"github.com/alukart32/tmp-weather/internal/tmpweather/weather"
+	"github.com/caarlos0/env/v6"
+	"github.com/rs/zerolog"
+	"gopkg.in/yaml.v3"
+)
+
+// pathEnv is the env parameter of the config file path.
+const pathEnv = "CONFIG_PATH"
+
+// fileSuffix is the suffix of env parameters whose value is read from a file.
+const fileSuffix = "_FILE"
+
+// Config is the application configuration.
+type Config struct {
+	Telegram  telegram.Config  `yaml:"telegram" toml:"telegram"`
+	Weather   weather.Config   `yaml:"weather" toml:"weather"`
+	Archive   archive.Config   `yaml:"archive" toml:"archive"`
+	Storage   storage.Config   `yaml:"storage" toml:"storage"`
+	Postgres  postgres.Config  `yaml:"postgres" toml:"postgres"`
+	Collector collector.Config `yaml:"collector" toml:"collector"`
+	Log       zerologx.Config  `yaml:"log" toml:"log"`
+	Tracing   otelx.Config     `yaml:"tracing" toml:"tracing"`
+
+	// ShutdownTimeout limits the time to finish in-flight work on shutdown.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT"`
+	// FlushTimeout limits the time to write the queued forecasts on shutdown,
+	// it starts when the in-flight work is finished or ShutdownTimeout is over.
+	FlushTimeout time.Duration `yaml:"flush_timeout" toml:"flush_timeout" env:"SHUTDOWN_FLUSH_TIMEOUT"`
+}
+
+// Default returns the config with default values.
+func Default() Config {
+	return Config{
+		Telegram: telegram.Config{
+			ChatRateLimit: 20,
+			ChatRateBurst: 5,
+		},
+		Weather: weather.Config{
+			Timeout:          time.Second,
+			Workers:          4,
+			RateLimit:        60,
+			Retries:          2,
+			RetryDelay:       200 * time.Millisecond,
+			BreakerThreshold: 5,
+			BreakerTimeout:   30 * time.Second,
+		},
+		Archive: archive.Config{
+			Timeout: 5 * time.Second,
+		},
+		Storage: storage.Config{
+			Driver:        storage.DriverPostgres,
+			SQLitePath:    "tmpweather.db",
+			BatchSize:     100,
+			FlushInterval: time.Second,
+			WriteRetries:  3,
+			Retention: storage.RetentionConfig{
+				Age:      90 * 24 * time.Hour,
+				Interval: time.Hour,
+			},
+		},
+		Postgres: postgres.Config{
+			MaxConns:    5,
+			PingTimeout: 300 * time.Millisecond,
+		},
+		Collector: collector.Config{
+			Interval: time.Hour,
+		},
+		Log: zerologx.Config{
+			Level:        int8(zerolog.InfoLevel),
+			Format:       zerologx.FormatConsole,
+			SamplePeriod: time.Second,
+		},
+		ShutdownTimeout: 10 * time.Second,
+		FlushTimeout:    5 * time.Second,
+	}
+}
+
+// Load loads and validates the config.
+func Load() (Config, error) {
+	cfg, err := Parse()
+	if err != nil {
+		return Config{}, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// Parse loads the config without validation.
+func Parse() (Config, error) {
+	environ, err := environment(os.Environ())
+	if err != nil {
+		return Config{}, err
+	}
+
+	cfg := Default()
+	if path := environ[pathEnv]; len(path) != 0 {
+		if err := readFile(path, &cfg); err != nil {
+			return Config{}, err
+		}
+	}
+
+	if err := env.Parse(&cfg, env.Options{Environment: environ}); err != nil {
+		return Config{}, fmt.Errorf("parse env: %w", err)
+	}
+	return cfg, nil
+}
+
+// Validate checks the config values.
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, field, env, msg string) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s (%s): %s", field, env, msg))
+		}
+	}
+
+	check(len(c.Telegram.Token) != 0, "telegram.token", "TELEGRAM_BOT_TOKEN", "is required")
+	check(c.Telegram.ChatRateLimit >= 0, "telegram.chat_rate_limit", "TELEGRAM_CHAT_RATE_LIMIT", "must not be negative")
+	check(c.Telegram.ChatRateLimit == 0 || c.Telegram.ChatRateBurst > 0,
+		"telegram.chat_rate_burst", "TELEGRAM_CHAT_RATE_BURST", "must be positive")
+	check(len(c.Weather.APIToken) != 0, "weather.api_token", "OPENWEATHERMAP_API_TOKEN", "is required")
+	check(c.Weather.Timeout > 0, "weather.timeout", "OPENWEATHERMAP_TIMEOUT", "must be positive")
+	check(c.Weather.Workers > 0, "weather.workers", "OPENWEATHERMAP_WORKERS", "must be positive")
+	check(c.Weather.RateLimit >= 0, "weather.rate_limit", "OPENWEATHERMAP_RATE_LIMIT", "must not be negative")
+	check(c.Weather.Retries >= 0, "weather.retries", "OPENWEATHERMAP_RETRIES", "must not be negative")
+	check(c.Weather.Retries == 0 || c.Weather.RetryDelay > 0,
+		"weather.retry_delay", "OPENWEATHERMAP_RETRY_DELAY", "must be positive")
+	check(c.Weather.BreakerThreshold >= 0, "weather.breaker_threshold", "OPENWEATHERMAP_BREAKER_THRESHOLD", "must not be negative")
+	check(c.Weather.BreakerThreshold == 0 || c.Weather.BreakerTimeout > 0,
+		"weather.breaker_timeout", "OPENWEATHERMAP_BREAKER_TIMEOUT", "must be positive")
+	check(c.Archive.Timeout > 0, "archive.timeout", "ARCHIVE_TIMEOUT", "must be positive")
+	switch c.Storage.Driver {
+	case storage.DriverPostgres:
+		check(len(c.Postgres.URI) != 0, "postgres.uri", "POSTGRES_URI", "is required")
+		check(c.Postgres.MaxConns > 0, "postgres.max_conns", "POSTGRES_MAX_CONNS", "must be positive")
+		check(c.Postgres.PingTimeout > 0, "postgres.ping_timeout", "POSTGRES_PING_TIMEOUT", "must be positive")
+	case storage.DriverSQLite:
+		check(len(c.Storage.SQLitePath) != 0, "storage.sqlite_path", "SQLITE_PATH", "is required")
+	case storage.DriverMemory:
+	default:
+		check(false, "storage.driver", "STORAGE_DRIVER", "must be postgres, sqlite or memory")
+	}
+	check(c.Storage.BatchSize > 0, "storage.batch_size", "STORAGE_BATCH_SIZE", "must be positive")
+	check(c.Storage.FlushInterval > 0, "storage.flush_interval", "STORAGE_FLUSH_INTERVAL", "must be positive")
+	check(c.Storage.WriteRetries >= 0, "storage.write_retries", "STORAGE_WRITE_RETRIES", "must not be negative")
+	check(c.Storage.Retention.Age >= 0, "storage.retention.age", "RETENTION_AGE", "must not be negative")
+	check(c.Storage.Retention.Age == 0 || c.Storage.Retention.Interval > 0,
+		"storage.retention.interval", "RETENTION_INTERVAL", "must be positive")
+	if len(c.Collector.Cities) != 0 {
+		for _, city := range c.Collector.Cities {
+			check(len(strings.TrimSpace(city)) != 0, "collector.cities", "COLLECTOR_CITIES", "must not contain empty names")
+		}
+		check(c.Collector.Interval > 0, "collector.interval", "COLLECTOR_INTERVAL", "must be positive")
+	}
+	check(c.Log.Level >= int8(zerolog.TraceLevel) && c.Log.Level <= int8(zerolog.Disabled),
+		"log.level", "LOG_LEVEL", "must be in [-1, 7]")
+	check(c.Log.Format == zerologx.FormatConsole || c.Log.Format == zerologx.FormatJSON,
+		"log.format", "LOG_FORMAT", "must be console or json")
+	check(c.ShutdownTimeout > 0, "shutdown_timeout", "SHUTDOWN_TIMEOUT", "must be positive")
+	check(c.FlushTimeout > 0, "flush_timeout", "SHUTDOWN_FLUSH_TIMEOUT", "must be positive")
+
+	if len(errs) != 0 {
+		return fmt.Errorf("invalid config: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// readFile decodes the YAML or TOML config file into cfg.
+func readFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, cfg)
+	case ".toml":
+		err = toml.Unmarshal(data, cfg)
+	default:
+		return fmt.Errorf("unsupported config file format: %q", ext)
+	}
+	if err != nil {
+		return fmt.Errorf("decode config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// environment returns env parameters as a map, resolving X_FILE parameters
+// of the config to X.
+func environment(environ []string) (map[string]string, error) {
+	vars := make(map[string]string, len(environ))
+	for _, kv := range environ {
+		k, v, _ := strings.Cut(kv, "=")
+		vars[k] = v
+	}
+
+	for _, name := range envNames(reflect.TypeOf(Config{})) {
+		path := vars[name+fileSuffix]
+		if len(path) == 0 {
+			continue
+		}
+		if len(vars[name]) != 0 {
+			return nil, fmt.Errorf("both %s and %s are set", name, name+fileSuffix)
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", name+fileSuffix, err)
+		}
+		vars[name] = strings.TrimSpace(string(data))
+	}
+	return vars, nil
+}
+
+// envNames returns the env parameter names of the struct type fields.
+func envNames(t reflect.Type) []string {
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if name, _, _ := strings.Cut(f.Tag.Get("env"), ","); len(name) != 0 {
+			names = append(names, name)
+			continue
+		}
+		if f.Type.Kind() == reflect.Struct {
+			names = append(names, envNames(f.Type)...)
+		}
+	}
+	return names
+}
@@ -0,0 +1,174 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, data string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(data), 0o600))
+		return path
+	}
+
+	yamlPath := writeFile("config.yaml", `
+telegram:
+  token: yaml-token
+weather:
+  api_token: yaml-api-token
+  timeout: 3s
+postgres:
+  uri: postgres://yaml
+log:
+  format: json
+`)
+	tomlPath := writeFile("config.toml", `
+[telegram]
+token = "toml-token"
+[weather]
+api_token = "toml-api-token"
+[postgres]
+uri = "postgres://toml"
+max_conns = 10
+`)
+	secretPath := writeFile("token", "secret-token\n")
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		check   func(t *testing.T, cfg Config)
+		wantErr string
+	}{
+		{
+			name: "Env only",
+			env: map[string]string{
+				"TELEGRAM_BOT_TOKEN":       "env-token",
+				"OPENWEATHERMAP_API_TOKEN": "env-api-token",
+				"POSTGRES_URI":             "postgres://env",
+			},
+			check: func(t *testing.T, cfg Config) {
+				assert.Equal(t, "env-token", cfg.Telegram.Token)
+				assert.Equal(t, time.Second, cfg.Weather.Timeout)
+				assert.Equal(t, int32(5), cfg.Postgres.MaxConns)
+			},
+		},
+		{
+			name: "YAML file overridden by env",
+			env: map[string]string{
+				"CONFIG_PATH":  yamlPath,
+				"POSTGRES_URI": "postgres://env",
+			},
+			check: func(t *testing.T, cfg Config) {
+				assert.Equal(t, "yaml-token", cfg.Telegram.Token)
+				assert.Equal(t, 3*time.Second, cfg.Weather.Timeout)
+				assert.Equal(t, "postgres://env", cfg.Postgres.URI)
+				assert.Equal(t, "json", cfg.Log.Format)
+			},
+		},
+		{
+			name: "TOML file",
+			env: map[string]string{
+				"CONFIG_PATH": tomlPath,
+			},
+			check: func(t *testing.T, cfg Config) {
+				assert.Equal(t, "toml-token", cfg.Telegram.Token)
+				assert.Equal(t, int32(10), cfg.Postgres.MaxConns)
+			},
+		},
+		{
+			name: "Secret file",
+			env: map[string]string{
+				"CONFIG_PATH":             tomlPath,
+				"TELEGRAM_BOT_TOKEN_FILE": secretPath,
+			},
+			check: func(t *testing.T, cfg Config) {
+				assert.Equal(t, "secret-token", cfg.Telegram.Token)
+			},
+		},
+		{
+			name: "Secret file and env are both set",
+			env: map[string]string{
+				"TELEGRAM_BOT_TOKEN":      "env-token",
+				"TELEGRAM_BOT_TOKEN_FILE": secretPath,
+			},
+			wantErr: "both TELEGRAM_BOT_TOKEN and TELEGRAM_BOT_TOKEN_FILE are set",
+		},
+		{
+			name: "Memory storage without postgres",
+			env: map[string]string{
+				"TELEGRAM_BOT_TOKEN":       "env-token",
+				"OPENWEATHERMAP_API_TOKEN": "env-api-token",
+				"STORAGE_DRIVER":           "memory",
+			},
+			check: func(t *testing.T, cfg Config) {
+				assert.Equal(t, "memory", cfg.Storage.Driver)
+			},
+		},
+		{
+			name: "Collector watchlist",
+			env: map[string]string{
+				"TELEGRAM_BOT_TOKEN":       "env-token",
+				"OPENWEATHERMAP_API_TOKEN": "env-api-token",
+				"STORAGE_DRIVER":           "memory",
+				"COLLECTOR_CITIES":         "Berlin,Paris",
+			},
+			check: func(t *testing.T, cfg Config) {
+				assert.Equal(t, []string{"Berlin", "Paris"}, cfg.Collector.Cities)
+				assert.Equal(t, time.Hour, cfg.Collector.Interval)
+			},
+		},
+		{
+			name: "Unknown storage driver",
+			env: map[string]string{
+				"TELEGRAM_BOT_TOKEN":       "env-token",
+				"OPENWEATHERMAP_API_TOKEN": "env-api-token",
+				"STORAGE_DRIVER":           "mysql",
+			},
+			wantErr: "storage.driver (STORAGE_DRIVER): must be postgres, sqlite or memory",
+		},
+		{
+			name: "Missing required values",
+			env: map[string]string{
+				"LOG_FORMAT": "xml",
+			},
+			wantErr: "telegram.token (TELEGRAM_BOT_TOKEN): is required",
+		},
+		{
+			name: "Unsupported file format",
+			env: map[string]string{
+				"CONFIG_PATH": secretPath,
+			},
+			wantErr: "unsupported config file format",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, k := range []string{
+				"CONFIG_PATH", "TELEGRAM_BOT_TOKEN", "TELEGRAM_BOT_TOKEN_FILE",
+				"OPENWEATHERMAP_API_TOKEN", "POSTGRES_URI", "LOG_FORMAT", "STORAGE_DRIVER", "COLLECTOR_CITIES",
+			} {
+				t.Setenv(k, "")
+			}
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			cfg, err := Load()
+			if len(tt.wantErr) != 0 {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			tt.check(t, cfg)
+		})
+	}
+}
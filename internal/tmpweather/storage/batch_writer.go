@@ -0,0 +1,249 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alukart32/tmp-weather/internal/pkg/zerologx"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+var (
+	// ErrWriterClosed is returned by BatchWriter.Insert after Close.
+	ErrWriterClosed = errors.New("forecast writer is closed")
+	// ErrWriterFull is returned by BatchWriter.Insert when the queue is full, the forecast is dropped.
+	ErrWriterFull = errors.New("forecast writer queue is full")
+)
+
+var writerMetrics = newWriterMetrics()
+
+// writerMetricsSet holds the batch writer instruments.
+type writerMetricsSet struct {
+	dropped metric.Int64Counter
+}
+
+func newWriterMetrics() writerMetricsSet {
+	var (
+		m   writerMetricsSet
+		err error
+	)
+	m.dropped, err = meter.Int64Counter("tmpweather.forecasts.dropped",
+		metric.WithDescription("Forecasts dropped by the batch writer by reason"),
+		metric.WithUnit("{row}"))
+	if err != nil {
+		otel.Handle(err)
+	}
+	return m
+}
+
+// batchRetryDelay is the delay before the first retry of a batch write, doubled on each retry.
+const batchRetryDelay = 100 * time.Millisecond
+
+// BatchWriter is the forecast writer that writes forecasts asynchronously in batches.
+//
+// Insert validates the forecast and queues it without waiting for the database,
+// the forecast is dropped if the queue is full.
+// Queued forecasts are written when the batch is full or the flush interval elapses,
+// transient write errors are retried. The forecasts are read from the repository,
+// so a forecast is read after it is flushed.
+type BatchWriter struct {
+	repo BatchRepository
+
+	batchSize     int
+	flushInterval time.Duration
+	retries       int
+	retryDelay    time.Duration
+
+	mtx     sync.RWMutex
+	closed  bool
+	records chan WeatherForecast
+
+	ctx    context.Context // canceled if Close is timed out
+	cancel context.CancelFunc
+	done   chan struct{} // closed when the queued forecasts are flushed
+}
+
+// NewBatchWriter returns a new BatchWriter of the repository and starts flushing.
+func NewBatchWriter(repo BatchRepository, cfg Config) *BatchWriter {
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &BatchWriter{
+		repo:          repo,
+		batchSize:     cfg.BatchSize,
+		flushInterval: cfg.FlushInterval,
+		retries:       cfg.WriteRetries,
+		retryDelay:    batchRetryDelay,
+		// The queue holds the next batch while the current one is written.
+		records: make(chan WeatherForecast, 2*cfg.BatchSize),
+		ctx:     ctx,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+	go w.run()
+
+	return w
+}
+
+// Insert queues the weather forecast to be written. It does not wait for the queue:
+// if it is full, the forecast is dropped and ErrWriterFull is returned.
+func (w *BatchWriter) Insert(ctx context.Context, f WeatherForecast) error {
+	if err := validateForecast(f); err != nil {
+		return err
+	}
+
+	// The send does not block, so Close does not wait for the callers.
+	w.mtx.RLock()
+	closed, queued := w.closed, false
+	if !closed {
+		select {
+		case w.records <- f:
+			queued = true
+		default:
+		}
+	}
+	w.mtx.RUnlock()
+
+	switch {
+	case closed:
+		return ErrWriterClosed
+	case !queued:
+		writerMetrics.dropped.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", "full")))
+		return ErrWriterFull
+	}
+	return nil
+}
+
+// Close stops accepting forecasts and flushes the queued ones. If ctx is done before,
+// the writes are aborted, the remaining forecasts are dropped and ctx.Err() is returned.
+func (w *BatchWriter) Close(ctx context.Context) error {
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			w.cancel()
+		case <-stop:
+		}
+	}()
+
+	w.mtx.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.records)
+	}
+	w.mtx.Unlock()
+
+	<-w.done
+	if w.ctx.Err() != nil {
+		return ctx.Err()
+	}
+	w.cancel()
+	return nil
+}
+
+// run collects the queued forecasts into batches and flushes them until the queue is closed.
+func (w *BatchWriter) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]WeatherForecast, 0, w.batchSize)
+	for {
+		select {
+		case f, ok := <-w.records:
+			if !ok {
+				w.flush(batch)
+				return
+			}
+			batch = append(batch, f)
+			if len(batch) >= w.batchSize {
+				w.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			w.flush(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+// flush writes the batch, retrying transient errors with exponential backoff.
+func (w *BatchWriter) flush(batch []WeatherForecast) {
+	if len(batch) == 0 {
+		return
+	}
+	logger := zerologx.Get()
+
+	delay := w.retryDelay
+	for attempt := 0; ; attempt++ {
+		err := w.repo.InsertBatch(w.ctx, batch)
+		if err == nil {
+			logger.Debug().
+				Str("op", "flush forecasts").
+				Int("count", len(batch)).Send()
+			return
+		}
+		if attempt >= w.retries || !isTransient(err) || w.ctx.Err() != nil {
+			logger.Error().
+				Str("op", "flush forecasts").
+				Int("dropped", len(batch)).
+				Err(err).Send()
+			writerMetrics.dropped.Add(context.Background(), int64(len(batch)),
+				metric.WithAttributes(attribute.String("reason", "write_error")))
+			return
+		}
+		logger.Warn().
+			Str("op", "flush forecasts").
+			Int("attempt", attempt+1).
+			Err(err).Msg("retry")
+
+		select {
+		case <-time.After(delay):
+		case <-w.ctx.Done():
+		}
+		delay *= 2
+	}
+}
+
+// isTransient reports whether the write error is temporary, so the write can be retried.
+func isTransient(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch {
+		case strings.HasPrefix(pgErr.Code, "08"): // connection exception
+			return true
+		case strings.HasPrefix(pgErr.Code, "53"): // insufficient resources
+			return true
+		case pgErr.Code == "40001", // serialization failure
+			pgErr.Code == "40P01", // deadlock detected
+			pgErr.Code == "57P01": // admin shutdown
+			return true
+		}
+		return false
+	}
+
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		code := sqliteErr.Code() & 0xff // primary result code
+		return code == sqlite3.SQLITE_BUSY || code == sqlite3.SQLITE_LOCKED
+	}
+
+	if pgconn.SafeToRetry(err) || pgconn.Timeout(err) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
@@ -0,0 +1,198 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyRepo is the in-memory repository that fails batch writes with the given errors.
+type flakyRepo struct {
+	*MemoryRepo
+
+	mtx     sync.Mutex
+	errs    []error // returned by the next writes
+	batches []int   // sizes of the written batches
+}
+
+func (r *flakyRepo) InsertBatch(ctx context.Context, fs []WeatherForecast) error {
+	r.mtx.Lock()
+	if len(r.errs) != 0 {
+		err := r.errs[0]
+		r.errs = r.errs[1:]
+		r.mtx.Unlock()
+		return err
+	}
+	r.batches = append(r.batches, len(fs))
+	r.mtx.Unlock()
+
+	return r.MemoryRepo.InsertBatch(ctx, fs)
+}
+
+func (r *flakyRepo) written() []int {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return append([]int(nil), r.batches...)
+}
+
+func TestBatchWriter(t *testing.T) {
+	forecast := WeatherForecast{
+		ChatID: 1,
+		City:   "A",
+		Desc:   "casual weather",
+		Temp:   1.0,
+		Hum:    2,
+		Wind:   3.0,
+		MadeAt: time.Now(),
+	}
+	transientErr := &pgconn.PgError{Code: "40001"}
+
+	tests := []struct {
+		name          string
+		cfg           Config
+		errs          []error
+		inserts       int
+		wantBatches   []int
+		wantForecasts int
+		beforeClose   bool // the batches are written before Close
+	}{
+		{
+			name:          "Flush on batch size",
+			cfg:           Config{BatchSize: 2, FlushInterval: time.Hour},
+			inserts:       4,
+			wantBatches:   []int{2, 2},
+			wantForecasts: 4,
+			beforeClose:   true,
+		},
+		{
+			name:          "Flush on interval",
+			cfg:           Config{BatchSize: 100, FlushInterval: 10 * time.Millisecond},
+			inserts:       3,
+			wantBatches:   []int{3},
+			wantForecasts: 3,
+			beforeClose:   true,
+		},
+		{
+			name:          "Flush on close",
+			cfg:           Config{BatchSize: 100, FlushInterval: time.Hour},
+			inserts:       3,
+			wantBatches:   []int{3},
+			wantForecasts: 3,
+		},
+		{
+			name:          "Retry transient error",
+			cfg:           Config{BatchSize: 100, FlushInterval: time.Hour, WriteRetries: 2},
+			errs:          []error{transientErr, transientErr},
+			inserts:       3,
+			wantBatches:   []int{3},
+			wantForecasts: 3,
+		},
+		{
+			name:    "Drop batch after retries",
+			cfg:     Config{BatchSize: 100, FlushInterval: time.Hour, WriteRetries: 1},
+			errs:    []error{transientErr, transientErr},
+			inserts: 3,
+		},
+		{
+			name:    "Drop batch with permanent error",
+			cfg:     Config{BatchSize: 100, FlushInterval: time.Hour, WriteRetries: 3},
+			errs:    []error{errors.New("permanent")},
+			inserts: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &flakyRepo{MemoryRepo: NewMemoryRepo(), errs: tt.errs}
+			w := NewBatchWriter(repo, tt.cfg)
+			w.retryDelay = time.Millisecond
+
+			for i := 0; i < tt.inserts; i++ {
+				require.NoError(t, w.Insert(context.TODO(), forecast))
+			}
+
+			if tt.beforeClose {
+				assert.Eventually(t, func() bool {
+					return assert.ObjectsAreEqual(tt.wantBatches, repo.written())
+				}, time.Second, time.Millisecond)
+			}
+
+			require.NoError(t, w.Close(context.TODO()))
+			if !tt.beforeClose {
+				assert.Equal(t, tt.wantBatches, repo.written())
+			}
+
+			page, err := repo.History(context.TODO(), HistoryQuery{ChatID: 1, Limit: 10})
+			if tt.wantForecasts == 0 {
+				assert.ErrorIs(t, err, ErrNoData)
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, page.Forecasts, tt.wantForecasts)
+		})
+	}
+}
+
+func TestBatchWriter_Insert(t *testing.T) {
+	w := NewBatchWriter(NewMemoryRepo(), Config{BatchSize: 1, FlushInterval: time.Hour})
+
+	err := w.Insert(context.TODO(), WeatherForecast{Hum: 2, Wind: 3.0})
+	assert.Error(t, err, "invalid forecast is rejected")
+
+	require.NoError(t, w.Close(context.TODO()))
+
+	err = w.Insert(context.TODO(), WeatherForecast{City: "A", Hum: 2, Wind: 3.0})
+	assert.ErrorIs(t, err, ErrWriterClosed)
+}
+
+func TestBatchWriter_InsertFull(t *testing.T) {
+	repo := &flakyRepo{MemoryRepo: NewMemoryRepo()}
+	for i := 0; i < 100; i++ {
+		repo.errs = append(repo.errs, &pgconn.PgError{Code: "40001"})
+	}
+	w := NewBatchWriter(repo, Config{BatchSize: 1, FlushInterval: time.Hour, WriteRetries: 100})
+	w.retryDelay = time.Hour
+
+	// The first forecast is being written, the next 2 fill the queue.
+	f := WeatherForecast{City: "A", Hum: 2, Wind: 3.0}
+	require.NoError(t, w.Insert(context.TODO(), f))
+	require.Eventually(t, func() bool { return len(w.records) == 0 }, time.Second, time.Millisecond)
+	for i := 0; i < 2; i++ {
+		require.NoError(t, w.Insert(context.TODO(), f))
+	}
+
+	done := make(chan error)
+	go func() { done <- w.Insert(context.TODO(), f) }()
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, ErrWriterFull)
+	case <-time.After(time.Second):
+		t.Fatal("insert is blocked by the full queue")
+	}
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, w.Close(ctx), context.DeadlineExceeded)
+}
+
+func TestBatchWriter_CloseTimeout(t *testing.T) {
+	repo := &flakyRepo{MemoryRepo: NewMemoryRepo()}
+	for i := 0; i < 100; i++ {
+		repo.errs = append(repo.errs, &pgconn.PgError{Code: "40001"})
+	}
+	w := NewBatchWriter(repo, Config{BatchSize: 100, FlushInterval: time.Hour, WriteRetries: 100})
+	w.retryDelay = time.Hour
+
+	require.NoError(t, w.Insert(context.TODO(), WeatherForecast{City: "A", Hum: 2, Wind: 3.0}))
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, w.Close(ctx), context.DeadlineExceeded)
+	assert.Empty(t, repo.written())
+}
@@ -0,0 +1,298 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/alukart32/tmp-weather/internal/pkg/otelx"
+	"github.com/alukart32/tmp-weather/internal/pkg/zerologx"
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ChatRepository defines the repository of the chat preferences.
+type ChatRepository interface {
+	// AddFavorite adds the city to the chat favorites, an already added city is skipped.
+	AddFavorite(ctx context.Context, chatID int64, city string) error
+	// RemoveFavorite removes the city from the chat favorites.
+	// ErrNoData is returned if the city is not a favorite one.
+	RemoveFavorite(ctx context.Context, chatID int64, city string) error
+	// Favorites returns the chat favorite cities in the order they were added.
+	Favorites(ctx context.Context, chatID int64) ([]string, error)
+	// ChatSettings returns the chat settings, the zero settings if they are not saved.
+	ChatSettings(ctx context.Context, chatID int64) (ChatSettings, error)
+	// SaveChatSettings adds or replaces the chat settings.
+	SaveChatSettings(ctx context.Context, s ChatSettings) error
+	// GroupSettings returns the group chat settings, the zero settings if they are not saved.
+	GroupSettings(ctx context.Context, chatID int64) (GroupSettings, error)
+	// SaveGroupSettings adds or replaces the group chat settings.
+	SaveGroupSettings(ctx context.Context, s GroupSettings) error
+	// ClaimDigests returns the group and private chat digests due at now and marks them as sent
+	// on the now UTC date, so a digest is claimed once a day. The digest is due since its UTC time
+	// if the home city is set.
+	ClaimDigests(ctx context.Context, now time.Time) ([]Digest, error)
+}
+
+// ChatSettings represents the chat settings.
+type ChatSettings struct {
+	ChatID     int64
+	HomeCity   string // optional default city of the chat
+	DigestTime string // optional UTC time of the daily digest, 15:04
+	DigestAir  bool   // the digest includes the air quality
+}
+
+// GroupSettings represents the group chat settings set by the group admins.
+type GroupSettings struct {
+	ChatID     int64
+	HomeCity   string   // optional default city of the group
+	DigestTime string   // optional UTC time of the daily digest, 15:04
+	DigestAir  bool     // the digest includes the air quality
+	Commands   []string // allowed commands, all if empty
+}
+
+// Digest represents the daily digest due to the chat.
+type Digest struct {
+	ChatID   int64
+	HomeCity string
+	Air      bool // the digest includes the air quality
+}
+
+// digestDue returns the digest time and the date of now to claim the due digests.
+func digestDue(now time.Time) (clock, date string) {
+	now = now.UTC()
+	return now.Format("15:04"), now.Format(time.DateOnly)
+}
+
+var (
+	_ ChatRepository = (*WeatherForecastRepo)(nil)
+	_ ChatRepository = (*SQLiteRepo)(nil)
+	_ ChatRepository = (*MemoryRepo)(nil)
+)
+
+// errEmptyCity is returned when the chat preference city is empty.
+var errEmptyCity = errors.New("empty city")
+
+const insertFavorite = `
+INSERT INTO
+	favorites(chat_id, city)
+VALUES
+	($1, $2)
+ON CONFLICT DO NOTHING
+`
+
+// AddFavorite adds the city to the chat favorites, an already added city is skipped.
+func (r *WeatherForecastRepo) AddFavorite(ctx context.Context, chatID int64, city string) (err error) {
+	ctx, span := tracer.Start(ctx, "WeatherForecastRepo.AddFavorite")
+	span.SetAttributes(
+		attribute.Int64("chat.id", chatID),
+		attribute.String("city", city),
+	)
+	defer func() { otelx.End(span, err) }()
+
+	if len(city) == 0 {
+		return errEmptyCity
+	}
+	_, err = r.pool.Exec(ctx, insertFavorite, chatID, city)
+	return err
+}
+
+const deleteFavorite = `
+DELETE FROM
+	favorites
+WHERE
+	chat_id = $1
+	AND LOWER(city) = LOWER($2)
+`
+
+// RemoveFavorite removes the city from the chat favorites.
+// ErrNoData is returned if the city is not a favorite one.
+func (r *WeatherForecastRepo) RemoveFavorite(ctx context.Context, chatID int64, city string) (err error) {
+	ctx, span := tracer.Start(ctx, "WeatherForecastRepo.RemoveFavorite")
+	span.SetAttributes(
+		attribute.Int64("chat.id", chatID),
+		attribute.String("city", city),
+	)
+	defer func() { otelx.End(span, err) }()
+
+	tag, err := r.pool.Exec(ctx, deleteFavorite, chatID, city)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNoData
+	}
+	return nil
+}
+
+const getFavorites = `
+SELECT
+	city
+FROM
+	favorites
+WHERE
+	chat_id = $1
+ORDER BY
+	added_at ASC, city ASC
+`
+
+// Favorites returns the chat favorite cities in the order they were added.
+func (r *WeatherForecastRepo) Favorites(ctx context.Context, chatID int64) (cities []string, err error) {
+	ctx, span := tracer.Start(ctx, "WeatherForecastRepo.Favorites")
+	span.SetAttributes(attribute.Int64("chat.id", chatID))
+	defer func() { otelx.End(span, err) }()
+
+	logger := zerologx.Ctx(ctx)
+	logger.Debug().
+		Str("op", "get favorites").Send()
+
+	rows, err := r.pool.Query(ctx, getFavorites, chatID)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
+
+const getChatSettings = `
+SELECT
+	home_city, digest_time, digest_air
+FROM
+	chat_settings
+WHERE
+	chat_id = $1
+`
+
+// ChatSettings returns the chat settings, the zero settings if they are not saved.
+func (r *WeatherForecastRepo) ChatSettings(ctx context.Context, chatID int64) (s ChatSettings, err error) {
+	ctx, span := tracer.Start(ctx, "WeatherForecastRepo.ChatSettings")
+	span.SetAttributes(attribute.Int64("chat.id", chatID))
+	defer func() { otelx.End(span, err) }()
+
+	s.ChatID = chatID
+	err = r.pool.QueryRow(ctx, getChatSettings, chatID).Scan(&s.HomeCity, &s.DigestTime, &s.DigestAir)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return s, nil
+	}
+	return s, err
+}
+
+const upsertChatSettings = `
+INSERT INTO
+	chat_settings(chat_id, home_city, digest_time, digest_air)
+VALUES
+	($1, $2, $3, $4)
+ON CONFLICT (chat_id) DO UPDATE SET
+	home_city = EXCLUDED.home_city,
+	digest_time = EXCLUDED.digest_time,
+	digest_air = EXCLUDED.digest_air,
+	updated_at = now()
+`
+
+// SaveChatSettings adds or replaces the chat settings.
+func (r *WeatherForecastRepo) SaveChatSettings(ctx context.Context, s ChatSettings) (err error) {
+	ctx, span := tracer.Start(ctx, "WeatherForecastRepo.SaveChatSettings")
+	span.SetAttributes(attribute.Int64("chat.id", s.ChatID))
+	defer func() { otelx.End(span, err) }()
+
+	_, err = r.pool.Exec(ctx, upsertChatSettings, s.ChatID, s.HomeCity, s.DigestTime, s.DigestAir)
+	return err
+}
+
+const getGroupSettings = `
+SELECT
+	home_city, digest_time, digest_air, commands
+FROM
+	group_settings
+WHERE
+	chat_id = $1
+`
+
+// GroupSettings returns the group chat settings, the zero settings if they are not saved.
+func (r *WeatherForecastRepo) GroupSettings(ctx context.Context, chatID int64) (s GroupSettings, err error) {
+	ctx, span := tracer.Start(ctx, "WeatherForecastRepo.GroupSettings")
+	span.SetAttributes(attribute.Int64("chat.id", chatID))
+	defer func() { otelx.End(span, err) }()
+
+	s.ChatID = chatID
+	err = r.pool.QueryRow(ctx, getGroupSettings, chatID).Scan(&s.HomeCity, &s.DigestTime, &s.DigestAir, &s.Commands)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return s, nil
+	}
+	if len(s.Commands) == 0 {
+		s.Commands = nil
+	}
+	return s, err
+}
+
+const upsertGroupSettings = `
+INSERT INTO
+	group_settings(chat_id, home_city, digest_time, digest_air, commands)
+VALUES
+	($1, $2, $3, $4, $5)
+ON CONFLICT (chat_id) DO UPDATE SET
+	home_city = EXCLUDED.home_city,
+	digest_time = EXCLUDED.digest_time,
+	digest_air = EXCLUDED.digest_air,
+	commands = EXCLUDED.commands,
+	updated_at = now()
+`
+
+// SaveGroupSettings adds or replaces the group chat settings.
+func (r *WeatherForecastRepo) SaveGroupSettings(ctx context.Context, s GroupSettings) (err error) {
+	ctx, span := tracer.Start(ctx, "WeatherForecastRepo.SaveGroupSettings")
+	span.SetAttributes(attribute.Int64("chat.id", s.ChatID))
+	defer func() { otelx.End(span, err) }()
+
+	commands := s.Commands
+	if commands == nil {
+		commands = []string{}
+	}
+	_, err = r.pool.Exec(ctx, upsertGroupSettings, s.ChatID, s.HomeCity, s.DigestTime, s.DigestAir, commands)
+	return err
+}
+
+const claimDigests = `
+WITH groups AS (
+  UPDATE
+    group_settings
+  SET
+    digest_sent_on = $2::date
+  WHERE
+    digest_time <> ''
+    AND digest_time <= $1
+    AND home_city <> ''
+    AND (digest_sent_on IS NULL OR digest_sent_on < $2::date)
+  RETURNING
+    chat_id, home_city, digest_air
+), chats AS (
+  UPDATE
+    chat_settings
+  SET
+    digest_sent_on = $2::date
+  WHERE
+    digest_time <> ''
+    AND digest_time <= $1
+    AND home_city <> ''
+    AND (digest_sent_on IS NULL OR digest_sent_on < $2::date)
+  RETURNING
+    chat_id, home_city, digest_air
+)
+SELECT chat_id, home_city, digest_air FROM groups
+UNION ALL
+SELECT chat_id, home_city, digest_air FROM chats
+`
+
+// ClaimDigests returns the group and private chat digests due at now and marks them as sent
+// on the now UTC date, so a digest is claimed once a day. The digest is due since its UTC time
+// if the home city is set.
+func (r *WeatherForecastRepo) ClaimDigests(ctx context.Context, now time.Time) (digests []Digest, err error) {
+	ctx, span := tracer.Start(ctx, "WeatherForecastRepo.ClaimDigests")
+	defer func() { otelx.End(span, err) }()
+
+	clock, date := digestDue(now)
+	rows, err := r.pool.Query(ctx, claimDigests, clock, date)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowToStructByPos[Digest])
+}
@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/alukart32/tmp-weather/internal/pkg/otelx"
+	"github.com/alukart32/tmp-weather/internal/pkg/zerologx"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ExportQuery defines the exported chat forecasts.
+type ExportQuery struct {
+	ChatID int64
+	Since  time.Time // optional start of the time window
+}
+
+const exportForecasts = `
+SELECT
+	` + forecastSelectColumns + `
+FROM
+	forecasts
+WHERE
+	chat_id = $1
+	AND ($2::timestamptz IS NULL OR made_at >= $2)
+ORDER BY
+	id ASC
+`
+
+// ExportForecasts calls fn for each chat forecast oldest-first as the rows are read.
+// It stops at the first fn error and returns it.
+func (r *WeatherForecastRepo) ExportForecasts(
+	ctx context.Context,
+	q ExportQuery,
+	fn func(WeatherForecast) error,
+) (err error) {
+	ctx, span := tracer.Start(ctx, "WeatherForecastRepo.ExportForecasts")
+	span.SetAttributes(attribute.Int64("chat.id", q.ChatID))
+	defer func() { otelx.End(span, err) }()
+
+	logger := zerologx.Ctx(ctx)
+	logger.Debug().
+		Str("op", "export forecasts").
+		Time("since", q.Since).Send()
+
+	rows, err := r.pool.Query(ctx, exportForecasts, q.ChatID, nullTime(q.Since))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var f WeatherForecast
+		err = rows.Scan(forecastScanFields(&f, &f.MadeAt)...)
+		if err != nil {
+			return err
+		}
+		if err = fn(f); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,191 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alukart32/tmp-weather/internal/pkg/otelx"
+	"github.com/alukart32/tmp-weather/internal/pkg/zerologx"
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// HistoryQuery defines the page of the chat forecast history.
+//
+// The history is keyset-paginated by the forecast ID. If Before is set, the page
+// contains the forecasts older than Before. If After is set, the page contains
+// the forecasts newer than After. Otherwise, it contains the newest forecasts.
+type HistoryQuery struct {
+	ChatID int64
+	City   string // optional city filter
+	Before int64
+	After  int64
+	Limit  int
+}
+
+// HistoryPage represents the page of the chat forecast history ordered newest-first.
+type HistoryPage struct {
+	Forecasts []WeatherForecast
+	HasNewer  bool
+	HasOlder  bool
+}
+
+// ToMsg converts the HistoryPage to the msg format of the telegram bot.
+func (p HistoryPage) ToMsg() string {
+	var sb strings.Builder
+
+	for _, f := range p.Forecasts {
+		fmt.Fprintf(&sb, "%v\n", f.MadeAt.Format(time.RFC822))
+		fmt.Fprintf(&sb, "\t\t%v: %.2f C, %v\n", f.City, f.Temp, f.Desc)
+	}
+
+	return sb.String()
+}
+
+const getWeatherForecastHistoryOlder = `
+SELECT
+	` + forecastSelectColumns + `
+FROM
+	forecasts
+WHERE
+	chat_id = $1
+	AND ($2::text = '' OR LOWER(city) = LOWER($2))
+	AND ($3::bigint = 0 OR id < $3)
+ORDER BY
+	id DESC
+LIMIT $4
+`
+
+const getWeatherForecastHistoryNewer = `
+SELECT
+	` + forecastSelectColumns + `
+FROM
+	forecasts
+WHERE
+	chat_id = $1
+	AND ($2::text = '' OR LOWER(city) = LOWER($2))
+	AND id > $3
+ORDER BY
+	id ASC
+LIMIT $4
+`
+
+// History returns the page of the chat forecast history.
+func (r *WeatherForecastRepo) History(ctx context.Context, q HistoryQuery) (page HistoryPage, err error) {
+	ctx, span := tracer.Start(ctx, "WeatherForecastRepo.History")
+	span.SetAttributes(
+		attribute.Int64("chat.id", q.ChatID),
+		attribute.String("city", q.City),
+	)
+	defer func() { otelx.End(span, err) }()
+
+	logger := zerologx.Ctx(ctx)
+	logger.Debug().
+		Str("op", "forecast history").
+		Str("city", q.City).
+		Int64("before", q.Before).
+		Int64("after", q.After).Send()
+
+	if q.Limit <= 0 {
+		return HistoryPage{}, fmt.Errorf("invalid history limit: %d", q.Limit)
+	}
+
+	tx, err := r.pool.BeginTx(ctx, pgx.TxOptions{
+		IsoLevel:       pgx.ReadCommitted,
+		AccessMode:     pgx.ReadOnly,
+		DeferrableMode: pgx.NotDeferrable,
+	})
+	if err != nil {
+		return HistoryPage{}, fmt.Errorf("unable to start transaction: %v", err.Error())
+	}
+
+	defer func() {
+		err = r.finishTransaction(ctx, tx, err)
+	}()
+
+	// Query one more record to know if there is a next page.
+	query, cursor := getWeatherForecastHistoryOlder, q.Before
+	if q.After > 0 {
+		query, cursor = getWeatherForecastHistoryNewer, q.After
+	}
+	rows, err := tx.Query(ctx, query, q.ChatID, q.City, cursor, q.Limit+1)
+	if err != nil {
+		return HistoryPage{}, err
+	}
+
+	forecasts, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (WeatherForecast, error) {
+		var f WeatherForecast
+		err := row.Scan(forecastScanFields(&f, &f.MadeAt)...)
+		return f, err
+	})
+	if err != nil {
+		return HistoryPage{}, err
+	}
+
+	return newHistoryPage(q, forecasts)
+}
+
+// newHistoryPage returns the history page of the forecasts selected by the query
+// with the limit increased by one.
+func newHistoryPage(q HistoryQuery, forecasts []WeatherForecast) (HistoryPage, error) {
+	hasMore := len(forecasts) > q.Limit
+	if hasMore {
+		forecasts = forecasts[:q.Limit]
+	}
+
+	var page HistoryPage
+	if q.After > 0 {
+		// Newer forecasts are selected oldest-first.
+		for i, j := 0, len(forecasts)-1; i < j; i, j = i+1, j-1 {
+			forecasts[i], forecasts[j] = forecasts[j], forecasts[i]
+		}
+		page = HistoryPage{Forecasts: forecasts, HasNewer: hasMore, HasOlder: true}
+	} else {
+		page = HistoryPage{Forecasts: forecasts, HasNewer: q.Before > 0, HasOlder: hasMore}
+	}
+
+	if len(page.Forecasts) == 0 {
+		return page, ErrNoData
+	}
+	return page, nil
+}
+
+const getCityForecasts = `
+SELECT
+	` + forecastSelectColumns + `
+FROM
+	forecasts
+WHERE
+	LOWER(city) = LOWER($1)
+	AND ($2::timestamptz IS NULL OR made_at >= $2)
+	AND ($3::timestamptz IS NULL OR made_at < $3)
+ORDER BY
+	made_at ASC
+`
+
+// CityForecasts returns the forecasts of the city ordered by time.
+func (r *WeatherForecastRepo) CityForecasts(ctx context.Context, q CityQuery) (forecasts []WeatherForecast, err error) {
+	ctx, span := tracer.Start(ctx, "WeatherForecastRepo.CityForecasts")
+	span.SetAttributes(attribute.String("city", q.City))
+	defer func() { otelx.End(span, err) }()
+
+	rows, err := r.pool.Query(ctx, getCityForecasts, q.City, nullTime(q.Since), nullTime(q.Until))
+	if err != nil {
+		return nil, err
+	}
+
+	forecasts, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (WeatherForecast, error) {
+		var f WeatherForecast
+		err := row.Scan(forecastScanFields(&f, &f.MadeAt)...)
+		return f, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(forecasts) == 0 {
+		return nil, ErrNoData
+	}
+	return forecasts, nil
+}
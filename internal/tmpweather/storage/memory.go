@@ -0,0 +1,429 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryRepo defines the in-memory forecast repository. The data is lost on restart.
+type MemoryRepo struct {
+	mtx          sync.RWMutex
+	forecasts    []WeatherForecast // ordered by ID
+	lastID       int64
+	observations []Observation
+	favorites    map[int64][]string // chat favorites in the order they were added
+	settings     map[int64]ChatSettings
+	groups       map[int64]GroupSettings
+	digestsSent  map[int64]string // the date the chat digest was sent on, YYYY-MM-DD
+}
+
+// NewMemoryRepo returns a new MemoryRepo.
+func NewMemoryRepo() *MemoryRepo {
+	return &MemoryRepo{
+		favorites:   make(map[int64][]string),
+		settings:    make(map[int64]ChatSettings),
+		groups:      make(map[int64]GroupSettings),
+		digestsSent: make(map[int64]string),
+	}
+}
+
+// Insert adds a new weather forecast data.
+func (r *MemoryRepo) Insert(ctx context.Context, f WeatherForecast) error {
+	if err := validateForecast(f); err != nil {
+		return err
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.lastID++
+	f.ID = r.lastID
+	r.forecasts = append(r.forecasts, f)
+
+	return nil
+}
+
+// InsertBatch adds the weather forecasts at once.
+func (r *MemoryRepo) InsertBatch(ctx context.Context, fs []WeatherForecast) error {
+	for _, f := range fs {
+		if err := validateForecast(f); err != nil {
+			return err
+		}
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	for _, f := range fs {
+		r.lastID++
+		f.ID = r.lastID
+		r.forecasts = append(r.forecasts, f)
+	}
+
+	return nil
+}
+
+// validateForecast checks the forecast like the postgres table constraints.
+func validateForecast(f WeatherForecast) error {
+	var errs []error
+	if len(f.City) == 0 {
+		errs = append(errs, errors.New("empty city"))
+	}
+	if f.Hum <= 0 {
+		errs = append(errs, fmt.Errorf("non-positive hum: %d", f.Hum))
+	}
+	if f.Wind <= 0 {
+		errs = append(errs, fmt.Errorf("non-positive wind: %v", f.Wind))
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("invalid forecast: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// Stat returns the weather forecast statistics.
+func (r *MemoryRepo) Stat(ctx context.Context, q StatQuery) (WeatherForecastStat, error) {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	type cityAcc struct {
+		CityStat
+		key                      string
+		sumTemp, sumHum, sumWind float64
+	}
+
+	stat := WeatherForecastStat{since: q.Since}
+	cities := make(map[string]*cityAcc)
+	for _, f := range r.forecasts {
+		if !q.Since.IsZero() && f.MadeAt.Before(q.Since) {
+			continue
+		}
+		if len(q.City) != 0 && !strings.EqualFold(f.City, q.City) {
+			continue
+		}
+
+		top := &stat.TopRecords
+		if stat.total == 0 || f.MadeAt.Before(stat.firstRecordAt) {
+			stat.firstRecordAt = f.MadeAt
+		}
+		if stat.total == 0 || f.Temp > top.maxTemp {
+			top.maxTempCity, top.maxTemp = f.City, f.Temp
+		}
+		if stat.total == 0 || f.Hum > top.maxHum {
+			top.maxHumCity, top.maxHum = f.City, f.Hum
+		}
+		if stat.total == 0 || f.Wind > top.maxWind {
+			top.maxWindCity, top.maxWind = f.City, f.Wind
+		}
+		stat.total++
+
+		key := strings.ToLower(f.City)
+		c, ok := cities[key]
+		if !ok {
+			c = &cityAcc{
+				CityStat: CityStat{
+					city:    f.City,
+					minTemp: f.Temp,
+					maxTemp: f.Temp,
+					minHum:  f.Hum,
+					maxHum:  f.Hum,
+					minWind: f.Wind,
+					maxWind: f.Wind,
+				},
+				key: key,
+			}
+			cities[key] = c
+		}
+		if f.City < c.city {
+			c.city = f.City
+		}
+		c.count++
+		c.minTemp, c.maxTemp = math.Min(c.minTemp, f.Temp), math.Max(c.maxTemp, f.Temp)
+		if f.Hum < c.minHum {
+			c.minHum = f.Hum
+		}
+		if f.Hum > c.maxHum {
+			c.maxHum = f.Hum
+		}
+		c.minWind, c.maxWind = math.Min(c.minWind, f.Wind), math.Max(c.maxWind, f.Wind)
+		c.sumTemp += f.Temp
+		c.sumHum += float64(f.Hum)
+		c.sumWind += f.Wind
+	}
+	if stat.total == 0 {
+		return WeatherForecastStat{}, ErrNoData
+	}
+	stat.TopRecords.maxTemp = round2(stat.TopRecords.maxTemp)
+	stat.TopRecords.maxWind = round2(stat.TopRecords.maxWind)
+
+	accs := make([]*cityAcc, 0, len(cities))
+	for _, c := range cities {
+		accs = append(accs, c)
+	}
+	sort.Slice(accs, func(i, j int) bool {
+		if accs[i].count != accs[j].count {
+			return accs[i].count > accs[j].count
+		}
+		return accs[i].key < accs[j].key
+	})
+	if len(accs) > statTopCities {
+		accs = accs[:statTopCities]
+	}
+
+	for _, c := range accs {
+		n := float64(c.count)
+		c.minTemp, c.avgTemp, c.maxTemp = round2(c.minTemp), round2(c.sumTemp/n), round2(c.maxTemp)
+		c.avgHum = round2(c.sumHum / n)
+		c.minWind, c.avgWind, c.maxWind = round2(c.minWind), round2(c.sumWind/n), round2(c.maxWind)
+		stat.cities = append(stat.cities, c.CityStat)
+	}
+
+	return stat, nil
+}
+
+// History returns the page of the chat forecast history.
+func (r *MemoryRepo) History(ctx context.Context, q HistoryQuery) (HistoryPage, error) {
+	if q.Limit <= 0 {
+		return HistoryPage{}, fmt.Errorf("invalid history limit: %d", q.Limit)
+	}
+
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	match := func(f WeatherForecast) bool {
+		return f.ChatID == q.ChatID && (len(q.City) == 0 || strings.EqualFold(f.City, q.City))
+	}
+
+	// Select one more record to know if there is a next page.
+	var forecasts []WeatherForecast
+	if q.After > 0 {
+		for i := 0; i < len(r.forecasts) && len(forecasts) <= q.Limit; i++ {
+			if f := r.forecasts[i]; f.ID > q.After && match(f) {
+				forecasts = append(forecasts, f)
+			}
+		}
+	} else {
+		for i := len(r.forecasts) - 1; i >= 0 && len(forecasts) <= q.Limit; i-- {
+			if f := r.forecasts[i]; (q.Before == 0 || f.ID < q.Before) && match(f) {
+				forecasts = append(forecasts, f)
+			}
+		}
+	}
+
+	return newHistoryPage(q, forecasts)
+}
+
+// CityForecasts returns the forecasts of the city ordered by time.
+func (r *MemoryRepo) CityForecasts(ctx context.Context, q CityQuery) ([]WeatherForecast, error) {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	var forecasts []WeatherForecast
+	for _, f := range r.forecasts {
+		if strings.EqualFold(f.City, q.City) && inWindow(f.MadeAt, q) {
+			forecasts = append(forecasts, f)
+		}
+	}
+	if len(forecasts) == 0 {
+		return nil, ErrNoData
+	}
+
+	sort.SliceStable(forecasts, func(i, j int) bool {
+		return forecasts[i].MadeAt.Before(forecasts[j].MadeAt)
+	})
+	return forecasts, nil
+}
+
+// ExportForecasts calls fn for each chat forecast oldest-first.
+// It stops at the first fn error and returns it.
+func (r *MemoryRepo) ExportForecasts(ctx context.Context, q ExportQuery, fn func(WeatherForecast) error) error {
+	r.mtx.RLock()
+	var forecasts []WeatherForecast
+	for _, f := range r.forecasts {
+		if f.ChatID == q.ChatID && (q.Since.IsZero() || !f.MadeAt.Before(q.Since)) {
+			forecasts = append(forecasts, f)
+		}
+	}
+	r.mtx.RUnlock()
+
+	for _, f := range forecasts {
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// inWindow reports whether t is in the time window of the query.
+func inWindow(t time.Time, q CityQuery) bool {
+	return (q.Since.IsZero() || !t.Before(q.Since)) && (q.Until.IsZero() || t.Before(q.Until))
+}
+
+// InsertObservations adds the observations, already stored ones are skipped.
+func (r *MemoryRepo) InsertObservations(ctx context.Context, obs []Observation) error {
+	for _, o := range obs {
+		if len(o.City) == 0 {
+			return errors.New("invalid observation: empty city")
+		}
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	for _, o := range obs {
+		stored := false
+		for _, v := range r.observations {
+			if v.City == o.City && v.ObservedAt.Equal(o.ObservedAt) {
+				stored = true
+				break
+			}
+		}
+		if !stored {
+			r.observations = append(r.observations, o)
+		}
+	}
+
+	return nil
+}
+
+// Observations returns the city observations ordered by time.
+func (r *MemoryRepo) Observations(ctx context.Context, q CityQuery) ([]Observation, error) {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	var obs []Observation
+	for _, o := range r.observations {
+		if strings.EqualFold(o.City, q.City) && inWindow(o.ObservedAt, q) {
+			obs = append(obs, o)
+		}
+	}
+	if len(obs) == 0 {
+		return nil, ErrNoData
+	}
+
+	sort.SliceStable(obs, func(i, j int) bool {
+		return obs[i].ObservedAt.Before(obs[j].ObservedAt)
+	})
+	return obs, nil
+}
+
+// AddFavorite adds the city to the chat favorites, an already added city is skipped.
+func (r *MemoryRepo) AddFavorite(ctx context.Context, chatID int64, city string) error {
+	if len(city) == 0 {
+		return errEmptyCity
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	for _, c := range r.favorites[chatID] {
+		if strings.EqualFold(c, city) {
+			return nil
+		}
+	}
+	r.favorites[chatID] = append(r.favorites[chatID], city)
+	return nil
+}
+
+// RemoveFavorite removes the city from the chat favorites.
+// ErrNoData is returned if the city is not a favorite one.
+func (r *MemoryRepo) RemoveFavorite(ctx context.Context, chatID int64, city string) error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	cities := r.favorites[chatID]
+	for i, c := range cities {
+		if strings.EqualFold(c, city) {
+			r.favorites[chatID] = append(cities[:i:i], cities[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNoData
+}
+
+// Favorites returns the chat favorite cities in the order they were added.
+func (r *MemoryRepo) Favorites(ctx context.Context, chatID int64) ([]string, error) {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	return append([]string(nil), r.favorites[chatID]...), nil
+}
+
+// ChatSettings returns the chat settings, the zero settings if they are not saved.
+func (r *MemoryRepo) ChatSettings(ctx context.Context, chatID int64) (ChatSettings, error) {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	s, ok := r.settings[chatID]
+	if !ok {
+		return ChatSettings{ChatID: chatID}, nil
+	}
+	return s, nil
+}
+
+// SaveChatSettings adds or replaces the chat settings.
+func (r *MemoryRepo) SaveChatSettings(ctx context.Context, s ChatSettings) error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.settings[s.ChatID] = s
+	return nil
+}
+
+// GroupSettings returns the group chat settings, the zero settings if they are not saved.
+func (r *MemoryRepo) GroupSettings(ctx context.Context, chatID int64) (GroupSettings, error) {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	s, ok := r.groups[chatID]
+	if !ok {
+		return GroupSettings{ChatID: chatID}, nil
+	}
+	s.Commands = append([]string(nil), s.Commands...)
+	return s, nil
+}
+
+// SaveGroupSettings adds or replaces the group chat settings.
+func (r *MemoryRepo) SaveGroupSettings(ctx context.Context, s GroupSettings) error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	s.Commands = append([]string(nil), s.Commands...)
+	r.groups[s.ChatID] = s
+	return nil
+}
+
+// ClaimDigests returns the group and private chat digests due at now and marks them as sent
+// on the now UTC date, so a digest is claimed once a day. The digest is due since its UTC time
+// if the home city is set. The group and private chat IDs do not overlap.
+func (r *MemoryRepo) ClaimDigests(ctx context.Context, now time.Time) ([]Digest, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	clock, date := digestDue(now)
+	var digests []Digest
+	claim := func(chatID int64, homeCity, digestTime string, air bool) {
+		if len(digestTime) == 0 || digestTime > clock || len(homeCity) == 0 {
+			return
+		}
+		if r.digestsSent[chatID] >= date {
+			return
+		}
+		r.digestsSent[chatID] = date
+		digests = append(digests, Digest{ChatID: chatID, HomeCity: homeCity, Air: air})
+	}
+	for _, s := range r.groups {
+		claim(s.ChatID, s.HomeCity, s.DigestTime, s.DigestAir)
+	}
+	for _, s := range r.settings {
+		claim(s.ChatID, s.HomeCity, s.DigestTime, s.DigestAir)
+	}
+	sort.Slice(digests, func(i, j int) bool { return digests[i].ChatID < digests[j].ChatID })
+	return digests, nil
+}
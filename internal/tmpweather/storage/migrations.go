@@ -0,0 +1,16 @@
+package storage
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// Migrations returns the sql schema migrations of the repository.
+func Migrations() fs.FS {
+	// The embedded directory always exists.
+	sub, _ := fs.Sub(migrations, "migrations")
+	return sub
+}
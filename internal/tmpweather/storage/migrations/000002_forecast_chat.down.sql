@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS forecasts_chat_id_id_idx;
+
+ALTER TABLE "forecasts"
+    DROP COLUMN IF EXISTS chat_id,
+    DROP COLUMN IF EXISTS id;
@@ -0,0 +1,5 @@
+ALTER TABLE "forecasts"
+    ADD COLUMN IF NOT EXISTS id BIGSERIAL PRIMARY KEY,
+    ADD COLUMN IF NOT EXISTS chat_id bigint NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS forecasts_chat_id_id_idx ON "forecasts" (chat_id, id DESC);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS forecasts_made_at_idx;
+
+DROP TABLE IF EXISTS "forecasts_daily";
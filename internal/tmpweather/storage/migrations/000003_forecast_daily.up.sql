@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS "forecasts_daily" (
+    day date NOT NULL,
+    city text NOT NULL,
+    count bigint NOT NULL,
+    min_temp real NOT NULL,
+    max_temp real NOT NULL,
+    sum_temp double precision NOT NULL,
+    min_hum int NOT NULL,
+    max_hum int NOT NULL,
+    sum_hum bigint NOT NULL,
+    min_wind real NOT NULL,
+    max_wind real NOT NULL,
+    sum_wind double precision NOT NULL,
+    PRIMARY KEY (day, city)
+);
+
+CREATE INDEX IF NOT EXISTS forecasts_made_at_idx ON "forecasts" (made_at);
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS "observations" (
+    city text NOT NULL CHECK(LENGTH(city) > 0),
+    observed_at timestamptz NOT NULL,
+    description text NOT NULL,
+    temp real NOT NULL,
+    feels_like real NOT NULL,
+    hum int NOT NULL,
+    wind real NOT NULL,
+    PRIMARY KEY (city, observed_at)
+);
+
+-- Observations are a time series, so they are stored as a hypertable
+-- if the TimescaleDB extension is installed.
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'timescaledb') THEN
+        PERFORM create_hypertable('observations', 'observed_at', if_not_exists => TRUE);
+    END IF;
+END
+$$;
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS "favorites" (
+    chat_id bigint NOT NULL,
+    city text NOT NULL CHECK(LENGTH(city) > 0),
+    added_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS favorites_chat_id_city_idx ON "favorites" (chat_id, LOWER(city));
@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS "chat_settings" (
+    chat_id bigint PRIMARY KEY,
+    home_city text NOT NULL DEFAULT '',
+    updated_at timestamptz NOT NULL DEFAULT now()
+);
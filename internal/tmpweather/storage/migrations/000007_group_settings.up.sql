@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS "group_settings" (
+    chat_id bigint PRIMARY KEY,
+    home_city text NOT NULL DEFAULT '',
+    digest_time text NOT NULL DEFAULT '',
+    commands text[] NOT NULL DEFAULT '{}',
+    updated_at timestamptz NOT NULL DEFAULT now()
+);
@@ -0,0 +1,12 @@
+ALTER TABLE "forecasts"
+    DROP COLUMN IF EXISTS country,
+    DROP COLUMN IF EXISTS condition_id,
+    DROP COLUMN IF EXISTS icon,
+    DROP COLUMN IF EXISTS feels_like,
+    DROP COLUMN IF EXISTS pressure,
+    DROP COLUMN IF EXISTS visibility,
+    DROP COLUMN IF EXISTS clouds,
+    DROP COLUMN IF EXISTS wind_deg,
+    DROP COLUMN IF EXISTS wind_gust,
+    DROP COLUMN IF EXISTS rain,
+    DROP COLUMN IF EXISTS snow;
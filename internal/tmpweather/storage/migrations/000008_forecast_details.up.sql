@@ -0,0 +1,12 @@
+ALTER TABLE "forecasts"
+    ADD COLUMN IF NOT EXISTS country text NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS condition_id int NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS icon text NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS feels_like real NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS pressure int NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS visibility int NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS clouds int NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS wind_deg int NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS wind_gust real NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS rain real NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS snow real NOT NULL DEFAULT 0;
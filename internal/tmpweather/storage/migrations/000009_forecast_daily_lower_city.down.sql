@@ -0,0 +1,2 @@
+-- The original case of the merged city names is not kept, the lowercase names stay.
+SELECT 1;
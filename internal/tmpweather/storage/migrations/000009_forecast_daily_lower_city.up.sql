@@ -0,0 +1,32 @@
+-- The daily aggregates are keyed by the lowercase city name: merge the rows of the other cases.
+WITH merged AS (
+    DELETE FROM "forecasts_daily" WHERE city <> LOWER(city) RETURNING *
+)
+INSERT INTO "forecasts_daily" AS d (
+    day, city, count,
+    min_temp, max_temp, sum_temp,
+    min_hum, max_hum, sum_hum,
+    min_wind, max_wind, sum_wind
+)
+SELECT
+    day,
+    LOWER(city),
+    SUM(count),
+    MIN(min_temp), MAX(max_temp), SUM(sum_temp),
+    MIN(min_hum), MAX(max_hum), SUM(sum_hum),
+    MIN(min_wind), MAX(max_wind), SUM(sum_wind)
+FROM
+    merged
+GROUP BY
+    day, LOWER(city)
+ON CONFLICT (day, city) DO UPDATE SET
+    count = d.count + EXCLUDED.count,
+    min_temp = LEAST(d.min_temp, EXCLUDED.min_temp),
+    max_temp = GREATEST(d.max_temp, EXCLUDED.max_temp),
+    sum_temp = d.sum_temp + EXCLUDED.sum_temp,
+    min_hum = LEAST(d.min_hum, EXCLUDED.min_hum),
+    max_hum = GREATEST(d.max_hum, EXCLUDED.max_hum),
+    sum_hum = d.sum_hum + EXCLUDED.sum_hum,
+    min_wind = LEAST(d.min_wind, EXCLUDED.min_wind),
+    max_wind = GREATEST(d.max_wind, EXCLUDED.max_wind),
+    sum_wind = d.sum_wind + EXCLUDED.sum_wind;
@@ -0,0 +1,2 @@
+ALTER TABLE "group_settings"
+    DROP COLUMN IF EXISTS digest_sent_on;
@@ -0,0 +1,3 @@
+-- The UTC date the group digest was last sent on, so it is sent once a day by any replica.
+ALTER TABLE "group_settings"
+    ADD COLUMN IF NOT EXISTS digest_sent_on date;
@@ -0,0 +1,3 @@
+ALTER TABLE "chat_settings"
+    DROP COLUMN IF EXISTS digest_time,
+    DROP COLUMN IF EXISTS digest_sent_on;
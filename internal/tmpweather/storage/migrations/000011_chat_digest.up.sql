@@ -0,0 +1,3 @@
+ALTER TABLE "chat_settings"
+    ADD COLUMN IF NOT EXISTS digest_time text NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS digest_sent_on date;
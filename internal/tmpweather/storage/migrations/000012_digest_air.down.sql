@@ -0,0 +1,5 @@
+ALTER TABLE "group_settings"
+    DROP COLUMN IF EXISTS digest_air;
+
+ALTER TABLE "chat_settings"
+    DROP COLUMN IF EXISTS digest_air;
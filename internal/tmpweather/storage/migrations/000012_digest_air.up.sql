@@ -0,0 +1,5 @@
+ALTER TABLE "group_settings"
+    ADD COLUMN IF NOT EXISTS digest_air boolean NOT NULL DEFAULT false;
+
+ALTER TABLE "chat_settings"
+    ADD COLUMN IF NOT EXISTS digest_air boolean NOT NULL DEFAULT false;
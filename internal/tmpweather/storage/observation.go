@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/alukart32/tmp-weather/internal/pkg/otelx"
+	"github.com/alukart32/tmp-weather/internal/pkg/zerologx"
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Observation represents the observed weather of the city.
+type Observation struct {
+	City       string
+	ObservedAt time.Time
+	Desc       string
+	Temp       float64
+	FeelsLike  float64
+	Hum        int64
+	Wind       float64
+}
+
+// ObservationRepository defines the weather observation repository.
+type ObservationRepository interface {
+	// InsertObservations adds the observations, already stored ones are skipped.
+	InsertObservations(ctx context.Context, obs []Observation) error
+	// Observations returns the city observations ordered by time.
+	Observations(ctx context.Context, q CityQuery) ([]Observation, error)
+}
+
+var (
+	_ ObservationRepository = (*WeatherForecastRepo)(nil)
+	_ ObservationRepository = (*SQLiteRepo)(nil)
+	_ ObservationRepository = (*MemoryRepo)(nil)
+)
+
+const insertObservation = `
+INSERT INTO
+	observations(city, observed_at, description, temp, feels_like, hum, wind)
+VALUES
+	($1, $2, $3, $4, $5, $6, $7)
+ON CONFLICT DO NOTHING
+`
+
+// InsertObservations adds the observations, already stored ones are skipped.
+func (r *WeatherForecastRepo) InsertObservations(ctx context.Context, obs []Observation) (err error) {
+	ctx, span := tracer.Start(ctx, "WeatherForecastRepo.InsertObservations")
+	span.SetAttributes(attribute.Int("batch.size", len(obs)))
+	defer func() { otelx.End(span, err) }()
+
+	batch := &pgx.Batch{}
+	for _, o := range obs {
+		batch.Queue(insertObservation, o.City, o.ObservedAt, o.Desc, o.Temp, o.FeelsLike, o.Hum, o.Wind)
+	}
+	return r.pool.SendBatch(ctx, batch).Close()
+}
+
+const getObservations = `
+SELECT
+	city, observed_at, description, temp, feels_like, hum, wind
+FROM
+	observations
+WHERE
+	LOWER(city) = LOWER($1)
+	AND ($2::timestamptz IS NULL OR observed_at >= $2)
+	AND ($3::timestamptz IS NULL OR observed_at < $3)
+ORDER BY
+	observed_at ASC
+`
+
+// Observations returns the city observations ordered by time.
+func (r *WeatherForecastRepo) Observations(ctx context.Context, q CityQuery) (obs []Observation, err error) {
+	ctx, span := tracer.Start(ctx, "WeatherForecastRepo.Observations")
+	span.SetAttributes(attribute.String("city", q.City))
+	defer func() { otelx.End(span, err) }()
+
+	logger := zerologx.Ctx(ctx)
+	logger.Debug().
+		Str("op", "get observations").
+		Str("city", q.City).
+		Time("since", q.Since).
+		Time("until", q.Until).Send()
+
+	rows, err := r.pool.Query(ctx, getObservations, q.City, nullTime(q.Since), nullTime(q.Until))
+	if err != nil {
+		return nil, err
+	}
+
+	obs, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (Observation, error) {
+		var o Observation
+		err := row.Scan(&o.City, &o.ObservedAt, &o.Desc, &o.Temp, &o.FeelsLike, &o.Hum, &o.Wind)
+		return o, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(obs) == 0 {
+		return nil, ErrNoData
+	}
+	return obs, nil
+}
+
+// nullTime returns nil for the zero time to be passed as the SQL NULL.
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
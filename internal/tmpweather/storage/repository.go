@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Repository drivers.
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+	DriverMemory   = "memory"
+)
+
+// Config is the representation of repository settings.
+type Config struct {
+	// Driver is the repository driver: postgres, sqlite or memory.
+	Driver string `yaml:"driver" toml:"driver" env:"STORAGE_DRIVER"`
+	// SQLitePath is the SQLite database file path.
+	SQLitePath string `yaml:"sqlite_path" toml:"sqlite_path" env:"SQLITE_PATH"`
+
+	// BatchSize is the max number of forecasts written at once.
+	BatchSize int `yaml:"batch_size" toml:"batch_size" env:"STORAGE_BATCH_SIZE"`
+	// FlushInterval is the max time a forecast waits to be written.
+	FlushInterval time.Duration `yaml:"flush_interval" toml:"flush_interval" env:"STORAGE_FLUSH_INTERVAL"`
+	// WriteRetries is the number of retries of a batch write with a transient error.
+	WriteRetries int `yaml:"write_retries" toml:"write_retries" env:"STORAGE_WRITE_RETRIES"`
+
+	// Retention is the retention of the postgres forecasts.
+	Retention RetentionConfig `yaml:"retention" toml:"retention"`
+}
+
+// CityQuery defines the records of the city in the time window.
+type CityQuery struct {
+	City  string    // case-insensitive
+	Since time.Time // optional start of the time window
+	Until time.Time // optional end of the time window
+}
+
+// Repository defines the weather forecast repository.
+type Repository interface {
+	// Insert adds a new weather forecast data.
+	Insert(ctx context.Context, f WeatherForecast) error
+	// Stat returns the weather forecast statistics.
+	Stat(ctx context.Context, q StatQuery) (WeatherForecastStat, error)
+	// History returns the page of the chat forecast history.
+	History(ctx context.Context, q HistoryQuery) (HistoryPage, error)
+	// CityForecasts returns the forecasts of the city ordered by time.
+	CityForecasts(ctx context.Context, q CityQuery) ([]WeatherForecast, error)
+	// ExportForecasts calls fn for each chat forecast oldest-first without loading them all.
+	ExportForecasts(ctx context.Context, q ExportQuery, fn func(WeatherForecast) error) error
+}
+
+// ForecastWriter defines the writer of new weather forecasts.
+type ForecastWriter interface {
+	// Insert adds a new weather forecast data.
+	Insert(ctx context.Context, f WeatherForecast) error
+}
+
+// BatchRepository defines the repository that can write forecasts in batches.
+type BatchRepository interface {
+	Repository
+	// InsertBatch adds the weather forecasts at once.
+	InsertBatch(ctx context.Context, fs []WeatherForecast) error
+}
+
+var (
+	_ BatchRepository = (*WeatherForecastRepo)(nil)
+	_ BatchRepository = (*SQLiteRepo)(nil)
+	_ BatchRepository = (*MemoryRepo)(nil)
+	_ ForecastWriter  = (*BatchWriter)(nil)
+)
+
+// round2 rounds x to 2 decimal places like numeric(10, 2).
+func round2(x float64) float64 {
+	return math.Round(x*100) / 100
+}
@@ -0,0 +1,806 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// repoRunner runs the test with a new empty repository.
+type repoRunner func(t *testing.T, test func(t *testing.T, repo Repository))
+
+func TestMemoryRepo(t *testing.T) {
+	testRepository(t, func(t *testing.T, test func(t *testing.T, repo Repository)) {
+		test(t, NewMemoryRepo())
+	})
+}
+
+func TestSQLiteRepo(t *testing.T) {
+	testRepository(t, func(t *testing.T, test func(t *testing.T, repo Repository)) {
+		repo, err := NewSQLiteRepo(filepath.Join(t.TempDir(), "test.db"))
+		require.NoError(t, err)
+		defer repo.Close()
+
+		test(t, repo)
+	})
+}
+
+func TestSQLiteRepo_addedColumns(t *testing.T) {
+	// The database created before the forecast details.
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := sql.Open("sqlite", "file:"+path)
+	require.NoError(t, err)
+	_, err = db.Exec(`CREATE TABLE forecasts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    chat_id INTEGER NOT NULL DEFAULT 0,
+    msg_id INTEGER NOT NULL,
+    city TEXT NOT NULL,
+    description TEXT NOT NULL,
+    temp REAL NOT NULL,
+    hum INTEGER NOT NULL,
+    wind REAL NOT NULL,
+    made_at INTEGER NOT NULL
+);
+INSERT INTO forecasts(msg_id, city, description, temp, hum, wind, made_at) VALUES (1, 'Berlin', 'clear', 1, 2, 3, 0);`)
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	for i := 0; i < 2; i++ {
+		repo, err := NewSQLiteRepo(path)
+		require.NoError(t, err, "reopen %d", i)
+
+		forecasts, err := repo.CityForecasts(context.TODO(), CityQuery{City: "Berlin"})
+		require.NoError(t, err)
+		require.Len(t, forecasts, 1)
+		assert.Equal(t, int64(0), forecasts[0].Pressure)
+		require.NoError(t, repo.Close())
+	}
+}
+
+// testRepository is the conformance test suite of the Repository implementations.
+func testRepository(t *testing.T, withRepo repoRunner) {
+	t.Run("Insert", func(t *testing.T) { testRepositoryInsert(t, withRepo) })
+	t.Run("InsertBatch", func(t *testing.T) { testRepositoryInsertBatch(t, withRepo) })
+	t.Run("Stat", func(t *testing.T) { testRepositoryStat(t, withRepo) })
+	t.Run("History", func(t *testing.T) { testRepositoryHistory(t, withRepo) })
+	t.Run("CityForecasts", func(t *testing.T) { testRepositoryCityForecasts(t, withRepo) })
+	t.Run("ForecastDetails", func(t *testing.T) { testRepositoryForecastDetails(t, withRepo) })
+	t.Run("ExportForecasts", func(t *testing.T) { testRepositoryExportForecasts(t, withRepo) })
+	t.Run("Observations", func(t *testing.T) { testRepositoryObservations(t, withRepo) })
+	t.Run("Favorites", func(t *testing.T) { testRepositoryFavorites(t, withRepo) })
+	t.Run("ChatSettings", func(t *testing.T) { testRepositoryChatSettings(t, withRepo) })
+	t.Run("GroupSettings", func(t *testing.T) { testRepositoryGroupSettings(t, withRepo) })
+	t.Run("ClaimDigests", func(t *testing.T) { testRepositoryClaimDigests(t, withRepo) })
+}
+
+func testRepositoryInsert(t *testing.T, withRepo repoRunner) {
+	tests := []struct {
+		name    string
+		data    WeatherForecast
+		wantErr bool
+	}{
+		{
+			name: "Valid weather forecast",
+			data: WeatherForecast{
+				MsgID:  1,
+				City:   "Test",
+				Desc:   "testable",
+				Temp:   1.0,
+				Hum:    2,
+				Wind:   3.0,
+				MadeAt: time.Now(),
+			},
+		},
+		{
+			name: "Empty city",
+			data: WeatherForecast{
+				MsgID:  1,
+				Desc:   "testable",
+				Temp:   1.0,
+				Hum:    2,
+				Wind:   3.0,
+				MadeAt: time.Now(),
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withRepo(t, func(t *testing.T, repo Repository) {
+				insertCtx, cancel := context.WithTimeout(context.TODO(), 1*time.Second)
+				defer cancel()
+
+				err := repo.Insert(insertCtx, tt.data)
+				if tt.wantErr {
+					require.Error(t, err)
+					return
+				}
+				require.NoError(t, err)
+			})
+		})
+	}
+}
+
+func testRepositoryInsertBatch(t *testing.T, withRepo repoRunner) {
+	withRepo(t, func(t *testing.T, repo Repository) {
+		batchRepo, ok := repo.(BatchRepository)
+		if !ok {
+			t.Skip("no batch writes")
+		}
+
+		ctx, cancel := context.WithTimeout(context.TODO(), 1*time.Second)
+		defer cancel()
+
+		madeAt := time.Now().Truncate(time.Microsecond)
+		var forecasts []WeatherForecast
+		for i := 1; i <= 3; i++ {
+			forecasts = append(forecasts, WeatherForecast{
+				ChatID: 1,
+				MsgID:  i,
+				City:   "A",
+				Desc:   "casual weather",
+				Temp:   1.5,
+				Hum:    2,
+				Wind:   3.5,
+				MadeAt: madeAt,
+			})
+		}
+		require.NoError(t, batchRepo.InsertBatch(ctx, forecasts))
+
+		page, err := repo.History(ctx, HistoryQuery{ChatID: 1, Limit: 5})
+		require.NoError(t, err)
+		require.Len(t, page.Forecasts, 3)
+		for i, f := range page.Forecasts {
+			assert.Equal(t, 3-i, f.MsgID)
+			assert.Equal(t, "A", f.City)
+			assert.Equal(t, 1.5, f.Temp)
+			assert.Equal(t, 3.5, f.Wind)
+			assert.Equal(t, 0, madeAt.Compare(f.MadeAt))
+		}
+	})
+}
+
+func testRepositoryStat(t *testing.T, withRepo repoRunner) {
+	// Postgres stores timestamps with microsecond precision.
+	firstCreatedAt := time.Now().Truncate(time.Microsecond)
+	tests := []struct {
+		name      string
+		forecasts []WeatherForecast
+		query     StatQuery
+		wantStat  WeatherForecastStat
+		wantErr   bool
+	}{
+		{
+			name: "Valid stat",
+			forecasts: []WeatherForecast{
+				{
+					MsgID:  1,
+					City:   "A",
+					Desc:   "max temp",
+					Temp:   30.0,
+					Hum:    2,
+					Wind:   3.0,
+					MadeAt: firstCreatedAt,
+				},
+				{
+					MsgID:  2,
+					City:   "B",
+					Desc:   "max hum",
+					Temp:   1.0,
+					Hum:    90,
+					Wind:   3.0,
+					MadeAt: time.Now(),
+				},
+				{
+					MsgID:  3,
+					City:   "C",
+					Desc:   "max wind",
+					Temp:   1.0,
+					Hum:    2,
+					Wind:   12.0,
+					MadeAt: time.Now(),
+				},
+				{
+					MsgID:  4,
+					City:   "D",
+					Desc:   "casual weather",
+					Temp:   1.0,
+					Hum:    2,
+					Wind:   3.0,
+					MadeAt: time.Now(),
+				},
+			},
+			wantStat: WeatherForecastStat{
+				total:         4,
+				firstRecordAt: firstCreatedAt,
+				TopRecords: struct {
+					maxTempCity string
+					maxTemp     float64
+					maxHumCity  string
+					maxHum      int64
+					maxWindCity string
+					maxWind     float64
+				}{
+					maxTempCity: "A",
+					maxTemp:     30.0,
+					maxHumCity:  "B",
+					maxHum:      90,
+					maxWindCity: "C",
+					maxWind:     12.0,
+				},
+			},
+		},
+		{
+			name: "City stat in the time window",
+			forecasts: []WeatherForecast{
+				{
+					MsgID:  1,
+					City:   "A",
+					Desc:   "out of window",
+					Temp:   40.0,
+					Hum:    2,
+					Wind:   3.0,
+					MadeAt: firstCreatedAt.Add(-48 * time.Hour),
+				},
+				{
+					MsgID:  2,
+					City:   "A",
+					Desc:   "max temp",
+					Temp:   20.0,
+					Hum:    10,
+					Wind:   2.0,
+					MadeAt: firstCreatedAt,
+				},
+				{
+					MsgID:  3,
+					City:   "a",
+					Desc:   "max hum and wind",
+					Temp:   10.0,
+					Hum:    30,
+					Wind:   4.0,
+					MadeAt: firstCreatedAt.Add(time.Minute),
+				},
+				{
+					MsgID:  4,
+					City:   "B",
+					Desc:   "other city",
+					Temp:   1.0,
+					Hum:    2,
+					Wind:   3.0,
+					MadeAt: firstCreatedAt,
+				},
+			},
+			query: StatQuery{
+				City:  "A",
+				Since: firstCreatedAt.Add(-24 * time.Hour),
+			},
+			wantStat: WeatherForecastStat{
+				total:         2,
+				firstRecordAt: firstCreatedAt,
+				TopRecords: struct {
+					maxTempCity string
+					maxTemp     float64
+					maxHumCity  string
+					maxHum      int64
+					maxWindCity string
+					maxWind     float64
+				}{
+					maxTempCity: "A",
+					maxTemp:     20.0,
+					maxHumCity:  "a",
+					maxHum:      30,
+					maxWindCity: "a",
+					maxWind:     4.0,
+				},
+				cities: []CityStat{
+					{
+						city:    "A",
+						count:   2,
+						minTemp: 10.0,
+						avgTemp: 15.0,
+						maxTemp: 20.0,
+						minHum:  10,
+						avgHum:  20.0,
+						maxHum:  30,
+						minWind: 2.0,
+						avgWind: 3.0,
+						maxWind: 4.0,
+					},
+				},
+			},
+		},
+		{
+			name:    "No data",
+			query:   StatQuery{City: "Unknown"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withRepo(t, func(t *testing.T, repo Repository) {
+				insertCtx, cancel := context.WithTimeout(context.TODO(), 1*time.Second)
+				defer cancel()
+
+				for _, v := range tt.forecasts {
+					require.NoError(t, repo.Insert(insertCtx, v))
+				}
+
+				statCtx, cancel := context.WithTimeout(context.TODO(), 1*time.Second)
+				defer cancel()
+
+				stat, err := repo.Stat(statCtx, tt.query)
+				if tt.wantErr {
+					require.Error(t, err)
+					return
+				}
+				require.NoError(t, err)
+
+				assert.Equal(t, tt.wantStat.total, stat.total,
+					"expected total: %d, was %d", tt.wantStat.total, stat.total)
+				assert.Equal(t, 0, tt.wantStat.firstRecordAt.Compare(stat.firstRecordAt),
+					"expected firstRecordAt: %v, was %v", tt.wantStat.firstRecordAt, stat.firstRecordAt)
+				assert.Equal(t, tt.wantStat.TopRecords.maxTempCity, stat.TopRecords.maxTempCity,
+					"expected maxTempCity: %v, was %v", tt.wantStat.TopRecords.maxTempCity, stat.TopRecords.maxTempCity)
+				assert.Equal(t, tt.wantStat.TopRecords.maxTemp, stat.TopRecords.maxTemp,
+					"expected maxTemp: %v, was %v", tt.wantStat.TopRecords.maxTemp, stat.TopRecords.maxTemp)
+				assert.Equal(t, tt.wantStat.TopRecords.maxHumCity, stat.TopRecords.maxHumCity,
+					"expected maxHumCity: %v, was %v", tt.wantStat.TopRecords.maxHumCity, stat.TopRecords.maxHumCity)
+				assert.Equal(t, tt.wantStat.TopRecords.maxHum, stat.TopRecords.maxHum,
+					"expected maxHum: %v, was %v", tt.wantStat.TopRecords.maxHum, stat.TopRecords.maxHum)
+				assert.Equal(t, tt.wantStat.TopRecords.maxWindCity, stat.TopRecords.maxWindCity,
+					"expected maxWindCity: %v, was %v", tt.wantStat.TopRecords.maxWindCity, stat.TopRecords.maxWindCity)
+				assert.Equal(t, tt.wantStat.TopRecords.maxWind, stat.TopRecords.maxWind,
+					"expected maxWind: %v, was %v", tt.wantStat.TopRecords.maxWind, stat.TopRecords.maxWind)
+				if tt.wantStat.cities != nil {
+					assert.Equal(t, tt.wantStat.cities, stat.cities)
+				}
+			})
+		})
+	}
+}
+
+func testRepositoryHistory(t *testing.T, withRepo repoRunner) {
+	withRepo(t, func(t *testing.T, repo Repository) {
+		ctx, cancel := context.WithTimeout(context.TODO(), 5*time.Second)
+		defer cancel()
+
+		// Chat 1 has forecasts with msg IDs 1..7, chat 2 has 8..9.
+		madeAt := time.Now().Truncate(time.Microsecond)
+		for i := 1; i <= 9; i++ {
+			f := WeatherForecast{
+				ChatID: 1,
+				MsgID:  i,
+				City:   "A",
+				Desc:   "casual weather",
+				Temp:   1.0,
+				Hum:    2,
+				Wind:   3.0,
+				MadeAt: madeAt.Add(time.Duration(i) * time.Minute),
+			}
+			if i%2 == 0 {
+				f.City = "B"
+			}
+			if i > 7 {
+				f.ChatID = 2
+			}
+			require.NoError(t, repo.Insert(ctx, f))
+		}
+
+		msgIDs := func(page HistoryPage) []int {
+			var ids []int
+			for _, f := range page.Forecasts {
+				ids = append(ids, f.MsgID)
+			}
+			return ids
+		}
+
+		first, err := repo.History(ctx, HistoryQuery{ChatID: 1, Limit: 3})
+		require.NoError(t, err)
+		assert.Equal(t, []int{7, 6, 5}, msgIDs(first))
+		assert.False(t, first.HasNewer)
+		assert.True(t, first.HasOlder)
+		assert.Equal(t, 0, madeAt.Add(7*time.Minute).Compare(first.Forecasts[0].MadeAt))
+
+		second, err := repo.History(ctx, HistoryQuery{
+			ChatID: 1,
+			Before: first.Forecasts[2].ID,
+			Limit:  3,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []int{4, 3, 2}, msgIDs(second))
+		assert.True(t, second.HasNewer)
+		assert.True(t, second.HasOlder)
+
+		last, err := repo.History(ctx, HistoryQuery{
+			ChatID: 1,
+			Before: second.Forecasts[2].ID,
+			Limit:  3,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []int{1}, msgIDs(last))
+		assert.True(t, last.HasNewer)
+		assert.False(t, last.HasOlder)
+
+		prev, err := repo.History(ctx, HistoryQuery{
+			ChatID: 1,
+			After:  last.Forecasts[0].ID,
+			Limit:  3,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []int{4, 3, 2}, msgIDs(prev))
+		assert.True(t, prev.HasNewer)
+		assert.True(t, prev.HasOlder)
+
+		city, err := repo.History(ctx, HistoryQuery{ChatID: 1, City: "B", Limit: 5})
+		require.NoError(t, err)
+		assert.Equal(t, []int{6, 4, 2}, msgIDs(city))
+		assert.False(t, city.HasOlder)
+
+		// The city filter is case-insensitive in both directions.
+		mixedCase, err := repo.History(ctx, HistoryQuery{ChatID: 1, City: "b", Limit: 5})
+		require.NoError(t, err)
+		assert.Equal(t, []int{6, 4, 2}, msgIDs(mixedCase))
+		newer, err := repo.History(ctx, HistoryQuery{ChatID: 1, City: "b", After: mixedCase.Forecasts[2].ID, Limit: 5})
+		require.NoError(t, err)
+		assert.Equal(t, []int{6, 4}, msgIDs(newer))
+
+		_, err = repo.History(ctx, HistoryQuery{ChatID: 3, Limit: 3})
+		assert.ErrorIs(t, err, ErrNoData)
+	})
+}
+
+func testRepositoryCityForecasts(t *testing.T, withRepo repoRunner) {
+	withRepo(t, func(t *testing.T, repo Repository) {
+		ctx, cancel := context.WithTimeout(context.TODO(), 5*time.Second)
+		defer cancel()
+
+		madeAt := time.Now().Truncate(time.Microsecond)
+		for i, city := range []string{"Berlin", "berlin", "Paris", "Berlin"} {
+			require.NoError(t, repo.Insert(ctx, WeatherForecast{
+				ChatID: int64(i),
+				MsgID:  i,
+				City:   city,
+				Desc:   "casual weather",
+				Temp:   float64(i),
+				Hum:    2,
+				Wind:   3.0,
+				// Inserted in reverse time order.
+				MadeAt: madeAt.Add(-time.Duration(i) * time.Hour),
+			}))
+		}
+
+		msgIDs := func(forecasts []WeatherForecast) []int {
+			var ids []int
+			for _, f := range forecasts {
+				ids = append(ids, f.MsgID)
+			}
+			return ids
+		}
+
+		forecasts, err := repo.CityForecasts(ctx, CityQuery{City: "BERLIN"})
+		require.NoError(t, err)
+		assert.Equal(t, []int{3, 1, 0}, msgIDs(forecasts))
+
+		forecasts, err = repo.CityForecasts(ctx, CityQuery{
+			City:  "Berlin",
+			Since: madeAt.Add(-time.Hour),
+			Until: madeAt,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []int{1}, msgIDs(forecasts))
+
+		_, err = repo.CityForecasts(ctx, CityQuery{City: "London"})
+		assert.ErrorIs(t, err, ErrNoData)
+	})
+}
+
+func testRepositoryForecastDetails(t *testing.T, withRepo repoRunner) {
+	withRepo(t, func(t *testing.T, repo Repository) {
+		ctx, cancel := context.WithTimeout(context.TODO(), 5*time.Second)
+		defer cancel()
+
+		want := WeatherForecast{
+			ChatID:      1,
+			MsgID:       2,
+			City:        "Berlin",
+			Country:     "DE",
+			Desc:        "light rain",
+			ConditionID: 500,
+			Icon:        "10d",
+			Temp:        12.5,
+			FeelsLike:   11.25,
+			Hum:         80,
+			Pressure:    1012,
+			Visibility:  9000,
+			Clouds:      75,
+			Wind:        4.5,
+			WindDeg:     230,
+			WindGust:    8.25,
+			Rain:        0.5,
+			Snow:        0.25,
+			MadeAt:      time.Now().Truncate(time.Microsecond),
+		}
+		require.NoError(t, repo.Insert(ctx, want))
+
+		forecasts, err := repo.CityForecasts(ctx, CityQuery{City: "Berlin"})
+		require.NoError(t, err)
+		require.Len(t, forecasts, 1)
+		got := forecasts[0]
+		assert.NotZero(t, got.ID)
+		assert.True(t, want.MadeAt.Equal(got.MadeAt))
+		want.ID, want.MadeAt = got.ID, got.MadeAt
+		assert.Equal(t, want, got)
+	})
+}
+
+func testRepositoryExportForecasts(t *testing.T, withRepo repoRunner) {
+	withRepo(t, func(t *testing.T, repo Repository) {
+		ctx, cancel := context.WithTimeout(context.TODO(), 5*time.Second)
+		defer cancel()
+
+		madeAt := time.Now().Truncate(time.Microsecond)
+		for i, chatID := range []int64{1, 2, 1, 1} {
+			require.NoError(t, repo.Insert(ctx, WeatherForecast{
+				ChatID: chatID,
+				MsgID:  i,
+				City:   "Berlin",
+				Desc:   "casual weather",
+				Temp:   float64(i),
+				Hum:    2,
+				Wind:   3.0,
+				// The first forecast is the oldest one.
+				MadeAt: madeAt.Add(-time.Duration(3-i) * time.Hour),
+			}))
+		}
+
+		export := func(q ExportQuery) ([]int, error) {
+			var ids []int
+			err := repo.ExportForecasts(ctx, q, func(f WeatherForecast) error {
+				assert.Equal(t, q.ChatID, f.ChatID)
+				ids = append(ids, f.MsgID)
+				return nil
+			})
+			return ids, err
+		}
+
+		ids, err := export(ExportQuery{ChatID: 1})
+		require.NoError(t, err)
+		assert.Equal(t, []int{0, 2, 3}, ids)
+
+		ids, err = export(ExportQuery{ChatID: 1, Since: madeAt.Add(-time.Hour)})
+		require.NoError(t, err)
+		assert.Equal(t, []int{2, 3}, ids)
+
+		ids, err = export(ExportQuery{ChatID: 3})
+		require.NoError(t, err)
+		assert.Empty(t, ids)
+
+		// The fn error stops the export.
+		errStop := errors.New("stop")
+		var n int
+		err = repo.ExportForecasts(ctx, ExportQuery{ChatID: 1}, func(WeatherForecast) error {
+			n++
+			return errStop
+		})
+		assert.ErrorIs(t, err, errStop)
+		assert.Equal(t, 1, n)
+	})
+}
+
+func testRepositoryObservations(t *testing.T, withRepo repoRunner) {
+	withRepo(t, func(t *testing.T, repo Repository) {
+		obsRepo, ok := repo.(ObservationRepository)
+		if !ok {
+			t.Skip("no observations")
+		}
+
+		ctx, cancel := context.WithTimeout(context.TODO(), 5*time.Second)
+		defer cancel()
+
+		observedAt := time.Now().Truncate(time.Microsecond)
+		observation := func(city string, hours int, temp float64) Observation {
+			return Observation{
+				City:       city,
+				ObservedAt: observedAt.Add(time.Duration(hours) * time.Hour),
+				Desc:       "casual weather",
+				Temp:       temp,
+				FeelsLike:  temp - 1,
+				Hum:        2,
+				Wind:       3.0,
+			}
+		}
+
+		require.NoError(t, obsRepo.InsertObservations(ctx, []Observation{
+			observation("Berlin", 2, 12),
+			observation("Berlin", 0, 10),
+			observation("Berlin", 1, 11),
+			observation("Paris", 1, 20),
+		}))
+		// Already stored observations are skipped.
+		require.NoError(t, obsRepo.InsertObservations(ctx, []Observation{
+			observation("Berlin", 1, 11),
+		}))
+
+		temps := func(obs []Observation) []float64 {
+			var res []float64
+			for _, o := range obs {
+				res = append(res, o.Temp)
+			}
+			return res
+		}
+
+		obs, err := obsRepo.Observations(ctx, CityQuery{City: "berlin"})
+		require.NoError(t, err)
+		assert.Equal(t, []float64{10, 11, 12}, temps(obs))
+		assert.Equal(t, "Berlin", obs[0].City)
+		assert.Equal(t, 9.0, obs[0].FeelsLike)
+		assert.Equal(t, 0, observedAt.Compare(obs[0].ObservedAt))
+
+		obs, err = obsRepo.Observations(ctx, CityQuery{
+			City:  "Berlin",
+			Since: observedAt.Add(time.Hour),
+			Until: observedAt.Add(2 * time.Hour),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []float64{11}, temps(obs))
+
+		_, err = obsRepo.Observations(ctx, CityQuery{City: "London"})
+		assert.ErrorIs(t, err, ErrNoData)
+	})
+}
+
+func testRepositoryFavorites(t *testing.T, withRepo repoRunner) {
+	withRepo(t, func(t *testing.T, repo Repository) {
+		chatRepo, ok := repo.(ChatRepository)
+		if !ok {
+			t.Skip("no chat preferences")
+		}
+
+		ctx, cancel := context.WithTimeout(context.TODO(), 5*time.Second)
+		defer cancel()
+
+		for _, city := range []string{"Paris", "Berlin", "paris", "London"} {
+			require.NoError(t, chatRepo.AddFavorite(ctx, 1, city))
+		}
+		require.NoError(t, chatRepo.AddFavorite(ctx, 2, "Rome"))
+		assert.Error(t, chatRepo.AddFavorite(ctx, 1, ""))
+
+		cities, err := chatRepo.Favorites(ctx, 1)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Paris", "Berlin", "London"}, cities)
+
+		require.NoError(t, chatRepo.RemoveFavorite(ctx, 1, "BERLIN"))
+		assert.ErrorIs(t, chatRepo.RemoveFavorite(ctx, 1, "Berlin"), ErrNoData)
+		assert.ErrorIs(t, chatRepo.RemoveFavorite(ctx, 1, "Rome"), ErrNoData)
+
+		cities, err = chatRepo.Favorites(ctx, 1)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Paris", "London"}, cities)
+
+		cities, err = chatRepo.Favorites(ctx, 3)
+		require.NoError(t, err)
+		assert.Empty(t, cities)
+	})
+}
+
+func testRepositoryChatSettings(t *testing.T, withRepo repoRunner) {
+	withRepo(t, func(t *testing.T, repo Repository) {
+		chatRepo, ok := repo.(ChatRepository)
+		if !ok {
+			t.Skip("no chat preferences")
+		}
+
+		ctx, cancel := context.WithTimeout(context.TODO(), 5*time.Second)
+		defer cancel()
+
+		settings, err := chatRepo.ChatSettings(ctx, 1)
+		require.NoError(t, err)
+		assert.Equal(t, ChatSettings{ChatID: 1}, settings)
+
+		require.NoError(t, chatRepo.SaveChatSettings(ctx, ChatSettings{ChatID: 1, HomeCity: "Paris"}))
+		require.NoError(t, chatRepo.SaveChatSettings(ctx, ChatSettings{ChatID: 2, HomeCity: "Rome"}))
+		require.NoError(t, chatRepo.SaveChatSettings(ctx, ChatSettings{ChatID: 1, HomeCity: "Berlin", DigestTime: "07:15", DigestAir: true}))
+
+		settings, err = chatRepo.ChatSettings(ctx, 1)
+		require.NoError(t, err)
+		assert.Equal(t, ChatSettings{ChatID: 1, HomeCity: "Berlin", DigestTime: "07:15", DigestAir: true}, settings)
+
+		require.NoError(t, chatRepo.SaveChatSettings(ctx, ChatSettings{ChatID: 2}))
+		settings, err = chatRepo.ChatSettings(ctx, 2)
+		require.NoError(t, err)
+		assert.Equal(t, ChatSettings{ChatID: 2}, settings)
+	})
+}
+
+func testRepositoryGroupSettings(t *testing.T, withRepo repoRunner) {
+	withRepo(t, func(t *testing.T, repo Repository) {
+		chatRepo, ok := repo.(ChatRepository)
+		if !ok {
+			t.Skip("no chat preferences")
+		}
+
+		ctx, cancel := context.WithTimeout(context.TODO(), 5*time.Second)
+		defer cancel()
+
+		settings, err := chatRepo.GroupSettings(ctx, -1)
+		require.NoError(t, err)
+		assert.Equal(t, GroupSettings{ChatID: -1}, settings)
+
+		want := GroupSettings{ChatID: -1, HomeCity: "Paris", DigestTime: "08:30", DigestAir: true, Commands: []string{"info", "stat"}}
+		require.NoError(t, chatRepo.SaveGroupSettings(ctx, want))
+
+		settings, err = chatRepo.GroupSettings(ctx, -1)
+		require.NoError(t, err)
+		assert.Equal(t, want, settings)
+
+		// Group and private chat settings are stored separately.
+		chatSettings, err := chatRepo.ChatSettings(ctx, -1)
+		require.NoError(t, err)
+		assert.Empty(t, chatSettings.HomeCity)
+
+		want = GroupSettings{ChatID: -1, HomeCity: "Berlin"}
+		require.NoError(t, chatRepo.SaveGroupSettings(ctx, want))
+
+		settings, err = chatRepo.GroupSettings(ctx, -1)
+		require.NoError(t, err)
+		assert.Equal(t, want, settings)
+	})
+}
+
+func testRepositoryClaimDigests(t *testing.T, withRepo repoRunner) {
+	withRepo(t, func(t *testing.T, repo Repository) {
+		chatRepo, ok := repo.(ChatRepository)
+		if !ok {
+			t.Skip("no chat preferences")
+		}
+
+		ctx, cancel := context.WithTimeout(context.TODO(), 5*time.Second)
+		defer cancel()
+
+		for _, s := range []GroupSettings{
+			{ChatID: -1, HomeCity: "Paris", DigestTime: "08:30", DigestAir: true},
+			{ChatID: -2, HomeCity: "Berlin", DigestTime: "09:00"},
+			{ChatID: -3, DigestTime: "08:00"}, // no home city
+			{ChatID: -4, HomeCity: "Rome"},    // no digest
+		} {
+			require.NoError(t, chatRepo.SaveGroupSettings(ctx, s))
+		}
+		require.NoError(t, chatRepo.SaveChatSettings(ctx, ChatSettings{ChatID: 1, HomeCity: "Oslo", DigestTime: "08:45"}))
+		require.NoError(t, chatRepo.SaveChatSettings(ctx, ChatSettings{ChatID: 2, DigestTime: "08:00"}))
+
+		day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+		digests, err := chatRepo.ClaimDigests(ctx, day.Add(8*time.Hour))
+		require.NoError(t, err)
+		assert.Empty(t, digests)
+
+		digests, err = chatRepo.ClaimDigests(ctx, day.Add(8*time.Hour+45*time.Minute))
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []Digest{{ChatID: -1, HomeCity: "Paris", Air: true}, {ChatID: 1, HomeCity: "Oslo"}}, digests)
+
+		// The claimed digest is not due again the same day.
+		digests, err = chatRepo.ClaimDigests(ctx, day.Add(10*time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, []Digest{{ChatID: -2, HomeCity: "Berlin"}}, digests)
+
+		digests, err = chatRepo.ClaimDigests(ctx, day.Add(23*time.Hour))
+		require.NoError(t, err)
+		assert.Empty(t, digests)
+
+		digests, err = chatRepo.ClaimDigests(ctx, day.AddDate(0, 0, 1).Add(9*time.Hour))
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []Digest{
+			{ChatID: -1, HomeCity: "Paris", Air: true},
+			{ChatID: -2, HomeCity: "Berlin"},
+			{ChatID: 1, HomeCity: "Oslo"},
+		}, digests)
+	})
+}
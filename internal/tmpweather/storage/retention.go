@@ -0,0 +1,224 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alukart32/tmp-weather/internal/pkg/otelx"
+	"github.com/alukart32/tmp-weather/internal/pkg/zerologx"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// RetentionConfig is the representation of forecast retention settings.
+type RetentionConfig struct {
+	// Age is the age of forecasts rolled up into daily aggregates and deleted, 0 disables retention.
+	// The statistics include the aggregates, the other queries lose the deleted forecasts.
+	Age time.Duration `yaml:"age" toml:"age" env:"RETENTION_AGE"`
+	// Interval is the period of the retention job.
+	Interval time.Duration `yaml:"interval" toml:"interval" env:"RETENTION_INTERVAL"`
+}
+
+// retentionLockID is the postgres advisory lock key of the retention job.
+const retentionLockID int64 = 0x746d7077_72657431 // "tmpwret1"
+
+// retentionBatchSize is the max number of forecasts pruned in one statement.
+const retentionBatchSize = 10000
+
+var meter = otelx.Meter("github.com/alukart32/tmp-weather/internal/tmpweather/storage")
+
+var retentionMetrics = newRetentionMetrics()
+
+// retentionMetricsSet holds the retention job instruments.
+type retentionMetricsSet struct {
+	runs   metric.Int64Counter
+	pruned metric.Int64Counter
+}
+
+func newRetentionMetrics() retentionMetricsSet {
+	var (
+		m   retentionMetricsSet
+		err error
+	)
+	m.runs, err = meter.Int64Counter("tmpweather.retention.runs",
+		metric.WithDescription("Retention job runs by result"))
+	if err != nil {
+		otel.Handle(err)
+	}
+	m.pruned, err = meter.Int64Counter("tmpweather.retention.pruned",
+		metric.WithDescription("Forecasts rolled up and deleted by the retention job"),
+		metric.WithUnit("{row}"))
+	if err != nil {
+		otel.Handle(err)
+	}
+	return m
+}
+
+// RetentionJob rolls up old forecasts into the daily per-city aggregates and deletes them.
+// The aggregates are read by Stat only.
+//
+// The job takes a postgres advisory lock, so only one App replica runs it at a time.
+type RetentionJob struct {
+	pool *pgxpool.Pool
+	cfg  RetentionConfig
+}
+
+// NewRetentionJob returns a new RetentionJob of the repository.
+func NewRetentionJob(repo *WeatherForecastRepo, cfg RetentionConfig) (*RetentionJob, error) {
+	if cfg.Age <= 0 {
+		return nil, fmt.Errorf("invalid retention age: %v", cfg.Age)
+	}
+	if cfg.Interval <= 0 {
+		return nil, fmt.Errorf("invalid retention interval: %v", cfg.Interval)
+	}
+
+	return &RetentionJob{
+		pool: repo.pool,
+		cfg:  cfg,
+	}, nil
+}
+
+// Run prunes forecasts at once and then every interval until ctx is done.
+func (j *RetentionJob) Run(ctx context.Context) {
+	logger := zerologx.Ctx(ctx)
+
+	ticker := time.NewTicker(j.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		pruned, locked, err := j.Prune(ctx)
+		switch {
+		case err != nil:
+			retentionMetrics.runs.Add(ctx, 1, metric.WithAttributes(attribute.String("result", "error")))
+			if ctx.Err() == nil {
+				logger.Error().
+					Str("op", "prune forecasts").
+					Int64("pruned", pruned).
+					Err(err).Send()
+			}
+		case !locked:
+			retentionMetrics.runs.Add(ctx, 1, metric.WithAttributes(attribute.String("result", "skipped")))
+			logger.Debug().
+				Str("op", "prune forecasts").
+				Msg("locked by another replica")
+		default:
+			retentionMetrics.runs.Add(ctx, 1, metric.WithAttributes(attribute.String("result", "ok")))
+			logger.Info().
+				Str("op", "prune forecasts").
+				Int64("pruned", pruned).Send()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+const tryRetentionLock = `SELECT pg_try_advisory_lock($1)`
+
+const releaseRetentionLock = `SELECT pg_advisory_unlock($1)`
+
+// pruneForecasts deletes the batch of forecasts made before $1 and adds them
+// to the daily aggregates of the lowercase city name, as the statistics group cities case-insensitively.
+// It returns the number of deleted forecasts.
+const pruneForecasts = `
+WITH pruned AS (
+  DELETE FROM
+    forecasts
+  WHERE
+    id IN (
+      SELECT id FROM forecasts WHERE made_at < $1 ORDER BY made_at LIMIT $2
+    )
+  RETURNING
+    city, temp, hum, wind, made_at
+),
+rolled_up AS (
+  INSERT INTO
+    forecasts_daily AS d (
+      day, city, count,
+      min_temp, max_temp, sum_temp,
+      min_hum, max_hum, sum_hum,
+      min_wind, max_wind, sum_wind
+    )
+  SELECT
+    (made_at AT TIME ZONE 'UTC')::date,
+    LOWER(city),
+    COUNT(*),
+    MIN(temp), MAX(temp), SUM(temp::double precision),
+    MIN(hum), MAX(hum), SUM(hum),
+    MIN(wind), MAX(wind), SUM(wind::double precision)
+  FROM
+    pruned
+  GROUP BY
+    1, 2
+  ON CONFLICT (day, city) DO UPDATE SET
+    count = d.count + EXCLUDED.count,
+    min_temp = LEAST(d.min_temp, EXCLUDED.min_temp),
+    max_temp = GREATEST(d.max_temp, EXCLUDED.max_temp),
+    sum_temp = d.sum_temp + EXCLUDED.sum_temp,
+    min_hum = LEAST(d.min_hum, EXCLUDED.min_hum),
+    max_hum = GREATEST(d.max_hum, EXCLUDED.max_hum),
+    sum_hum = d.sum_hum + EXCLUDED.sum_hum,
+    min_wind = LEAST(d.min_wind, EXCLUDED.min_wind),
+    max_wind = GREATEST(d.max_wind, EXCLUDED.max_wind),
+    sum_wind = d.sum_wind + EXCLUDED.sum_wind
+)
+SELECT COUNT(*) FROM pruned
+`
+
+// Prune rolls up and deletes the forecasts older than the retention age in batches.
+// It returns the number of deleted forecasts and false if another replica holds the lock.
+func (j *RetentionJob) Prune(ctx context.Context) (pruned int64, locked bool, err error) {
+	ctx, span := tracer.Start(ctx, "RetentionJob.Prune")
+	defer func() {
+		span.SetAttributes(
+			attribute.Int64("pruned", pruned),
+			attribute.Bool("locked", locked),
+		)
+		otelx.End(span, err)
+	}()
+
+	// The session lock is held by the connection, so all batches use it.
+	conn, err := j.pool.Acquire(ctx)
+	if err != nil {
+		return 0, false, err
+	}
+	defer conn.Release()
+
+	if err = conn.QueryRow(ctx, tryRetentionLock, retentionLockID).Scan(&locked); err != nil {
+		return 0, false, err
+	}
+	if !locked {
+		return 0, false, nil
+	}
+	defer func() {
+		// ctx may be done, but the lock must be released before the connection is reused.
+		unlockCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if _, unlockErr := conn.Exec(unlockCtx, releaseRetentionLock, retentionLockID); unlockErr != nil {
+			// Closing the connection releases the lock and drops it from the pool.
+			conn.Conn().Close(unlockCtx)
+			err = errors.Join(err, fmt.Errorf("release retention lock: %w", unlockErr))
+		}
+	}()
+
+	before := time.Now().Add(-j.cfg.Age)
+	for {
+		var n int64
+		if err = conn.QueryRow(ctx, pruneForecasts, before, retentionBatchSize).Scan(&n); err != nil {
+			return pruned, true, err
+		}
+		pruned += n
+		retentionMetrics.pruned.Add(ctx, n)
+
+		if n < retentionBatchSize {
+			return pruned, true, nil
+		}
+	}
+}
@@ -0,0 +1,809 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alukart32/tmp-weather/internal/pkg/otelx"
+	"github.com/alukart32/tmp-weather/internal/pkg/zerologx"
+	"go.opentelemetry.io/otel/attribute"
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteRepo defines the SQLite forecast repository.
+//
+// Timestamps are stored as unix nanoseconds.
+type SQLiteRepo struct {
+	db *sql.DB
+}
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS forecasts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    chat_id INTEGER NOT NULL DEFAULT 0,
+    msg_id INTEGER NOT NULL,
+    city TEXT NOT NULL CHECK(LENGTH(city) > 0),
+    description TEXT NOT NULL,
+    temp REAL NOT NULL,
+    hum INTEGER NOT NULL CHECK(hum > 0),
+    wind REAL NOT NULL CHECK(wind > 0),
+    made_at INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS forecasts_chat_id_id_idx ON forecasts (chat_id, id DESC);
+
+CREATE TABLE IF NOT EXISTS observations (
+    city TEXT NOT NULL CHECK(LENGTH(city) > 0),
+    observed_at INTEGER NOT NULL,
+    description TEXT NOT NULL,
+    temp REAL NOT NULL,
+    feels_like REAL NOT NULL,
+    hum INTEGER NOT NULL,
+    wind REAL NOT NULL,
+    PRIMARY KEY (city, observed_at)
+);
+
+CREATE TABLE IF NOT EXISTS favorites (
+    chat_id INTEGER NOT NULL,
+    city TEXT NOT NULL CHECK(LENGTH(city) > 0),
+    UNIQUE (chat_id, city COLLATE NOCASE)
+);
+
+CREATE TABLE IF NOT EXISTS chat_settings (
+    chat_id INTEGER PRIMARY KEY,
+    home_city TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS group_settings (
+    chat_id INTEGER PRIMARY KEY,
+    home_city TEXT NOT NULL DEFAULT '',
+    digest_time TEXT NOT NULL DEFAULT '',
+    commands TEXT NOT NULL DEFAULT ''
+);
+`
+
+// sqliteAddedColumns are the columns added to the existing tables after their creation.
+var sqliteAddedColumns = []struct {
+	table, column, definition string
+}{
+	{"forecasts", "country", "TEXT NOT NULL DEFAULT ''"},
+	{"forecasts", "condition_id", "INTEGER NOT NULL DEFAULT 0"},
+	{"forecasts", "icon", "TEXT NOT NULL DEFAULT ''"},
+	{"forecasts", "feels_like", "REAL NOT NULL DEFAULT 0"},
+	{"forecasts", "pressure", "INTEGER NOT NULL DEFAULT 0"},
+	{"forecasts", "visibility", "INTEGER NOT NULL DEFAULT 0"},
+	{"forecasts", "clouds", "INTEGER NOT NULL DEFAULT 0"},
+	{"forecasts", "wind_deg", "INTEGER NOT NULL DEFAULT 0"},
+	{"forecasts", "wind_gust", "REAL NOT NULL DEFAULT 0"},
+	{"forecasts", "rain", "REAL NOT NULL DEFAULT 0"},
+	{"forecasts", "snow", "REAL NOT NULL DEFAULT 0"},
+	{"group_settings", "digest_sent_on", "TEXT NOT NULL DEFAULT ''"},
+	{"chat_settings", "digest_time", "TEXT NOT NULL DEFAULT ''"},
+	{"chat_settings", "digest_sent_on", "TEXT NOT NULL DEFAULT ''"},
+	{"group_settings", "digest_air", "INTEGER NOT NULL DEFAULT 0"},
+	{"chat_settings", "digest_air", "INTEGER NOT NULL DEFAULT 0"},
+}
+
+// NewSQLiteRepo opens the SQLite database file and returns a new SQLiteRepo.
+// The database schema is created if it does not exist.
+func NewSQLiteRepo(path string) (*SQLiteRepo, error) {
+	if len(path) == 0 {
+		return nil, fmt.Errorf("sqlite path is empty")
+	}
+
+	dsn := fmt.Sprintf("file:%s?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)", path)
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open sqlite: %w", err)
+	}
+	// SQLite allows a single writer.
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create sqlite schema: %w", err)
+	}
+	if err := addSQLiteColumns(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("update sqlite schema: %w", err)
+	}
+
+	return &SQLiteRepo{db: db}, nil
+}
+
+// addSQLiteColumns adds the missing sqliteAddedColumns, SQLite has no ADD COLUMN IF NOT EXISTS.
+func addSQLiteColumns(db *sql.DB) error {
+	for _, c := range sqliteAddedColumns {
+		var n int
+		err := db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, c.table, c.column).Scan(&n)
+		if err != nil {
+			return err
+		}
+		if n != 0 {
+			continue
+		}
+		if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", c.table, c.column, c.definition)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the database.
+func (r *SQLiteRepo) Close() error {
+	return r.db.Close()
+}
+
+const sqliteInsertWeatherForecast = `
+INSERT INTO
+	forecasts(chat_id, msg_id, city, country, description, condition_id, icon,
+		temp, feels_like, hum, pressure, visibility, clouds, wind, wind_deg, wind_gust, rain, snow, made_at)
+VALUES
+	(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+`
+
+// Insert adds a new weather forecast data.
+func (r *SQLiteRepo) Insert(ctx context.Context, f WeatherForecast) (err error) {
+	ctx, span := tracer.Start(ctx, "SQLiteRepo.Insert")
+	span.SetAttributes(attribute.String("city", f.City))
+	defer func() { otelx.End(span, err) }()
+
+	logger := zerologx.Ctx(ctx)
+	logger.Debug().
+		Str("op", "insert forecast").
+		Str("city", f.City).Send()
+
+	_, err = r.db.ExecContext(ctx, sqliteInsertWeatherForecast, forecastValues(f, f.MadeAt.UnixNano())...)
+	return err
+}
+
+// InsertBatch adds the weather forecasts in one transaction.
+func (r *SQLiteRepo) InsertBatch(ctx context.Context, fs []WeatherForecast) (err error) {
+	ctx, span := tracer.Start(ctx, "SQLiteRepo.InsertBatch")
+	span.SetAttributes(attribute.Int("batch.size", len(fs)))
+	defer func() { otelx.End(span, err) }()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("unable to start transaction: %v", err.Error())
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	stmt, err := tx.PrepareContext(ctx, sqliteInsertWeatherForecast)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, f := range fs {
+		_, err = stmt.ExecContext(ctx, forecastValues(f, f.MadeAt.UnixNano())...)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sqliteWindowedForecasts selects forecasts by the optional since (?1) and city (?2) filters.
+const sqliteWindowedForecasts = `
+WITH windowed AS (
+  SELECT
+    *
+  FROM
+    forecasts
+  WHERE
+    (?1 IS NULL OR made_at >= ?1)
+    AND (?2 = '' OR LOWER(city) = LOWER(?2))
+)
+`
+
+const sqliteGetWeatherForecastStat = sqliteWindowedForecasts + `
+SELECT
+  first_record.made_at,
+  total_records.count,
+  top_temp.city,
+  top_temp.temp,
+  top_hum.city,
+  top_hum.hum,
+  top_wind.city,
+  top_wind.wind
+FROM
+  (SELECT COUNT(*) AS count FROM windowed) AS total_records,
+  (SELECT made_at FROM windowed ORDER BY made_at ASC LIMIT 1) AS first_record,
+  (SELECT city, ROUND(temp, 2) AS temp FROM windowed ORDER BY temp DESC LIMIT 1) AS top_temp,
+  (SELECT city, hum FROM windowed ORDER BY hum DESC LIMIT 1) AS top_hum,
+  (SELECT city, ROUND(wind, 2) AS wind FROM windowed ORDER BY wind DESC LIMIT 1) AS top_wind
+`
+
+const sqliteGetWeatherForecastCityStat = sqliteWindowedForecasts + `
+SELECT
+  MIN(city),
+  COUNT(*) AS count,
+  ROUND(MIN(temp), 2),
+  ROUND(AVG(temp), 2),
+  ROUND(MAX(temp), 2),
+  MIN(hum),
+  ROUND(AVG(hum), 2),
+  MAX(hum),
+  ROUND(MIN(wind), 2),
+  ROUND(AVG(wind), 2),
+  ROUND(MAX(wind), 2)
+FROM
+  windowed
+GROUP BY
+  LOWER(city)
+ORDER BY
+  count DESC,
+  LOWER(city) ASC
+LIMIT ?3
+`
+
+// Stat returns the weather forecast statistics.
+func (r *SQLiteRepo) Stat(ctx context.Context, q StatQuery) (stat WeatherForecastStat, err error) {
+	ctx, span := tracer.Start(ctx, "SQLiteRepo.Stat")
+	span.SetAttributes(attribute.String("city", q.City))
+	defer func() { otelx.End(span, err) }()
+
+	logger := zerologx.Ctx(ctx)
+	logger.Debug().
+		Str("op", "stat forecasts").
+		Str("city", q.City).
+		Time("since", q.Since).Send()
+
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return WeatherForecastStat{}, fmt.Errorf("unable to start transaction: %v", err.Error())
+	}
+	defer tx.Rollback()
+
+	since := nullUnixNano(q.Since)
+	stat.since = q.Since
+
+	// Get main forecast stat.
+	var firstRecordAt int64
+	err = tx.QueryRowContext(ctx, sqliteGetWeatherForecastStat, since, q.City).Scan(
+		&firstRecordAt,
+		&stat.total,
+		&stat.TopRecords.maxTempCity,
+		&stat.TopRecords.maxTemp,
+		&stat.TopRecords.maxHumCity,
+		&stat.TopRecords.maxHum,
+		&stat.TopRecords.maxWindCity,
+		&stat.TopRecords.maxWind,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = ErrNoData
+		}
+		return stat, err
+	}
+	stat.firstRecordAt = time.Unix(0, firstRecordAt)
+
+	// Get the most requested cities stat.
+	rows, err := tx.QueryContext(ctx, sqliteGetWeatherForecastCityStat, since, q.City, statTopCities)
+	if err != nil {
+		return stat, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c CityStat
+		err = rows.Scan(
+			&c.city,
+			&c.count,
+			&c.minTemp,
+			&c.avgTemp,
+			&c.maxTemp,
+			&c.minHum,
+			&c.avgHum,
+			&c.maxHum,
+			&c.minWind,
+			&c.avgWind,
+			&c.maxWind,
+		)
+		if err != nil {
+			return stat, err
+		}
+		stat.cities = append(stat.cities, c)
+	}
+
+	return stat, rows.Err()
+}
+
+const sqliteGetWeatherForecastHistoryOlder = `
+SELECT
+	` + forecastSelectColumns + `
+FROM
+	forecasts
+WHERE
+	chat_id = ?1
+	AND (?2 = '' OR city = ?2 COLLATE NOCASE)
+	AND (?3 = 0 OR id < ?3)
+ORDER BY
+	id DESC
+LIMIT ?4
+`
+
+const sqliteGetWeatherForecastHistoryNewer = `
+SELECT
+	` + forecastSelectColumns + `
+FROM
+	forecasts
+WHERE
+	chat_id = ?1
+	AND (?2 = '' OR city = ?2 COLLATE NOCASE)
+	AND id > ?3
+ORDER BY
+	id ASC
+LIMIT ?4
+`
+
+// History returns the page of the chat forecast history.
+func (r *SQLiteRepo) History(ctx context.Context, q HistoryQuery) (page HistoryPage, err error) {
+	ctx, span := tracer.Start(ctx, "SQLiteRepo.History")
+	span.SetAttributes(
+		attribute.Int64("chat.id", q.ChatID),
+		attribute.String("city", q.City),
+	)
+	defer func() { otelx.End(span, err) }()
+
+	if q.Limit <= 0 {
+		return HistoryPage{}, fmt.Errorf("invalid history limit: %d", q.Limit)
+	}
+
+	// Query one more record to know if there is a next page.
+	query, cursor := sqliteGetWeatherForecastHistoryOlder, q.Before
+	if q.After > 0 {
+		query, cursor = sqliteGetWeatherForecastHistoryNewer, q.After
+	}
+	rows, err := r.db.QueryContext(ctx, query, q.ChatID, q.City, cursor, q.Limit+1)
+	if err != nil {
+		return HistoryPage{}, err
+	}
+	defer rows.Close()
+
+	forecasts, err := scanSQLiteForecasts(rows)
+	if err != nil {
+		return HistoryPage{}, err
+	}
+
+	return newHistoryPage(q, forecasts)
+}
+
+const sqliteGetCityForecasts = `
+SELECT
+	` + forecastSelectColumns + `
+FROM
+	forecasts
+WHERE
+	LOWER(city) = LOWER(?1)
+	AND (?2 IS NULL OR made_at >= ?2)
+	AND (?3 IS NULL OR made_at < ?3)
+ORDER BY
+	made_at ASC
+`
+
+// CityForecasts returns the forecasts of the city ordered by time.
+func (r *SQLiteRepo) CityForecasts(ctx context.Context, q CityQuery) (forecasts []WeatherForecast, err error) {
+	ctx, span := tracer.Start(ctx, "SQLiteRepo.CityForecasts")
+	span.SetAttributes(attribute.String("city", q.City))
+	defer func() { otelx.End(span, err) }()
+
+	rows, err := r.db.QueryContext(ctx, sqliteGetCityForecasts, q.City, nullUnixNano(q.Since), nullUnixNano(q.Until))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	forecasts, err = scanSQLiteForecasts(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(forecasts) == 0 {
+		return nil, ErrNoData
+	}
+	return forecasts, nil
+}
+
+const sqliteExportForecasts = `
+SELECT
+	` + forecastSelectColumns + `
+FROM
+	forecasts
+WHERE
+	chat_id = ?1
+	AND (?2 IS NULL OR made_at >= ?2)
+ORDER BY
+	id ASC
+`
+
+// ExportForecasts calls fn for each chat forecast oldest-first as the rows are read.
+// It stops at the first fn error and returns it.
+func (r *SQLiteRepo) ExportForecasts(ctx context.Context, q ExportQuery, fn func(WeatherForecast) error) (err error) {
+	ctx, span := tracer.Start(ctx, "SQLiteRepo.ExportForecasts")
+	span.SetAttributes(attribute.Int64("chat.id", q.ChatID))
+	defer func() { otelx.End(span, err) }()
+
+	rows, err := r.db.QueryContext(ctx, sqliteExportForecasts, q.ChatID, nullUnixNano(q.Since))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			f      WeatherForecast
+			madeAt int64
+		)
+		err = rows.Scan(forecastScanFields(&f, &madeAt)...)
+		if err != nil {
+			return err
+		}
+		f.MadeAt = time.Unix(0, madeAt)
+		if err = fn(f); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// scanSQLiteForecasts scans the forecasts rows.
+func scanSQLiteForecasts(rows *sql.Rows) ([]WeatherForecast, error) {
+	var forecasts []WeatherForecast
+	for rows.Next() {
+		var (
+			f      WeatherForecast
+			madeAt int64
+		)
+		err := rows.Scan(forecastScanFields(&f, &madeAt)...)
+		if err != nil {
+			return nil, err
+		}
+		f.MadeAt = time.Unix(0, madeAt)
+		forecasts = append(forecasts, f)
+	}
+	return forecasts, rows.Err()
+}
+
+const sqliteInsertObservation = `
+INSERT OR IGNORE INTO
+	observations(city, observed_at, description, temp, feels_like, hum, wind)
+VALUES
+	(?, ?, ?, ?, ?, ?, ?)
+`
+
+// InsertObservations adds the observations, already stored ones are skipped.
+func (r *SQLiteRepo) InsertObservations(ctx context.Context, obs []Observation) (err error) {
+	ctx, span := tracer.Start(ctx, "SQLiteRepo.InsertObservations")
+	span.SetAttributes(attribute.Int("batch.size", len(obs)))
+	defer func() { otelx.End(span, err) }()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("unable to start transaction: %v", err.Error())
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	for _, o := range obs {
+		_, err = tx.ExecContext(ctx, sqliteInsertObservation,
+			o.City, o.ObservedAt.UnixNano(), o.Desc, o.Temp, o.FeelsLike, o.Hum, o.Wind)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const sqliteGetObservations = `
+SELECT
+	city, observed_at, description, temp, feels_like, hum, wind
+FROM
+	observations
+WHERE
+	LOWER(city) = LOWER(?1)
+	AND (?2 IS NULL OR observed_at >= ?2)
+	AND (?3 IS NULL OR observed_at < ?3)
+ORDER BY
+	observed_at ASC
+`
+
+// Observations returns the city observations ordered by time.
+func (r *SQLiteRepo) Observations(ctx context.Context, q CityQuery) (obs []Observation, err error) {
+	ctx, span := tracer.Start(ctx, "SQLiteRepo.Observations")
+	span.SetAttributes(attribute.String("city", q.City))
+	defer func() { otelx.End(span, err) }()
+
+	rows, err := r.db.QueryContext(ctx, sqliteGetObservations, q.City, nullUnixNano(q.Since), nullUnixNano(q.Until))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			o          Observation
+			observedAt int64
+		)
+		if err = rows.Scan(&o.City, &observedAt, &o.Desc, &o.Temp, &o.FeelsLike, &o.Hum, &o.Wind); err != nil {
+			return nil, err
+		}
+		o.ObservedAt = time.Unix(0, observedAt)
+		obs = append(obs, o)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(obs) == 0 {
+		return nil, ErrNoData
+	}
+	return obs, nil
+}
+
+const sqliteInsertFavorite = `
+INSERT OR IGNORE INTO
+	favorites(chat_id, city)
+VALUES
+	(?, ?)
+`
+
+// AddFavorite adds the city to the chat favorites, an already added city is skipped.
+func (r *SQLiteRepo) AddFavorite(ctx context.Context, chatID int64, city string) (err error) {
+	ctx, span := tracer.Start(ctx, "SQLiteRepo.AddFavorite")
+	span.SetAttributes(
+		attribute.Int64("chat.id", chatID),
+		attribute.String("city", city),
+	)
+	defer func() { otelx.End(span, err) }()
+
+	if len(city) == 0 {
+		return errEmptyCity
+	}
+	_, err = r.db.ExecContext(ctx, sqliteInsertFavorite, chatID, city)
+	return err
+}
+
+const sqliteDeleteFavorite = `
+DELETE FROM
+	favorites
+WHERE
+	chat_id = ?
+	AND city = ? COLLATE NOCASE
+`
+
+// RemoveFavorite removes the city from the chat favorites.
+// ErrNoData is returned if the city is not a favorite one.
+func (r *SQLiteRepo) RemoveFavorite(ctx context.Context, chatID int64, city string) (err error) {
+	ctx, span := tracer.Start(ctx, "SQLiteRepo.RemoveFavorite")
+	span.SetAttributes(
+		attribute.Int64("chat.id", chatID),
+		attribute.String("city", city),
+	)
+	defer func() { otelx.End(span, err) }()
+
+	res, err := r.db.ExecContext(ctx, sqliteDeleteFavorite, chatID, city)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoData
+	}
+	return nil
+}
+
+const sqliteGetFavorites = `
+SELECT
+	city
+FROM
+	favorites
+WHERE
+	chat_id = ?
+ORDER BY
+	rowid ASC
+`
+
+// Favorites returns the chat favorite cities in the order they were added.
+func (r *SQLiteRepo) Favorites(ctx context.Context, chatID int64) (cities []string, err error) {
+	ctx, span := tracer.Start(ctx, "SQLiteRepo.Favorites")
+	span.SetAttributes(attribute.Int64("chat.id", chatID))
+	defer func() { otelx.End(span, err) }()
+
+	rows, err := r.db.QueryContext(ctx, sqliteGetFavorites, chatID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var city string
+		if err := rows.Scan(&city); err != nil {
+			return nil, err
+		}
+		cities = append(cities, city)
+	}
+	return cities, rows.Err()
+}
+
+const sqliteGetChatSettings = `
+SELECT
+	home_city, digest_time, digest_air
+FROM
+	chat_settings
+WHERE
+	chat_id = ?
+`
+
+// ChatSettings returns the chat settings, the zero settings if they are not saved.
+func (r *SQLiteRepo) ChatSettings(ctx context.Context, chatID int64) (s ChatSettings, err error) {
+	ctx, span := tracer.Start(ctx, "SQLiteRepo.ChatSettings")
+	span.SetAttributes(attribute.Int64("chat.id", chatID))
+	defer func() { otelx.End(span, err) }()
+
+	s.ChatID = chatID
+	err = r.db.QueryRowContext(ctx, sqliteGetChatSettings, chatID).Scan(&s.HomeCity, &s.DigestTime, &s.DigestAir)
+	if errors.Is(err, sql.ErrNoRows) {
+		return s, nil
+	}
+	return s, err
+}
+
+const sqliteUpsertChatSettings = `
+INSERT INTO
+	chat_settings(chat_id, home_city, digest_time, digest_air)
+VALUES
+	(?1, ?2, ?3, ?4)
+ON CONFLICT (chat_id) DO UPDATE SET
+	home_city = ?2,
+	digest_time = ?3,
+	digest_air = ?4
+`
+
+// SaveChatSettings adds or replaces the chat settings.
+func (r *SQLiteRepo) SaveChatSettings(ctx context.Context, s ChatSettings) (err error) {
+	ctx, span := tracer.Start(ctx, "SQLiteRepo.SaveChatSettings")
+	span.SetAttributes(attribute.Int64("chat.id", s.ChatID))
+	defer func() { otelx.End(span, err) }()
+
+	_, err = r.db.ExecContext(ctx, sqliteUpsertChatSettings, s.ChatID, s.HomeCity, s.DigestTime, s.DigestAir)
+	return err
+}
+
+const sqliteGetGroupSettings = `
+SELECT
+	home_city, digest_time, digest_air, commands
+FROM
+	group_settings
+WHERE
+	chat_id = ?
+`
+
+// GroupSettings returns the group chat settings, the zero settings if they are not saved.
+// The commands are stored comma-separated.
+func (r *SQLiteRepo) GroupSettings(ctx context.Context, chatID int64) (s GroupSettings, err error) {
+	ctx, span := tracer.Start(ctx, "SQLiteRepo.GroupSettings")
+	span.SetAttributes(attribute.Int64("chat.id", chatID))
+	defer func() { otelx.End(span, err) }()
+
+	s.ChatID = chatID
+	var commands string
+	err = r.db.QueryRowContext(ctx, sqliteGetGroupSettings, chatID).Scan(&s.HomeCity, &s.DigestTime, &s.DigestAir, &commands)
+	if errors.Is(err, sql.ErrNoRows) {
+		return s, nil
+	}
+	if len(commands) != 0 {
+		s.Commands = strings.Split(commands, ",")
+	}
+	return s, err
+}
+
+const sqliteUpsertGroupSettings = `
+INSERT INTO
+	group_settings(chat_id, home_city, digest_time, digest_air, commands)
+VALUES
+	(?1, ?2, ?3, ?4, ?5)
+ON CONFLICT (chat_id) DO UPDATE SET
+	home_city = ?2,
+	digest_time = ?3,
+	digest_air = ?4,
+	commands = ?5
+`
+
+// SaveGroupSettings adds or replaces the group chat settings.
+func (r *SQLiteRepo) SaveGroupSettings(ctx context.Context, s GroupSettings) (err error) {
+	ctx, span := tracer.Start(ctx, "SQLiteRepo.SaveGroupSettings")
+	span.SetAttributes(attribute.Int64("chat.id", s.ChatID))
+	defer func() { otelx.End(span, err) }()
+
+	_, err = r.db.ExecContext(ctx, sqliteUpsertGroupSettings,
+		s.ChatID, s.HomeCity, s.DigestTime, s.DigestAir, strings.Join(s.Commands, ","))
+	return err
+}
+
+// sqliteClaimDigests claims the due digests of the settings table formatted into it.
+const sqliteClaimDigests = `
+UPDATE
+	%s
+SET
+	digest_sent_on = ?2
+WHERE
+	digest_time <> ''
+	AND digest_time <= ?1
+	AND home_city <> ''
+	AND digest_sent_on < ?2
+RETURNING
+	chat_id, home_city, digest_air
+`
+
+// ClaimDigests returns the group and private chat digests due at now and marks them as sent
+// on the now UTC date, so a digest is claimed once a day. The digest is due since its UTC time
+// if the home city is set. The sent date is stored as YYYY-MM-DD, empty if the digest was never sent.
+func (r *SQLiteRepo) ClaimDigests(ctx context.Context, now time.Time) (digests []Digest, err error) {
+	ctx, span := tracer.Start(ctx, "SQLiteRepo.ClaimDigests")
+	defer func() { otelx.End(span, err) }()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to start transaction: %v", err.Error())
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	clock, date := digestDue(now)
+	for _, table := range []string{"group_settings", "chat_settings"} {
+		digests, err = claimSQLiteDigests(ctx, tx, table, clock, date, digests)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return digests, nil
+}
+
+// claimSQLiteDigests claims the due digests of the table and appends them to digests.
+func claimSQLiteDigests(ctx context.Context, tx *sql.Tx, table, clock, date string, digests []Digest) ([]Digest, error) {
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf(sqliteClaimDigests, table), clock, date)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var d Digest
+		if err := rows.Scan(&d.ChatID, &d.HomeCity, &d.Air); err != nil {
+			return nil, err
+		}
+		digests = append(digests, d)
+	}
+	return digests, rows.Err()
+}
+
+// nullUnixNano returns the time as unix nanoseconds or NULL for the zero time.
+func nullUnixNano(t time.Time) sql.NullInt64 {
+	if t.IsZero() {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: t.UnixNano(), Valid: true}
+}
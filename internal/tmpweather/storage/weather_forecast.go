@@ -1,6 +1,7 @@
 // Package storage provides predictive data storage functionality.
 //
-// PostgreSQL is the default repository driver.
+// PostgreSQL is the default repository driver. SQLite and in-memory drivers
+// are intended for small deployments and tests.
 package storage
 
 import (
@@ -8,20 +9,23 @@ import (
 	"errors"
 	"fmt"
 	"strings"
-	"sync"
 	"time"
 
+	"github.com/alukart32/tmp-weather/internal/pkg/otelx"
+	"github.com/alukart32/tmp-weather/internal/pkg/zerologx"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 var ErrNoData = errors.New("no data")
 
-// WeatherForecastRepo defines the forecast repository.
+var tracer = otelx.Tracer("github.com/alukart32/tmp-weather/internal/tmpweather/storage")
+
+// WeatherForecastRepo defines the PostgreSQL forecast repository.
 type WeatherForecastRepo struct {
 	pool *pgxpool.Pool
-	mtx  sync.Mutex
 }
 
 // NewWeatherForecastRepo returns a new ForecastRepo.
@@ -37,24 +41,74 @@ func NewWeatherForecastRepo(pool *pgxpool.Pool) (*WeatherForecastRepo, error) {
 
 // WeatherForecast represents the weather forecast that is stored in the repository.
 type WeatherForecast struct {
-	MadeAt time.Time
-	City   string
-	Desc   string
-	Temp   float64
-	Hum    int64
-	Wind   float64
-	MsgID  int
+	ID          int64
+	ChatID      int64
+	MadeAt      time.Time
+	City        string
+	Country     string
+	Desc        string
+	ConditionID int64 // https://openweathermap.org/weather-conditions
+	Icon        string
+	Temp        float64
+	FeelsLike   float64
+	Hum         int64
+	Pressure    int64 // hPa
+	Visibility  int64 // m
+	Clouds      int64 // %
+	Wind        float64
+	WindDeg     int64
+	WindGust    float64
+	Rain        float64 // mm for the last hour
+	Snow        float64 // mm for the last hour
+	MsgID       int
+}
+
+// forecastColumns are the columns of the forecasts table written by Insert and InsertBatch.
+var forecastColumns = []string{
+	"chat_id", "msg_id", "city", "country", "description", "condition_id", "icon",
+	"temp", "feels_like", "hum", "pressure", "visibility", "clouds", "wind", "wind_deg", "wind_gust", "rain", "snow", "made_at",
+}
+
+// forecastSelectColumns are the columns of the forecasts table read into WeatherForecast.
+const forecastSelectColumns = `id, chat_id, msg_id, city, country, description, condition_id, icon,
+	temp, feels_like, hum, pressure, visibility, clouds, wind, wind_deg, wind_gust, rain, snow, made_at`
+
+// forecastValues returns the values of forecastColumns, madeAt is the made_at value of the driver.
+func forecastValues(f WeatherForecast, madeAt any) []any {
+	return []any{
+		f.ChatID, f.MsgID, f.City, f.Country, f.Desc, f.ConditionID, f.Icon,
+		f.Temp, f.FeelsLike, f.Hum, f.Pressure, f.Visibility, f.Clouds, f.Wind, f.WindDeg, f.WindGust, f.Rain, f.Snow, madeAt,
+	}
+}
+
+// forecastScanFields returns the scan destinations of forecastSelectColumns,
+// madeAt is the made_at destination of the driver.
+func forecastScanFields(f *WeatherForecast, madeAt any) []any {
+	return []any{
+		&f.ID, &f.ChatID, &f.MsgID, &f.City, &f.Country, &f.Desc, &f.ConditionID, &f.Icon,
+		&f.Temp, &f.FeelsLike, &f.Hum, &f.Pressure, &f.Visibility, &f.Clouds, &f.Wind, &f.WindDeg, &f.WindGust, &f.Rain, &f.Snow, madeAt,
+	}
 }
 
 const upsertWeatherForecast = `
 INSERT INTO
-	forecasts(msg_id, city, description, temp, hum, wind, made_at)
+	forecasts(chat_id, msg_id, city, country, description, condition_id, icon,
+		temp, feels_like, hum, pressure, visibility, clouds, wind, wind_deg, wind_gust, rain, snow, made_at)
 VALUES
-	($1, $2, $3, $4, $5, $6, $7)
+	($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19)
 `
 
 // Insert adds a new weather forecast data.
-func (r *WeatherForecastRepo) Insert(ctx context.Context, f WeatherForecast) error {
+func (r *WeatherForecastRepo) Insert(ctx context.Context, f WeatherForecast) (err error) {
+	ctx, span := tracer.Start(ctx, "WeatherForecastRepo.Insert")
+	span.SetAttributes(attribute.String("city", f.City))
+	defer func() { otelx.End(span, err) }()
+
+	logger := zerologx.Ctx(ctx)
+	logger.Debug().
+		Str("op", "insert forecast").
+		Str("city", f.City).Send()
+
 	tx, err := r.pool.BeginTx(ctx, pgx.TxOptions{
 		IsoLevel:       pgx.ReadCommitted,
 		AccessMode:     pgx.ReadWrite,
@@ -68,94 +122,264 @@ func (r *WeatherForecastRepo) Insert(ctx context.Context, f WeatherForecast) err
 		err = r.finishTransaction(ctx, tx, err)
 	}()
 
-	_, err = tx.Exec(ctx, upsertWeatherForecast,
-		f.MsgID,
-		f.City,
-		f.Desc,
-		f.Temp,
-		f.Hum,
-		f.Wind,
-		f.MadeAt,
-	)
+	_, err = tx.Exec(ctx, upsertWeatherForecast, forecastValues(f, f.MadeAt)...)
+
+	return err
+}
 
+// InsertBatch adds the weather forecasts at once with the COPY protocol.
+func (r *WeatherForecastRepo) InsertBatch(ctx context.Context, fs []WeatherForecast) (err error) {
+	ctx, span := tracer.Start(ctx, "WeatherForecastRepo.InsertBatch")
+	span.SetAttributes(attribute.Int("batch.size", len(fs)))
+	defer func() { otelx.End(span, err) }()
+
+	_, err = r.pool.CopyFrom(ctx, pgx.Identifier{"forecasts"}, forecastColumns,
+		pgx.CopyFromSlice(len(fs), func(i int) ([]any, error) {
+			return forecastValues(fs[i], fs[i].MadeAt), nil
+		}),
+	)
 	return err
 }
 
+// StatQuery defines the weather forecast statistics filter.
+type StatQuery struct {
+	City  string    // optional city, case-insensitive
+	Since time.Time // optional start of the time window
+}
+
 // WeatherForecastStat represents the weather forecast statistics.
 type WeatherForecastStat struct {
 	TopRecords struct {
-		city    string
-		maxTemp float64
+		maxTempCity string
+		maxTemp     float64
+		maxHumCity  string
+		maxHum      int64
+		maxWindCity string
+		maxWind     float64
 	}
+	cities        []CityStat
+	since         time.Time
 	firstRecordAt time.Time
 	total         int
 }
 
+// CityStat represents the weather forecast statistics of the city.
+type CityStat struct {
+	city    string
+	count   int
+	minTemp float64
+	avgTemp float64
+	maxTemp float64
+	minHum  int64
+	avgHum  float64
+	maxHum  int64
+	minWind float64
+	avgWind float64
+	maxWind float64
+}
+
 // ToMsg converts the ForecastStat data to the msg format of the telegram bot.
 func (f WeatherForecastStat) ToMsg() string {
 	var sb strings.Builder
 
-	fmt.Fprint(&sb, "Total\n")
+	if f.since.IsZero() {
+		fmt.Fprint(&sb, "Total\n")
+	} else {
+		fmt.Fprintf(&sb, "Since %v\n", f.since.Format(time.RFC822))
+	}
 	fmt.Fprintf(&sb, "\t\trecords: %d\n", f.total)
 	fmt.Fprintf(&sb, "\t\t1st at: %v\n\n", f.firstRecordAt.Format(time.RFC822))
 	fmt.Fprintf(&sb, "Top forecast\n")
-	fmt.Fprintf(&sb, "\t\tcity: %v\n", f.TopRecords.city)
-	fmt.Fprintf(&sb, "\t\ttemp: %.2f C\n", f.TopRecords.maxTemp)
+	fmt.Fprintf(&sb, "\t\ttemp: %.2f C, %v\n", f.TopRecords.maxTemp, f.TopRecords.maxTempCity)
+	fmt.Fprintf(&sb, "\t\thum: %d %%, %v\n", f.TopRecords.maxHum, f.TopRecords.maxHumCity)
+	fmt.Fprintf(&sb, "\t\twind: %.2f m/s, %v\n", f.TopRecords.maxWind, f.TopRecords.maxWindCity)
+
+	if len(f.cities) != 0 {
+		fmt.Fprintf(&sb, "\nMost requested\n")
+	}
+	for _, c := range f.cities {
+		fmt.Fprintf(&sb, "%v: %d\n", c.city, c.count)
+		fmt.Fprintf(&sb, "\t\ttemp: %.2f / %.2f / %.2f C\n", c.minTemp, c.avgTemp, c.maxTemp)
+		fmt.Fprintf(&sb, "\t\thum: %d / %.0f / %d %%\n", c.minHum, c.avgHum, c.maxHum)
+		fmt.Fprintf(&sb, "\t\twind: %.2f / %.2f / %.2f m/s\n", c.minWind, c.avgWind, c.maxWind)
+	}
+	if len(f.cities) != 0 {
+		fmt.Fprintf(&sb, "\n(min / avg / max)\n")
+	}
 
 	return sb.String()
 }
 
 // MarshalZerologObject adds ForecastStat to the logger as an object.
 func (f WeatherForecastStat) MarshalZerologObject(e *zerolog.Event) {
+	cities := zerolog.Arr()
+	for _, c := range f.cities {
+		cities.Dict(zerolog.Dict().
+			Str("city", c.city).
+			Int("count", c.count).
+			Float64("avgTemp", c.avgTemp).
+			Float64("avgHum", c.avgHum).
+			Float64("avgWind", c.avgWind))
+	}
+
 	e.
 		Int("total", f.total).
+		Time("since", f.since).
 		Time("firstRecordAt", f.firstRecordAt).
 		Dict("topRecord", zerolog.Dict().
-			Str("city", f.TopRecords.city).
-			Float64("temp", f.TopRecords.maxTemp))
+			Str("tempCity", f.TopRecords.maxTempCity).
+			Float64("temp", f.TopRecords.maxTemp).
+			Str("humCity", f.TopRecords.maxHumCity).
+			Int64("hum", f.TopRecords.maxHum).
+			Str("windCity", f.TopRecords.maxWindCity).
+			Float64("wind", f.TopRecords.maxWind)).
+		Array("cities", cities)
 }
 
-const getWeatherForecastStat = `
+// statTopCities is the number of the most requested cities in the statistics.
+const statTopCities = 5
+
+// windowedForecasts selects the forecasts and the daily rollups of the pruned forecasts
+// by the optional since ($1) and city ($2) filters. Each row is an aggregate: a forecast is
+// the aggregate of one record. The rollup of the since day is selected as a whole.
+const windowedForecasts = `
+WITH windowed AS (
+  SELECT
+    city,
+    1::bigint AS count,
+    made_at AS first_at,
+    temp::double precision AS min_temp,
+    temp::double precision AS max_temp,
+    temp::double precision AS sum_temp,
+    hum::bigint AS min_hum,
+    hum::bigint AS max_hum,
+    hum::bigint AS sum_hum,
+    wind::double precision AS min_wind,
+    wind::double precision AS max_wind,
+    wind::double precision AS sum_wind
+  FROM
+    forecasts
+  WHERE
+    ($1::timestamptz IS NULL OR made_at >= $1)
+    AND ($2::text = '' OR LOWER(city) = LOWER($2))
+  UNION ALL
+  SELECT
+    city,
+    count,
+    day::timestamp AT TIME ZONE 'UTC',
+    min_temp::double precision,
+    max_temp::double precision,
+    sum_temp,
+    min_hum::bigint,
+    max_hum::bigint,
+    sum_hum,
+    min_wind::double precision,
+    max_wind::double precision,
+    sum_wind
+  FROM
+    forecasts_daily
+  WHERE
+    ($1::timestamptz IS NULL OR day >= ($1::timestamptz AT TIME ZONE 'UTC')::date)
+    AND ($2::text = '' OR city = LOWER($2))
+)
+`
+
+const getWeatherForecastStat = windowedForecasts + `
 SELECT
-  first_record.made_at AS first_made,
+  first_record.first_at AS first_made,
   total_records.count AS total,
   top_temp.city,
-  top_temp.max_temp
+  top_temp.temp,
+  top_hum.city,
+  top_hum.hum,
+  top_wind.city,
+  top_wind.wind
 FROM
   (
-  	SELECT
-   	  COUNT(*) AS count
-	FROM
-   	  forecasts
+    SELECT
+      SUM(count)::bigint AS count
+    FROM
+      windowed
   ) AS total_records,
   (
-	SELECT
-	  made_at
-	FROM
-	  forecasts
-	ORDER BY
-	  made_at ASC
-	LIMIT 1
+    SELECT
+      first_at
+    FROM
+      windowed
+    ORDER BY
+      first_at ASC
+    LIMIT 1
   ) AS first_record,
   (
-	SELECT
+    SELECT
+      city,
+      max_temp::numeric(10, 2) AS temp
+    FROM
+      windowed
+    ORDER BY
+      max_temp DESC
+    LIMIT 1
+  ) AS top_temp,
+  (
+    SELECT
+      city,
+      max_hum AS hum
+    FROM
+      windowed
+    ORDER BY
+      max_hum DESC
+    LIMIT 1
+  ) AS top_hum,
+  (
+    SELECT
       city,
-	  MAX(DISTINCT temp)::numeric(10, 2) AS max_temp
+      max_wind::numeric(10, 2) AS wind
     FROM
-      forecasts
-    GROUP BY
-      city
+      windowed
     ORDER BY
-	  max_temp DESC
+      max_wind DESC
     LIMIT 1
-  ) AS top_temp
+  ) AS top_wind
+`
+
+const getWeatherForecastCityStat = windowedForecasts + `
+SELECT
+  MIN(city COLLATE "C"),
+  SUM(count)::bigint AS count,
+  MIN(min_temp)::numeric(10, 2),
+  (SUM(sum_temp) / SUM(count))::numeric(10, 2),
+  MAX(max_temp)::numeric(10, 2),
+  MIN(min_hum),
+  (SUM(sum_hum)::numeric / SUM(count))::numeric(10, 2),
+  MAX(max_hum),
+  MIN(min_wind)::numeric(10, 2),
+  (SUM(sum_wind) / SUM(count))::numeric(10, 2),
+  MAX(max_wind)::numeric(10, 2)
+FROM
+  windowed
+GROUP BY
+  LOWER(city)
+ORDER BY
+  count DESC,
+  LOWER(city) ASC
+LIMIT $3
 `
 
 // Stat returns the weather forecast statistics.
-func (r *WeatherForecastRepo) Stat(ctx context.Context) (WeatherForecastStat, error) {
+func (r *WeatherForecastRepo) Stat(ctx context.Context, q StatQuery) (stat WeatherForecastStat, err error) {
+	ctx, span := tracer.Start(ctx, "WeatherForecastRepo.Stat")
+	span.SetAttributes(attribute.String("city", q.City))
+	defer func() { otelx.End(span, err) }()
+
+	logger := zerologx.Ctx(ctx)
+	logger.Debug().
+		Str("op", "stat forecasts").
+		Str("city", q.City).
+		Time("since", q.Since).Send()
+
 	tx, err := r.pool.BeginTx(ctx, pgx.TxOptions{
-		IsoLevel:       pgx.ReadCommitted,
-		AccessMode:     pgx.ReadWrite,
+		IsoLevel:       pgx.RepeatableRead,
+		AccessMode:     pgx.ReadOnly,
 		DeferrableMode: pgx.NotDeferrable,
 	})
 	if err != nil {
@@ -166,23 +390,54 @@ func (r *WeatherForecastRepo) Stat(ctx context.Context) (WeatherForecastStat, er
 		err = r.finishTransaction(ctx, tx, err)
 	}()
 
-	r.mtx.Lock()
-	defer r.mtx.Unlock()
-	// Get top city stat.
-	var stat WeatherForecastStat
+	var since *time.Time
+	if !q.Since.IsZero() {
+		since = &q.Since
+	}
+	stat.since = q.Since
 
 	// Get main forecast stat.
-	row := tx.QueryRow(ctx, getWeatherForecastStat)
+	row := tx.QueryRow(ctx, getWeatherForecastStat, since, q.City)
 	err = row.Scan(
 		&stat.firstRecordAt,
 		&stat.total,
-		&stat.TopRecords.city,
+		&stat.TopRecords.maxTempCity,
 		&stat.TopRecords.maxTemp,
+		&stat.TopRecords.maxHumCity,
+		&stat.TopRecords.maxHum,
+		&stat.TopRecords.maxWindCity,
+		&stat.TopRecords.maxWind,
 	)
-	if err != nil && errors.Is(err, pgx.ErrNoRows) {
-		err = ErrNoData
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = ErrNoData
+		}
+		return stat, err
 	}
 
+	// Get the most requested cities stat.
+	rows, err := tx.Query(ctx, getWeatherForecastCityStat, since, q.City, statTopCities)
+	if err != nil {
+		return stat, err
+	}
+	stat.cities, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (CityStat, error) {
+		var c CityStat
+		err := row.Scan(
+			&c.city,
+			&c.count,
+			&c.minTemp,
+			&c.avgTemp,
+			&c.maxTemp,
+			&c.minHum,
+			&c.avgHum,
+			&c.maxHum,
+			&c.minWind,
+			&c.avgWind,
+			&c.maxWind,
+		)
+		return c, err
+	})
+
 	return stat, err
 }
 
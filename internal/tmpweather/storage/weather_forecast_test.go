@@ -104,161 +104,99 @@ func TestMain(m *testing.M) {
 	os.Exit(code)
 }
 
-func TestForecastRepo_Upsert(t *testing.T) {
-	tests := []struct {
-		name    string
-		data    WeatherForecast
-		wantErr bool
-	}{
-		{
-			name: "Valid weather forecast",
-			data: WeatherForecast{
-				MsgID:  1,
-				City:   "Test",
-				Desc:   "testable",
-				Temp:   1.0,
-				Hum:    2,
-				Wind:   3.0,
-				MadeAt: time.Now(),
-			},
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			withPostgresTest(context.TODO(), t, func(t *testing.T, pool *pgxpool.Pool) {
-				t.Parallel()
-				repo, err := NewWeatherForecastRepo(pool)
-				if err != nil {
-					t.Fatalf("unable to create repo: %v", err)
-				}
-
-				upsertCtx, cancel := context.WithTimeout(context.TODO(), 1*time.Second)
-				defer cancel()
-
-				err = repo.Upsert(upsertCtx, tt.data)
-				if tt.wantErr {
-					require.Error(t, err)
-				}
-			})
+func TestWeatherForecastRepo(t *testing.T) {
+	testRepository(t, func(t *testing.T, test func(t *testing.T, repo Repository)) {
+		withPostgresTest(context.TODO(), t, func(t *testing.T, pool *pgxpool.Pool) {
+			repo, err := NewWeatherForecastRepo(pool)
+			if err != nil {
+				t.Fatalf("unable to create repo: %v", err)
+			}
+
+			test(t, repo)
 		})
-	}
+	})
 }
 
-func TestForecastRepo_Stat(t *testing.T) {
-	firstCreatedAt := time.Now()
-	tests := []struct {
-		name      string
-		forecasts []WeatherForecast
-		wantStat  WeatherForecastStat
-		wantErr   bool
-	}{
-		{
-			name: "Valid stat",
-			forecasts: []WeatherForecast{
-				{
-					MsgID:  1,
-					City:   "A",
-					Desc:   "max temp",
-					Temp:   30.0,
-					Hum:    2,
-					Wind:   3.0,
-					MadeAt: firstCreatedAt,
-				},
-				{
-					MsgID:  2,
-					City:   "B",
-					Desc:   "max hum",
-					Temp:   1.0,
-					Hum:    90,
-					Wind:   3.0,
-					MadeAt: time.Now(),
-				},
-				{
-					MsgID:  3,
-					City:   "C",
-					Desc:   "max wind",
-					Temp:   1.0,
-					Hum:    2,
-					Wind:   12.0,
-					MadeAt: time.Now(),
-				},
-				{
-					MsgID:  4,
-					City:   "D",
-					Desc:   "casual weather",
-					Temp:   1.0,
-					Hum:    2,
-					Wind:   3.0,
-					MadeAt: time.Now(),
-				},
-			},
-			wantStat: WeatherForecastStat{
-				total:         4,
-				firstRecordAt: firstCreatedAt,
-				TopRecords: struct {
-					maxTempCity string
-					maxTemp     float64
-					maxHumCity  string
-					maxHum      int64
-					maxWindCity string
-					maxWind     float64
-				}{
-					maxTempCity: "A",
-					maxTemp:     30.0,
-					maxHumCity:  "B",
-					maxHum:      90,
-					maxWindCity: "C",
-					maxWind:     12.0,
-				},
-			},
-		},
-	}
+func TestRetentionJob_Prune(t *testing.T) {
+	withPostgresTest(context.TODO(), t, func(t *testing.T, pool *pgxpool.Pool) {
+		ctx, cancel := context.WithTimeout(context.TODO(), 5*time.Second)
+		defer cancel()
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			withPostgresTest(context.TODO(), t, func(t *testing.T, pool *pgxpool.Pool) {
-				t.Parallel()
-				repo, err := NewWeatherForecastRepo(pool)
-				if err != nil {
-					t.Fatalf("unable to create repo: %v", err)
-				}
-
-				upsertCtx, cancel := context.WithTimeout(context.TODO(), 1*time.Second)
-				defer cancel()
-
-				for _, v := range tt.forecasts {
-					require.NoError(t, repo.Upsert(upsertCtx, v))
-				}
-
-				statCtx, cancel := context.WithTimeout(context.TODO(), 1*time.Second)
-				defer cancel()
-
-				stat, err := repo.Stat(statCtx)
-				if tt.wantErr {
-					require.Error(t, err)
-					return
-				}
-
-				assert.Equal(t, tt.wantStat.total, stat.total,
-					"expected total: %d, was %d", tt.wantStat.total, stat.total)
-				assert.Equal(t, 0, tt.wantStat.firstRecordAt.Compare(stat.firstRecordAt),
-					"expected firstRecordAt: %v, was %v", tt.wantStat.firstRecordAt, stat.firstRecordAt)
-				assert.Equal(t, tt.wantStat.TopRecords.maxTempCity, stat.TopRecords.maxTempCity,
-					"expected maxTempCity: %v, was %v", tt.wantStat.TopRecords.maxTempCity, stat.TopRecords.maxTempCity)
-				assert.Equal(t, tt.wantStat.TopRecords.maxTemp, stat.TopRecords.maxTemp,
-					"expected maxTemp: %v, was %v", tt.wantStat.TopRecords.maxTemp, stat.TopRecords.maxTemp)
-				assert.Equal(t, tt.wantStat.TopRecords.maxHumCity, stat.TopRecords.maxHumCity,
-					"expected maxHumCity: %v, was %v", tt.wantStat.TopRecords.maxHumCity, stat.TopRecords.maxHumCity)
-				assert.Equal(t, tt.wantStat.TopRecords.maxHum, stat.TopRecords.maxHum,
-					"expected maxHum: %v, was %v", tt.wantStat.TopRecords.maxHum, stat.TopRecords.maxHum)
-				assert.Equal(t, tt.wantStat.TopRecords.maxWindCity, stat.TopRecords.maxWindCity,
-					"expected maxWindCity: %v, was %v", tt.wantStat.TopRecords.maxWindCity, stat.TopRecords.maxWindCity)
-				assert.Equal(t, tt.wantStat.TopRecords.maxWind, stat.TopRecords.maxWind,
-					"expected maxWind: %v, was %v", tt.wantStat.TopRecords.maxWind, stat.TopRecords.maxWind)
-			})
-		})
-	}
+		repo, err := NewWeatherForecastRepo(pool)
+		require.NoError(t, err)
+
+		day := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+		forecasts := []WeatherForecast{
+			{MsgID: 1, City: "A", Desc: "old", Temp: 10, Hum: 20, Wind: 1, MadeAt: day},
+			{MsgID: 2, City: "a", Desc: "old", Temp: 20, Hum: 40, Wind: 3, MadeAt: day.Add(time.Hour)},
+			{MsgID: 3, City: "B", Desc: "old", Temp: 5, Hum: 50, Wind: 2, MadeAt: day},
+			{MsgID: 4, City: "A", Desc: "new", Temp: 1, Hum: 10, Wind: 1, MadeAt: time.Now()},
+		}
+		require.NoError(t, repo.InsertBatch(ctx, forecasts))
+
+		job, err := NewRetentionJob(repo, RetentionConfig{Age: 24 * time.Hour, Interval: time.Hour})
+		require.NoError(t, err)
+
+		// Another replica holds the lock.
+		conn, err := pool.Acquire(ctx)
+		require.NoError(t, err)
+		_, err = conn.Exec(ctx, tryRetentionLock, retentionLockID)
+		require.NoError(t, err)
+
+		pruned, locked, err := job.Prune(ctx)
+		require.NoError(t, err)
+		assert.False(t, locked)
+		assert.Zero(t, pruned)
+
+		_, err = conn.Exec(ctx, releaseRetentionLock, retentionLockID)
+		require.NoError(t, err)
+		conn.Release()
+
+		pruned, locked, err = job.Prune(ctx)
+		require.NoError(t, err)
+		assert.True(t, locked)
+		assert.Equal(t, int64(3), pruned)
+
+		page, err := repo.History(ctx, HistoryQuery{Limit: 10})
+		require.NoError(t, err)
+		require.Len(t, page.Forecasts, 1)
+		assert.Equal(t, 4, page.Forecasts[0].MsgID)
+
+		var (
+			count            int64
+			minTemp, maxTemp float64
+			sumHum           int64
+		)
+		err = pool.QueryRow(ctx,
+			`SELECT count, min_temp, max_temp, sum_hum FROM forecasts_daily WHERE day = $1 AND city = 'a'`,
+			day,
+		).Scan(&count, &minTemp, &maxTemp, &sumHum)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), count)
+		assert.Equal(t, 10.0, minTemp)
+		assert.Equal(t, 20.0, maxTemp)
+		assert.Equal(t, int64(60), sumHum)
+
+		// The statistics include the rollups.
+		stat, err := repo.Stat(ctx, StatQuery{})
+		require.NoError(t, err)
+		assert.Equal(t, 4, stat.total)
+		assert.Equal(t, day.Truncate(24*time.Hour), stat.firstRecordAt.UTC())
+		assert.Equal(t, 20.0, stat.TopRecords.maxTemp)
+		require.Len(t, stat.cities, 2)
+		assert.Equal(t, "A", stat.cities[0].city)
+		assert.Equal(t, 3, stat.cities[0].count)
+		assert.Equal(t, 1.0, stat.cities[0].minTemp)
+		assert.Equal(t, 10.33, stat.cities[0].avgTemp)
+		assert.Equal(t, int64(40), stat.cities[0].maxHum)
+
+		stat, err = repo.Stat(ctx, StatQuery{City: "b"})
+		require.NoError(t, err)
+		assert.Equal(t, 1, stat.total)
+
+		_, err = repo.Stat(ctx, StatQuery{Since: time.Now().Add(-time.Hour), City: "b"})
+		assert.ErrorIs(t, err, ErrNoData)
+	})
 }
 
 func withPostgresTest[TB testing.TB](ctx context.Context, tb TB, test func(t TB, pool *pgxpool.Pool)) {
@@ -322,7 +260,7 @@ func withPostgresTest[TB testing.TB](ctx context.Context, tb TB, test func(t TB,
 
 // migrateDb migrates the sql schema of the database.
 func migrateDb[TB testing.TB](tb TB, uri string) {
-	if err := migrate.Up(uri, ""); err != nil {
+	if err := migrate.Up(uri, Migrations()); err != nil {
 		tb.Fatalf("Unable to migrate: %v", err)
 	}
 }
@@ -0,0 +1,45 @@
+package telegram
+
+import (
+	"context"
+	"strings"
+
+	"github.com/alukart32/tmp-weather/internal/pkg/zerologx"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// air returns the msg text of the city air quality. The chat home city is used without the city name.
+func (p *MsgHandler) air(ctx context.Context, chat *tgbotapi.Chat, args string) string {
+	logger := zerologx.Ctx(ctx)
+
+	city := strings.TrimSpace(args)
+	if len(city) == 0 {
+		home, err := p.homeCity(ctx, chat)
+		if err != nil {
+			logger.Error().
+				Str("cmd", "air").
+				Err(err).Send()
+		}
+		city = home
+	}
+	if len(city) == 0 {
+		return `enter "/air city_name" or set your home city`
+	}
+	if !cityNameReg.MatchString(city) {
+		logger.Info().
+			Str("cmd", "air").
+			Msg("invalid name")
+		return "invalid city, try again"
+	}
+
+	air, err := p.AirForecaster.AirQuality(ctx, city)
+	if err != nil {
+		logger.Error().
+			Str("cmd", "air").
+			Err(err).Send()
+		return forecastErrMsg(err)
+	}
+	logger.Debug().Object("air", air).Msg("air quality respond")
+
+	return air.ToMsg()
+}
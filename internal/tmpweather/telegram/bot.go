@@ -3,167 +3,500 @@ package telegram
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
-	"os"
 	"regexp"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/alukart32/tmp-weather/internal/pkg/otelx"
 	"github.com/alukart32/tmp-weather/internal/pkg/zerologx"
+	"github.com/alukart32/tmp-weather/internal/tmpweather/archive"
 	"github.com/alukart32/tmp-weather/internal/tmpweather/storage"
 	"github.com/alukart32/tmp-weather/internal/tmpweather/weather"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otelx.Tracer("github.com/alukart32/tmp-weather/internal/tmpweather/telegram")
+
+// cityNameReg matches city names: https://stackoverflow.com/a/25677072.
+var cityNameReg = regexp.MustCompile("^([a-zA-Z\u0080-\u024F]+(?:. |-| |'))*[a-zA-Z\u0080-\u024F]*$")
+
+// Config is the representation of telegram bot settings.
+type Config struct {
+	Token string `yaml:"token" toml:"token" env:"TELEGRAM_BOT_TOKEN"`
+	Debug bool   `yaml:"debug" toml:"debug" env:"TELEGRAM_DEBUG"`
+
+	// ChatRateLimit is the max number of commands per minute of a chat, 0 means no limit.
+	ChatRateLimit int `yaml:"chat_rate_limit" toml:"chat_rate_limit" env:"TELEGRAM_CHAT_RATE_LIMIT"`
+	// ChatRateBurst is the max number of commands of a chat at once.
+	ChatRateBurst int `yaml:"chat_rate_burst" toml:"chat_rate_burst" env:"TELEGRAM_CHAT_RATE_BURST"`
+}
+
 // MsgHandler  is a telegram bot message handler.
 type MsgHandler struct {
-	ForecastRepo *storage.WeatherForecastRepo
-	Bot          *tgbotapi.BotAPI
-	Forecaster   weather.CityForecaster
+	ForecastRepo    storage.Repository
+	ForecastWriter  storage.ForecastWriter // writes the new forecasts, read by ForecastRepo
+	ObservationRepo storage.ObservationRepository
+	ChatRepo        storage.ChatRepository
+	Bot             *tgbotapi.BotAPI
+	Forecaster      weather.CityForecaster
+	AirForecaster   weather.AirForecaster
+	Archive         *archive.Client // past weather provider, nil if there is none
+
+	chatLimiter *chatLimiter // nil if there is no limit
+	sends       *sendQueue   // nil sends the replies at once
+
+	inlineDebouncer debouncer
+	inlineCache     forecastCache
+	inline          sync.WaitGroup // inline queries in progress
+	digests         sync.WaitGroup // daily digests sender
+
+	stopOnce sync.Once
+	stop     chan struct{} // closed on shutdown
+	done     chan struct{} // closed when handling is finished
 }
 
 // NewMsgHandler returns a new MsgHandler.
 func NewMsgHandler(
+	cfg Config,
 	forecaster weather.CityForecaster,
-	forecastRepo *storage.WeatherForecastRepo,
-	debugOn bool,
-) (MsgHandler, error) {
-	botAPIToken := os.Getenv("TELEGRAM_BOT_TOKEN")
-	if len(botAPIToken) == 0 {
-		return MsgHandler{}, fmt.Errorf("empty bot API token")
+	archiveClient *archive.Client,
+	forecastWriter storage.ForecastWriter,
+	forecastRepo storage.Repository,
+	observationRepo storage.ObservationRepository,
+	chatRepo storage.ChatRepository,
+) (*MsgHandler, error) {
+	if len(cfg.Token) == 0 {
+		return nil, fmt.Errorf("empty bot API token")
 	}
 
-	bot, err := tgbotapi.NewBotAPI(botAPIToken)
+	bot, err := tgbotapi.NewBotAPI(cfg.Token)
 	if err != nil {
-		return MsgHandler{}, err
+		return nil, err
 	}
+	bot.Debug = cfg.Debug
 
-	if debugOn {
-		bot.Debug = true
-	}
+	return newMsgHandler(cfg, bot, forecaster, archiveClient, forecastWriter, forecastRepo, observationRepo, chatRepo), nil
+}
 
-	return MsgHandler{
-		Bot:          bot,
-		Forecaster:   forecaster,
-		ForecastRepo: forecastRepo,
-	}, nil
+// newMsgHandler returns a new MsgHandler of the bot.
+func newMsgHandler(
+	cfg Config,
+	bot *tgbotapi.BotAPI,
+	forecaster weather.CityForecaster,
+	archiveClient *archive.Client,
+	forecastWriter storage.ForecastWriter,
+	forecastRepo storage.Repository,
+	observationRepo storage.ObservationRepository,
+	chatRepo storage.ChatRepository,
+) *MsgHandler {
+	return &MsgHandler{
+		Bot:             bot,
+		Forecaster:      forecaster,
+		AirForecaster:   forecaster.Air(),
+		Archive:         archiveClient,
+		ForecastRepo:    forecastRepo,
+		ForecastWriter:  forecastWriter,
+		ObservationRepo: observationRepo,
+		ChatRepo:        chatRepo,
+		chatLimiter:     newChatLimiter(cfg.ChatRateLimit, cfg.ChatRateBurst),
+		sends: newSendQueue(func(c tgbotapi.Chattable) error {
+			_, err := bot.Send(c)
+			return err
+		}),
+		inlineDebouncer: debouncer{delay: inlineDebounce},
+		inlineCache:     forecastCache{ttl: inlineCacheTTL, maxLen: inlineCacheMaxLen},
+		stop:            make(chan struct{}),
+		done:            make(chan struct{}),
+	}
 }
 
-// Handle handles incoming chat messages.
-func (p MsgHandler) Handle(ctx context.Context) {
+// Handle handles incoming chat messages until ctx is done or Shutdown is called.
+func (p *MsgHandler) Handle(ctx context.Context) {
 	u := tgbotapi.NewUpdate(0)
 	u.Timeout = 60
 
 	updates := p.Bot.GetUpdatesChan(u)
+	go p.sends.run(ctx)
+	if p.ChatRepo != nil {
+		p.digests.Add(1)
+		go func() {
+			defer p.digests.Done()
+			p.runDigests(ctx)
+		}()
+	}
 	go func() {
-		logger := zerologx.Get()
+		defer close(p.done)
+		defer func() {
+			// The queued replies are sent after the last command.
+			p.sends.close()
+			<-p.sends.done
+		}()
+		defer p.digests.Wait()
+		defer p.inline.Wait()
 
-		// https://stackoverflow.com/a/25677072
-		cityNameReg := regexp.MustCompile("^([a-zA-Z\u0080-\u024F]+(?:. |-| |'))*[a-zA-Z\u0080-\u024F]*$")
 		for {
 			select {
 			case <-ctx.Done():
 				return
+			case <-p.stop:
+				return
 			case update, ok := <-updates:
 				if !ok {
 					return
 				}
+				p.handleUpdate(ctx, update)
+			}
+		}
+	}()
+}
 
-				// Ignore any non-command Messages.
-				if update.Message == nil {
-					continue
-				}
-				if !update.Message.IsCommand() {
-					continue
-				}
+// Shutdown stops receiving updates and waits until the in-flight command, inline queries and digests
+// are handled and the queued replies are sent. If ctx is done first, the queued replies are dropped and its error is returned.
+func (p *MsgHandler) Shutdown(ctx context.Context) error {
+	p.stopOnce.Do(func() {
+		p.Bot.StopReceivingUpdates()
+		close(p.stop)
+	})
 
-				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
-				msg.ReplyToMessageID = update.Message.MessageID
-
-				// Update logger context.
-				logger.UpdateContext(func(c zerolog.Context) zerolog.Context {
-					return c.Dict("params", zerolog.Dict().
-						Int64("chatID", update.Message.Chat.ID).
-						Int("msgID", update.Message.MessageID),
-					)
-				})
-
-				switch update.Message.Command() {
-				case "info":
-					cityName := update.Message.CommandArguments()
-					if !cityNameReg.MatchString(cityName) {
-						logger.Info().
-							Str("cmd", "info").
-							Msg("invalid name")
-						msg.Text = "invalid city, try again"
-						break
-					}
-
-					forecast, err := p.Forecaster.Forecast(ctx, cityName)
-					if err != nil {
-						logger.Error().
-							Str("cmd", "info").
-							Err(err).Send()
-
-						switch err {
-						case weather.ErrCityNotFound:
-							msg.Text = "unknown city, try again"
-						case weather.ErrExternal, weather.ErrCorruptedCall:
-							msg.Text = "forecast error, try again"
-						default:
-							msg.Text = "internal error, try again"
-						}
-						break
-					}
-					logger.Debug().Object("forecast", forecast).Msg("forecast respond")
-
-					err = p.ForecastRepo.Insert(ctx, storage.WeatherForecast{
-						MsgID:  update.Message.MessageID,
-						City:   cityName,
-						Desc:   forecast.Weather[0].Description,
-						Temp:   forecast.Main.Temp,
-						Hum:    forecast.Main.Humidity,
-						Wind:   forecast.Wind.Speed,
-						MadeAt: forecast.MadeAt,
-					})
-					if err != nil {
-						logger.Error().
-							Str("cmd", "info").
-							Err(err).Send()
-					}
-
-					msg.Text = forecast.ToMsg()
-				case "stat":
-					stat, err := p.ForecastRepo.Stat(ctx)
-					if err != nil {
-						logger.Error().
-							Str("cmd", "stat").
-							Err(err).Send()
-						if errors.Is(storage.ErrNoData, err) {
-							msg.Text = "no stat data"
-						} else {
-							msg.Text = "could not stat, try again"
-						}
-						break
-					}
-					logger.Debug().Object("stat", stat).Msg("collected stat")
-
-					msg.Text = stat.ToMsg()
-				case "start":
-					msg.Text = `Enter "/info city_name" to forecast`
-				case "help":
-					msg.Text = "/info city_name - do forecast\n/stat - take statistics"
-				default:
-					msg.Text = "I don't know that command"
-				}
-				p.reply(msg)
+	select {
+	case <-p.done:
+		return nil
+	case <-ctx.Done():
+		p.sends.abort()
+		return ctx.Err()
+	}
+}
+
+// handleUpdate handles the chat command and replies to it.
+func (p *MsgHandler) handleUpdate(ctx context.Context, update tgbotapi.Update) {
+	if update.CallbackQuery != nil {
+		p.handleCallback(ctx, update)
+		return
+	}
+	if update.InlineQuery != nil {
+		p.handleInlineQuery(ctx, update)
+		return
+	}
+
+	// Ignore any non-command Messages.
+	if update.Message == nil {
+		return
+	}
+	if !update.Message.IsCommand() {
+		return
+	}
+	// Groups may have several bots, so only the commands addressed to the bot are handled.
+	group := isGroup(update.Message.Chat)
+	if group && !p.addressedToBot(update.Message) {
+		return
+	}
+
+	cmd := update.Message.Command()
+	ctx, span, logger := startRequest(ctx, update.UpdateID, cmd,
+		update.Message.Chat.ID, update.Message.MessageID)
+
+	msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
+	msg.ReplyToMessageID = update.Message.MessageID
+
+	if ok, wait, warn := p.chatLimiter.allow(update.Message.Chat.ID); !ok {
+		logger.Info().
+			Str("cmd", cmd).
+			Dur("wait", wait).
+			Msg("rate limited")
+		if !warn {
+			otelx.End(span, nil)
+			return
+		}
+		msg.Text = slowDownMsg(wait)
+		otelx.End(span, p.reply(ctx, update.Message.Chat.ID, msg))
+		return
+	}
+
+	if group && !p.groupAllows(ctx, update.Message.Chat.ID, cmd) {
+		msg.Text = "the command is disabled in this group"
+		otelx.End(span, p.reply(ctx, update.Message.Chat.ID, msg))
+		return
+	}
+
+	switch cmd {
+	case "info":
+		city := update.Message.CommandArguments()
+		if len(city) == 0 {
+			home, err := p.homeCity(ctx, update.Message.Chat)
+			if err != nil {
+				logger.Error().
+					Str("cmd", "info").
+					Err(err).Send()
 			}
+			city = home
 		}
-	}()
+		if len(city) == 0 {
+			text, markup := p.favoritesKeyboard(ctx, update.Message.Chat.ID)
+			msg.Text = text
+			if markup != nil {
+				msg.ReplyMarkup = markup
+			}
+			break
+		}
+		msg.Text = p.info(ctx, infoRequest{
+			chatID: update.Message.Chat.ID,
+			msgID:  update.Message.MessageID,
+			city:   city,
+		})
+	case "forecast":
+		msg.Text = p.forecast(ctx, update.Message.Chat, update.Message.CommandArguments())
+	case "air":
+		msg.Text = p.air(ctx, update.Message.Chat, update.Message.CommandArguments())
+	case "compare":
+		msg.Text = p.compare(ctx, update.Message.CommandArguments())
+		msg.ParseMode = tgbotapi.ModeHTML
+	case "past":
+		msg.Text = p.past(ctx, update.Message.Chat, update.Message.CommandArguments())
+	case "sun":
+		msg.Text = p.sun(ctx, update.Message.Chat, update.Message.CommandArguments())
+	case "fav":
+		msg.Text = p.fav(ctx, update.Message.Chat.ID, update.Message.CommandArguments())
+	case "home":
+		args := update.Message.CommandArguments()
+		if !group {
+			msg.Text = p.home(ctx, update.Message.Chat.ID, args)
+			break
+		}
+		// The group home city is one of the group settings.
+		if len(strings.TrimSpace(args)) != 0 {
+			args = "home " + args
+		}
+		msg.Text = p.group(ctx, update.Message, args)
+	case "digest":
+		args := update.Message.CommandArguments()
+		if !group {
+			msg.Text = p.digest(ctx, update.Message.Chat.ID, args)
+			break
+		}
+		// The group digest time is one of the group settings.
+		if len(strings.TrimSpace(args)) != 0 {
+			args = "digest " + args
+		}
+		msg.Text = p.group(ctx, update.Message, args)
+	case "group":
+		msg.Text = p.group(ctx, update.Message, update.Message.CommandArguments())
+	case "stat":
+		city, period, err := parseStatArgs(update.Message.CommandArguments())
+		if err != nil {
+			logger.Info().
+				Str("cmd", "stat").
+				Err(err).Send()
+			msg.Text = "invalid city or period, try again"
+			break
+		}
+
+		q := storage.StatQuery{City: city}
+		if period > 0 {
+			q.Since = time.Now().Add(-period)
+		}
+		stat, err := p.ForecastRepo.Stat(ctx, q)
+		if err != nil {
+			logger.Error().
+				Str("cmd", "stat").
+				Err(err).Send()
+			if errors.Is(err, storage.ErrNoData) {
+				msg.Text = "no stat data"
+			} else {
+				msg.Text = "could not stat, try again"
+			}
+			break
+		}
+		logger.Debug().Object("stat", stat).Msg("collected stat")
+
+		msg.Text = stat.ToMsg()
+	case "history":
+		text, markup := p.history(ctx, historyRequest{
+			chatID: update.Message.Chat.ID,
+			city:   update.Message.CommandArguments(),
+		})
+		msg.Text = text
+		if markup != nil {
+			msg.ReplyMarkup = markup
+		}
+	case "chart":
+		text, chart := p.chart(ctx, update.Message.CommandArguments())
+		if chart == nil {
+			msg.Text = text
+			break
+		}
+
+		photo := tgbotapi.NewPhoto(update.Message.Chat.ID, tgbotapi.FileBytes{Name: "chart.png", Bytes: chart})
+		photo.Caption = text
+		photo.ReplyToMessageID = update.Message.MessageID
+		otelx.End(span, p.reply(ctx, update.Message.Chat.ID, photo))
+		return
+	case "export":
+		text, doc := p.export(ctx, update.Message.Chat.ID, update.Message.CommandArguments())
+		if doc == nil {
+			msg.Text = text
+			break
+		}
+		document := tgbotapi.NewDocument(update.Message.Chat.ID, tgbotapi.FileReader{Name: doc.name, Reader: doc.file})
+		document.Caption = text
+		document.ReplyToMessageID = update.Message.MessageID
+		// The temp file is removed once it is sent.
+		otelx.End(span, p.replyAndRelease(ctx, update.Message.Chat.ID, document, func() { doc.Close() }))
+		return
+	case "start":
+		msg.Text = `Enter "/info city_name" to forecast or "/home city_name" to set your home city`
+	case "help":
+		msg.Text = "/info [city_name] - do forecast, the home city or a favorite one without the name\n" +
+			"/forecast [city_name] - forecast the next 5 days, the home city without the name\n" +
+			"/air [city_name] - show the air quality, the home city without the name\n" +
+			"/compare city_name city_name... - compare the weather of 2 to 5 cities, comma separated if a name has spaces\n" +
+			"/past [city_name] YYYY-MM-DD - show the observed weather of a past date, the home city without the name\n" +
+			"/sun [city_name] - show the sunrise, sunset and golden hour today, the home city without the name\n" +
+			"/fav add|remove city_name, /fav list - manage your favorite cities\n" +
+			"/home [city_name|clear] - show, set or clear your home city\n" +
+			"/digest [HH:MM [air]|off] - show, set or stop your daily digest of the home city at the UTC time, optionally with the air quality\n" +
+			"/group [home city_name|clear, digest HH:MM [air]|off, commands all|cmd...] - group settings, changed by admins\n" +
+			"/history [city_name] - list your forecasts\n" +
+			"/stat [city_name] [period] - take statistics, period: day, week, month, year or 12h, 7d, 2w\n" +
+			"/chart city_name [period] - draw the weather chart, a week by default\n" +
+			"/export [period] [csv|json] - export your forecasts, csv by default"
+	default:
+		msg.Text = "I don't know that command"
+	}
+	otelx.End(span, p.reply(ctx, update.Message.Chat.ID, msg))
 }
 
-// reply sends a response message.
-func (p *MsgHandler) reply(msg tgbotapi.MessageConfig) error {
-	_, err := p.Bot.Send(msg)
-	return err
+// handleCallback handles the inline keyboard callback.
+func (p *MsgHandler) handleCallback(ctx context.Context, update tgbotapi.Update) {
+	query := update.CallbackQuery
+	// Callbacks of inline mode messages have no message.
+	if query.Message == nil {
+		return
+	}
+	chatID := query.Message.Chat.ID
+
+	ctx, span, logger := startRequest(ctx, update.UpdateID, "callback", chatID, query.Message.MessageID)
+
+	// Answer the callback to stop the client loading animation.
+	// The rate limited chat is warned by the answer notification.
+	ok, wait, _ := p.chatLimiter.allow(chatID)
+	var answer string
+	if !ok {
+		answer = slowDownMsg(wait)
+	}
+	disabled := ok && isGroup(query.Message.Chat) && !p.groupAllowsCallback(ctx, chatID, query.Data)
+	if disabled {
+		answer = "the command is disabled in this group"
+	}
+	if _, err := p.Bot.Request(tgbotapi.NewCallback(query.ID, answer)); err != nil {
+		logger.Error().
+			Str("cmd", "callback").
+			Err(err).Send()
+	}
+	if !ok {
+		logger.Info().
+			Str("cmd", "callback").
+			Dur("wait", wait).
+			Msg("rate limited")
+		otelx.End(span, nil)
+		return
+	}
+	if disabled {
+		logger.Info().
+			Str("cmd", "callback").
+			Str("data", query.Data).
+			Msg("disabled in group")
+		otelx.End(span, nil)
+		return
+	}
+
+	if r, ok := parseHistoryCallbackData(query.Data); ok {
+		// Edit the history page in place.
+		r.chatID = chatID
+		text, markup := p.history(ctx, r)
+		edit := tgbotapi.NewEditMessageText(chatID, query.Message.MessageID, text)
+		edit.ReplyMarkup = markup
+
+		otelx.End(span, p.reply(ctx, chatID, edit))
+		return
+	}
+
+	if city, ok := parseFavoriteCallbackData(query.Data); ok {
+		msg := tgbotapi.NewMessage(chatID, p.info(ctx, infoRequest{
+			chatID: chatID,
+			msgID:  query.Message.MessageID,
+			city:   city,
+		}))
+		msg.ReplyToMessageID = query.Message.MessageID
+		otelx.End(span, p.reply(ctx, chatID, msg))
+		return
+	}
+
+	logger.Info().
+		Str("cmd", "callback").
+		Str("data", query.Data).
+		Msg("unknown callback")
+	otelx.End(span, nil)
+}
+
+// startRequest starts the update span and puts the request-scoped logger into ctx.
+func startRequest(
+	ctx context.Context,
+	updateID int,
+	cmd string,
+	chatID int64,
+	msgID int,
+) (context.Context, trace.Span, zerolog.Logger) {
+	ctx, span := tracer.Start(ctx, "telegram.update/"+cmd,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.Int("update.id", updateID),
+			attribute.Int64("chat.id", chatID),
+			attribute.Int("msg.id", msgID),
+			attribute.String("cmd", cmd),
+		),
+	)
+
+	logger := zerologx.Get().With().
+		Str("reqID", newRequestID()).
+		Str("traceID", span.SpanContext().TraceID().String()).
+		Dict("params", zerolog.Dict().
+			Int64("chatID", chatID).
+			Int("msgID", msgID),
+		).
+		Logger()
+
+	return zerologx.WithContext(ctx, logger), span, logger
+}
+
+// newRequestID returns a random request ID.
+func newRequestID() string {
+	var id [8]byte
+	rand.Read(id[:])
+	return hex.EncodeToString(id[:])
+}
+
+// reply queues a response message to the chat. The send errors are logged by the send queue.
+func (p *MsgHandler) reply(ctx context.Context, chatID int64, msg tgbotapi.Chattable) error {
+	return p.replyAndRelease(ctx, chatID, msg, nil)
+}
+
+// replyAndRelease queues a response message to the chat and calls release, if not nil,
+// once the message is sent or dropped, e.g. to remove the sent file.
+func (p *MsgHandler) replyAndRelease(ctx context.Context, chatID int64, msg tgbotapi.Chattable, release func()) error {
+	if p.sends == nil {
+		_, err := p.Bot.Send(msg)
+		if release != nil {
+			release()
+		}
+		return err
+	}
+	return p.sends.push(ctx, chatID, msg, release)
 }
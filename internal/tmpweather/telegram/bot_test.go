@@ -0,0 +1,117 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alukart32/tmp-weather/internal/tmpweather/weather"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBotAPI is a telegram bot API server that sends a single /start command
+// and holds the reply until it is released.
+type fakeBotAPI struct {
+	*httptest.Server
+	sending chan struct{} // closed when the reply is being sent
+	release chan struct{} // close to complete the reply
+	sent    atomic.Bool
+	updates atomic.Int32
+}
+
+func newFakeBotAPI(t *testing.T) *fakeBotAPI {
+	f := &fakeBotAPI{
+		sending: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/getMe"):
+			fmt.Fprint(w, `{"ok":true,"result":{"id":1,"is_bot":true,"first_name":"bot","username":"TmpWeatherBot"}}`)
+		case strings.HasSuffix(r.URL.Path, "/getUpdates"):
+			if f.updates.Add(1) == 1 {
+				fmt.Fprint(w, `{"ok":true,"result":[{"update_id":1,"message":{"message_id":1,"date":0,`+
+					`"chat":{"id":1,"type":"private"},"text":"/start",`+
+					`"entities":[{"type":"bot_command","offset":0,"length":6}]}}]}`)
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+			fmt.Fprint(w, `{"ok":true,"result":[]}`)
+		case strings.HasSuffix(r.URL.Path, "/sendMessage"):
+			close(f.sending)
+			<-f.release
+			f.sent.Store(true)
+			fmt.Fprint(w, `{"ok":true,"result":{"message_id":2,"date":0,"chat":{"id":1,"type":"private"}}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(f.Close)
+
+	return f
+}
+
+func TestMsgHandler_Shutdown(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		release bool
+		wantErr error
+	}{
+		{
+			name:    "In-flight command is finished",
+			timeout: 5 * time.Second,
+			release: true,
+		},
+		{
+			name:    "In-flight command exceeds the deadline",
+			timeout: 50 * time.Millisecond,
+			wantErr: context.DeadlineExceeded,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newFakeBotAPI(t)
+			bot, err := tgbotapi.NewBotAPIWithClient("token", api.URL+"/bot%s/%s", api.Client())
+			require.NoError(t, err)
+
+			h := newMsgHandler(Config{}, bot, weather.CityForecaster{}, nil, nil, nil, nil, nil)
+			h.Handle(context.Background())
+
+			select {
+			case <-api.sending:
+			case <-time.After(5 * time.Second):
+				t.Fatal("command was not handled")
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+
+			errCh := make(chan error, 1)
+			go func() { errCh <- h.Shutdown(ctx) }()
+
+			if tt.release {
+				select {
+				case err := <-errCh:
+					t.Fatalf("shutdown returned before the reply was sent: %v", err)
+				case <-time.After(20 * time.Millisecond):
+				}
+				close(api.release)
+			} else {
+				defer close(api.release)
+			}
+
+			err = <-errCh
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.release, api.sent.Load())
+		})
+	}
+}
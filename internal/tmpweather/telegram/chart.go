@@ -0,0 +1,84 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alukart32/tmp-weather/internal/pkg/zerologx"
+	"github.com/alukart32/tmp-weather/internal/tmpweather/chart"
+	"github.com/alukart32/tmp-weather/internal/tmpweather/storage"
+)
+
+// chartPeriod is the default period of the chart.
+const chartPeriod = 7 * 24 * time.Hour
+
+// chart returns the PNG chart of the city weather and its caption,
+// or the error msg text without the chart.
+func (p *MsgHandler) chart(ctx context.Context, args string) (string, []byte) {
+	logger := zerologx.Ctx(ctx)
+
+	city, period, err := parseStatArgs(args)
+	if err != nil || len(city) == 0 {
+		logger.Info().
+			Str("cmd", "chart").
+			Err(err).
+			Msg("invalid args")
+		return "invalid city or period, try again", nil
+	}
+	if period == 0 {
+		period = chartPeriod
+	}
+	q := storage.CityQuery{City: city, Since: time.Now().Add(-period)}
+
+	points, source, err := p.chartPoints(ctx, q)
+	if err != nil {
+		logger.Error().
+			Str("cmd", "chart").
+			Err(err).Send()
+		if errors.Is(err, storage.ErrNoData) {
+			return "no chart data", nil
+		}
+		return "could not draw chart, try again", nil
+	}
+
+	png, err := chart.Render(fmt.Sprintf("%s, %s", city, source), points, time.Local)
+	if err != nil {
+		logger.Error().
+			Str("cmd", "chart").
+			Err(err).Send()
+		return "could not draw chart, try again", nil
+	}
+
+	caption := fmt.Sprintf("%s, %s since %s, points: %d", city, source, q.Since.Format(time.RFC822), len(points))
+	return caption, png
+}
+
+// chartPoints returns the chart points of the watchlist city observations,
+// or of the city forecasts made by users if there are no observations.
+func (p *MsgHandler) chartPoints(ctx context.Context, q storage.CityQuery) ([]chart.Point, string, error) {
+	if p.ObservationRepo != nil {
+		obs, err := p.ObservationRepo.Observations(ctx, q)
+		if err == nil {
+			points := make([]chart.Point, len(obs))
+			for i, o := range obs {
+				points[i] = chart.Point{Time: o.ObservedAt, Temp: o.Temp, Hum: float64(o.Hum), Wind: o.Wind}
+			}
+			return points, "observations", nil
+		}
+		if !errors.Is(err, storage.ErrNoData) {
+			return nil, "", err
+		}
+	}
+
+	forecasts, err := p.ForecastRepo.CityForecasts(ctx, q)
+	if err != nil {
+		return nil, "", err
+	}
+	points := make([]chart.Point, len(forecasts))
+	for i, f := range forecasts {
+		points[i] = chart.Point{Time: f.MadeAt, Temp: f.Temp, Hum: float64(f.Hum), Wind: f.Wind}
+	}
+	return points, "forecasts", nil
+}
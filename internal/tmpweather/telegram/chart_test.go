@@ -0,0 +1,73 @@
+package telegram
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alukart32/tmp-weather/internal/tmpweather/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMsgHandler_chart(t *testing.T) {
+	repo := storage.NewMemoryRepo()
+	now := time.Now()
+
+	require.NoError(t, repo.InsertObservations(context.TODO(), []storage.Observation{
+		{City: "Berlin", ObservedAt: now.Add(-2 * time.Hour), Temp: 10, Hum: 50, Wind: 2},
+		{City: "Berlin", ObservedAt: now.Add(-time.Hour), Temp: 12, Hum: 40, Wind: 3},
+	}))
+	require.NoError(t, repo.Insert(context.TODO(), storage.WeatherForecast{
+		City: "Paris", Desc: "clear", Temp: 20, Hum: 30, Wind: 1, MadeAt: now.Add(-time.Hour),
+	}))
+
+	tests := []struct {
+		name      string
+		args      string
+		wantText  string
+		wantChart bool
+	}{
+		{
+			name:      "Observations",
+			args:      "Berlin",
+			wantText:  "Berlin, observations since",
+			wantChart: true,
+		},
+		{
+			name:      "Forecasts without observations",
+			args:      "Paris day",
+			wantText:  "Paris, forecasts since",
+			wantChart: true,
+		},
+		{
+			name:     "Out of the period",
+			args:     "Berlin 1h",
+			wantText: "no chart data",
+		},
+		{
+			name:     "Invalid period",
+			args:     "Berlin 30m",
+			wantText: "invalid city or period, try again",
+		},
+		{
+			name:     "No data",
+			args:     "London",
+			wantText: "no chart data",
+		},
+		{
+			name:     "Empty city",
+			args:     "",
+			wantText: "invalid city or period, try again",
+		},
+	}
+
+	p := &MsgHandler{ForecastRepo: repo, ObservationRepo: repo}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, chart := p.chart(context.TODO(), tt.args)
+			assert.Contains(t, text, tt.wantText)
+			assert.Equal(t, tt.wantChart, chart != nil)
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strings"
+	"sync"
+
+	"github.com/alukart32/tmp-weather/internal/pkg/zerologx"
+	"github.com/alukart32/tmp-weather/internal/tmpweather/weather"
+)
+
+// maxCompareCities is the max number of cities to compare.
+const maxCompareCities = 5
+
+// compareUsage is the reply to invalid /compare arguments.
+var compareUsage = fmt.Sprintf(`enter "/compare city_name city_name..." with 2 to %d cities, `+
+	`separate them by commas if a name has spaces`, maxCompareCities)
+
+// compareRow is the forecast of a compared city.
+type compareRow struct {
+	city     string
+	forecast weather.Forecast
+	err      error
+}
+
+// compare returns the HTML msg text of the cities weather comparison. The cities are forecast concurrently.
+func (p *MsgHandler) compare(ctx context.Context, args string) string {
+	logger := zerologx.Ctx(ctx)
+
+	cities := compareCities(args)
+	if len(cities) < 2 || len(cities) > maxCompareCities {
+		return compareUsage
+	}
+	for _, city := range cities {
+		if !cityNameReg.MatchString(city) {
+			logger.Info().
+				Str("cmd", "compare").
+				Msg("invalid name")
+			return html.EscapeString(fmt.Sprintf("invalid city %q, try again", city))
+		}
+	}
+
+	rows := make([]compareRow, len(cities))
+	var wg sync.WaitGroup
+	for i, city := range cities {
+		wg.Add(1)
+		go func(i int, city string) {
+			defer wg.Done()
+			forecast, err := p.Forecaster.Forecast(ctx, city)
+			rows[i] = compareRow{city: city, forecast: forecast, err: err}
+		}(i, city)
+	}
+	wg.Wait()
+
+	for _, r := range rows {
+		if r.err != nil {
+			logger.Error().
+				Str("cmd", "compare").
+				Str("city", r.city).
+				Err(r.err).Send()
+		}
+	}
+
+	return compareMsg(rows)
+}
+
+// compareCities splits the arguments by commas or, if there are none, by spaces.
+// The repeated cities are skipped.
+func compareCities(args string) []string {
+	var names []string
+	if strings.Contains(args, ",") {
+		names = strings.Split(args, ",")
+	} else {
+		names = strings.Fields(args)
+	}
+
+	var cities []string
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if len(name) == 0 || containsFold(cities, name) {
+			continue
+		}
+		cities = append(cities, name)
+	}
+	return cities
+}
+
+// compareMsg returns the HTML msg text of the aligned comparison table with the warmest, coldest and windiest cities.
+// The failed cities are listed with the error replies.
+func compareMsg(rows []compareRow) string {
+	var ok []compareRow
+	for _, r := range rows {
+		if r.err == nil {
+			ok = append(ok, r)
+		}
+	}
+	if len(ok) == 0 {
+		return forecastErrMsg(rows[0].err)
+	}
+
+	name := func(r compareRow) string {
+		if len(r.forecast.Name) != 0 {
+			return r.forecast.Name
+		}
+		return r.city
+	}
+	width := len("city")
+	for _, r := range ok {
+		if n := len([]rune(name(r))); n > width {
+			width = n
+		}
+	}
+
+	var table strings.Builder
+	fmt.Fprintf(&table, "%-*s %6s %6s %4s %5s\n", width, "city", "temp", "feels", "hum", "wind")
+	for _, r := range ok {
+		fmt.Fprintf(&table, "%-*s %6.1f %6.1f %3d%% %5.1f\n", width, name(r),
+			r.forecast.Main.Temp, r.forecast.Main.FeelsLike, r.forecast.Main.Humidity, r.forecast.Wind.Speed)
+	}
+
+	warmest, coldest, windiest := ok[0], ok[0], ok[0]
+	for _, r := range ok[1:] {
+		if r.forecast.Main.Temp > warmest.forecast.Main.Temp {
+			warmest = r
+		}
+		if r.forecast.Main.Temp < coldest.forecast.Main.Temp {
+			coldest = r
+		}
+		if r.forecast.Wind.Speed > windiest.forecast.Wind.Speed {
+			windiest = r
+		}
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<pre>%s</pre>\n", html.EscapeString(table.String()))
+	if len(ok) > 1 {
+		fmt.Fprintf(&sb, "warmest: <b>%s</b>, %.1f C\n", html.EscapeString(name(warmest)), warmest.forecast.Main.Temp)
+		fmt.Fprintf(&sb, "coldest: <b>%s</b>, %.1f C\n", html.EscapeString(name(coldest)), coldest.forecast.Main.Temp)
+		fmt.Fprintf(&sb, "windiest: <b>%s</b>, %.1f m/s\n", html.EscapeString(name(windiest)), windiest.forecast.Wind.Speed)
+	}
+	for _, r := range rows {
+		if r.err != nil {
+			fmt.Fprintf(&sb, "\n%s: %s", html.EscapeString(r.city), forecastErrMsg(r.err))
+		}
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,57 @@
+package telegram
+
+import (
+	"testing"
+
+	"github.com/alukart32/tmp-weather/internal/tmpweather/weather"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareCities(t *testing.T) {
+	tests := []struct {
+		args string
+		want []string
+	}{
+		{args: "", want: nil},
+		{args: "Moscow Berlin  London", want: []string{"Moscow", "Berlin", "London"}},
+		{args: "New York, Los Angeles,,Berlin", want: []string{"New York", "Los Angeles", "Berlin"}},
+		{args: "Berlin berlin Paris", want: []string{"Berlin", "Paris"}},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, compareCities(tt.args), tt.args)
+	}
+}
+
+func TestCompareMsg(t *testing.T) {
+	forecast := func(name string, temp, wind float64) weather.Forecast {
+		var f weather.Forecast
+		f.Name = name
+		f.Main.Temp, f.Main.FeelsLike, f.Main.Humidity = temp, temp-1, 50
+		f.Wind.Speed = wind
+		return f
+	}
+
+	msg := compareMsg([]compareRow{
+		{city: "moscow", forecast: forecast("Moscow", 3.5, 7)},
+		{city: "berlin", forecast: forecast("Berlin", 12, 2.25)},
+		{city: "atlantis", err: weather.ErrCityNotFound},
+		{city: "london", forecast: forecast("London", 9.8, 5.1)},
+	})
+	assert.Equal(t, `<pre>city     temp  feels  hum  wind
+Moscow    3.5    2.5  50%   7.0
+Berlin   12.0   11.0  50%   2.2
+London    9.8    8.8  50%   5.1
+</pre>
+warmest: <b>Berlin</b>, 12.0 C
+coldest: <b>Moscow</b>, 3.5 C
+windiest: <b>Moscow</b>, 7.0 m/s
+
+atlantis: unknown city, try again`, msg)
+
+	msg = compareMsg([]compareRow{
+		{city: "atlantis", err: weather.ErrCityNotFound},
+		{city: "mu", err: weather.ErrCityNotFound},
+	})
+	assert.Equal(t, "unknown city, try again", msg)
+}
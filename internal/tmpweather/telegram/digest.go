@@ -0,0 +1,144 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alukart32/tmp-weather/internal/pkg/otelx"
+	"github.com/alukart32/tmp-weather/internal/pkg/zerologx"
+	"github.com/alukart32/tmp-weather/internal/tmpweather/storage"
+	"github.com/alukart32/tmp-weather/internal/tmpweather/weather"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// digestInterval is the period of the due digests check, the digest time is set in minutes.
+const digestInterval = time.Minute
+
+// digest shows or sets the private chat digest time by the "[HH:MM [air]|off]" arguments and returns the msg text.
+func (p *MsgHandler) digest(ctx context.Context, chatID int64, args string) string {
+	logger := zerologx.Ctx(ctx)
+
+	settings, err := p.ChatRepo.ChatSettings(ctx, chatID)
+	if err != nil {
+		logger.Error().
+			Str("cmd", "digest").
+			Err(err).Send()
+		return "could not get digest time, try again"
+	}
+
+	value := strings.TrimSpace(args)
+	if len(value) == 0 {
+		return chatDigestMsg(settings)
+	}
+	digest, air, ok := parseDigest(value)
+	if !ok {
+		return "invalid digest time, use HH:MM [air] or off"
+	}
+
+	settings.DigestTime, settings.DigestAir = digest, air
+	if err := p.ChatRepo.SaveChatSettings(ctx, settings); err != nil {
+		logger.Error().
+			Str("cmd", "digest").
+			Err(err).Send()
+		return "could not set digest time, try again"
+	}
+	return chatDigestMsg(settings)
+}
+
+// chatDigestMsg returns the msg text of the private chat digest settings.
+func chatDigestMsg(s storage.ChatSettings) string {
+	if len(s.DigestTime) == 0 {
+		return `no daily digest, enter "/digest HH:MM" to get it at the UTC time`
+	}
+	text := "daily digest at " + digestTimeMsg(s.DigestTime, s.DigestAir)
+	if len(s.HomeCity) == 0 {
+		text += `, enter "/home city_name" to get it`
+	}
+	return text
+}
+
+// runDigests sends the due daily digests every digestInterval until ctx is done or Shutdown is called.
+func (p *MsgHandler) runDigests(ctx context.Context) {
+	ticker := time.NewTicker(digestInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.stop:
+			return
+		case now := <-ticker.C:
+			p.sendDigests(ctx, now)
+		}
+	}
+}
+
+// sendDigests claims the digests due at now and queues them to the chats.
+// The air quality is added to the digests that include it.
+// A claimed digest is not sent again that day, even if its forecast fails.
+func (p *MsgHandler) sendDigests(ctx context.Context, now time.Time) {
+	ctx, span := tracer.Start(ctx, "telegram.digests")
+	logger := zerologx.Ctx(ctx)
+
+	digests, err := p.ChatRepo.ClaimDigests(ctx, now)
+	if err != nil {
+		logger.Error().
+			Str("cmd", "digest").
+			Err(err).Send()
+		otelx.End(span, err)
+		return
+	}
+	span.SetAttributes(attribute.Int("digests", len(digests)))
+
+	for _, d := range digests {
+		forecast, err := p.Forecaster.Forecast(ctx, d.HomeCity)
+		if err != nil {
+			logger.Error().
+				Str("cmd", "digest").
+				Int64("chatID", d.ChatID).
+				Err(err).Send()
+		}
+
+		text := digestMsg(d, forecast, err)
+		if d.Air {
+			air, err := p.AirForecaster.AirQuality(ctx, d.HomeCity)
+			if err != nil {
+				logger.Error().
+					Str("cmd", "digest").
+					Int64("chatID", d.ChatID).
+					Err(err).Send()
+			}
+			text += "\n" + digestAirMsg(air, err)
+		}
+
+		msg := tgbotapi.NewMessage(d.ChatID, text)
+		if err := p.reply(ctx, d.ChatID, msg); err != nil {
+			logger.Error().
+				Str("cmd", "digest").
+				Int64("chatID", d.ChatID).
+				Err(err).Send()
+		}
+	}
+	otelx.End(span, nil)
+}
+
+// digestMsg returns the msg text of the daily digest of the home city forecast or its error.
+func digestMsg(d storage.Digest, f weather.Forecast, err error) string {
+	text := "Daily digest of " + d.HomeCity + "\n\n"
+	if err != nil {
+		return text + forecastErrMsg(err)
+	}
+	return text + f.ToMsg()
+}
+
+// digestAirMsg returns the msg text of the digest air quality or its error.
+func digestAirMsg(a weather.AirQuality, err error) string {
+	if err != nil {
+		return "air quality: " + forecastErrMsg(err)
+	}
+	return fmt.Sprintf("air quality: %s (%d of 5)\n%s\n", a.AQI, a.AQI, a.AQI.Guidance())
+}
@@ -0,0 +1,108 @@
+package telegram
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alukart32/tmp-weather/internal/tmpweather/storage"
+	"github.com/alukart32/tmp-weather/internal/tmpweather/weather"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDigestMsg(t *testing.T) {
+	var paris weather.Forecast
+	paris.Name = "Paris"
+	paris.Main.Temp = 21.5
+
+	tests := []struct {
+		name     string
+		forecast weather.Forecast
+		err      error
+		want     []string
+	}{
+		{
+			name:     "Forecast",
+			forecast: paris,
+			want:     []string{"Daily digest of Paris\n\n", "temp: 21.50 C"},
+		},
+		{
+			name: "Forecast error",
+			err:  weather.ErrUnavailable,
+			want: []string{"Daily digest of Paris\n\n", "weather service is unavailable"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := digestMsg(storage.Digest{ChatID: -1, HomeCity: "Paris"}, tt.forecast, tt.err)
+			for _, want := range tt.want {
+				assert.Contains(t, msg, want)
+			}
+		})
+	}
+}
+
+func TestMsgHandler_digest(t *testing.T) {
+	repo := storage.NewMemoryRepo()
+	p := &MsgHandler{ChatRepo: repo}
+
+	tests := []struct {
+		name       string
+		args       string
+		wantText   string
+		wantDigest string
+	}{
+		{
+			name:     "No digest",
+			wantText: "no daily digest",
+		},
+		{
+			name:       "Set without home city",
+			args:       "7:05",
+			wantText:   `daily digest at 07:05 UTC, enter "/home city_name" to get it`,
+			wantDigest: "07:05",
+		},
+		{
+			name:       "Invalid time",
+			args:       "7pm",
+			wantText:   "invalid digest time",
+			wantDigest: "07:05",
+		},
+		{
+			name:       "Set with air quality",
+			args:       "07:05 air",
+			wantText:   "daily digest at 07:05 UTC with the air quality",
+			wantDigest: "07:05",
+		},
+		{
+			name:     "Off",
+			args:     "off",
+			wantText: "no daily digest",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Contains(t, p.digest(context.TODO(), 1, tt.args), tt.wantText)
+
+			settings, err := repo.ChatSettings(context.TODO(), 1)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantDigest, settings.DigestTime)
+		})
+	}
+
+	// The home city is kept by the digest and the digest time by the home city.
+	p.home(context.TODO(), 1, "Oslo")
+	assert.Equal(t, "daily digest at 08:00 UTC", p.digest(context.TODO(), 1, "08:00"))
+	p.home(context.TODO(), 1, "Bergen")
+	settings, err := repo.ChatSettings(context.TODO(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, storage.ChatSettings{ChatID: 1, HomeCity: "Bergen", DigestTime: "08:00"}, settings)
+}
+
+func TestDigestAirMsg(t *testing.T) {
+	air := weather.AirQuality{AQI: weather.AQIPoor}
+	assert.Equal(t, "air quality: poor (4 of 5)\n"+weather.AQIPoor.Guidance()+"\n", digestAirMsg(air, nil))
+	assert.Equal(t, "air quality: unknown city, try again", digestAirMsg(weather.AirQuality{}, weather.ErrCityNotFound))
+}
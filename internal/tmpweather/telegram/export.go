@@ -0,0 +1,259 @@
+package telegram
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alukart32/tmp-weather/internal/pkg/zerologx"
+	"github.com/alukart32/tmp-weather/internal/tmpweather/storage"
+)
+
+// Export formats.
+const (
+	exportCSV  = "csv"
+	exportJSON = "json"
+)
+
+// exportDoc is the temp file of the exported forecasts.
+type exportDoc struct {
+	name string
+	file *os.File
+}
+
+// Close closes and removes the temp file.
+func (d *exportDoc) Close() error {
+	d.file.Close()
+	return os.Remove(d.file.Name())
+}
+
+// export writes the chat forecasts to the temp file and returns the document with its caption,
+// or the error msg text without the document.
+//
+// The forecasts are streamed from the repository to the file, so the history
+// is never held in memory.
+func (p *MsgHandler) export(ctx context.Context, chatID int64, args string) (string, *exportDoc) {
+	logger := zerologx.Ctx(ctx)
+
+	format, period, err := parseExportArgs(args)
+	if err != nil {
+		logger.Info().
+			Str("cmd", "export").
+			Err(err).
+			Msg("invalid args")
+		return "invalid format or period, try again", nil
+	}
+	q := storage.ExportQuery{ChatID: chatID}
+	if period > 0 {
+		q.Since = time.Now().Add(-period)
+	}
+
+	doc, n, err := p.writeExport(ctx, q, format)
+	if err != nil {
+		logger.Error().
+			Str("cmd", "export").
+			Err(err).Send()
+		return "could not export, try again", nil
+	}
+	if n == 0 {
+		doc.Close()
+		return "no export data", nil
+	}
+
+	caption := fmt.Sprintf("forecasts: %d", n)
+	if !q.Since.IsZero() {
+		caption += fmt.Sprintf(" since %s", q.Since.Format(time.RFC822))
+	}
+	return caption, doc
+}
+
+// writeExport writes the forecasts selected by q to the temp file in format.
+// It returns the document ready to be read and the number of forecasts.
+func (p *MsgHandler) writeExport(ctx context.Context, q storage.ExportQuery, format string) (*exportDoc, int, error) {
+	file, err := os.CreateTemp("", "tmpweather-export-*."+format)
+	if err != nil {
+		return nil, 0, err
+	}
+	doc := &exportDoc{name: "forecasts." + format, file: file}
+
+	w := bufio.NewWriter(file)
+	enc := newForecastEncoder(w, format)
+	var n int
+	err = p.ForecastRepo.ExportForecasts(ctx, q, func(f storage.WeatherForecast) error {
+		n++
+		return enc.Encode(f)
+	})
+	if err == nil {
+		err = enc.Close()
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		doc.Close()
+		return nil, 0, err
+	}
+	return doc, n, nil
+}
+
+// parseExportArgs parses the "[period] [format]" arguments of the export command in any order.
+// The default format is CSV, the zero period means all the time.
+func parseExportArgs(args string) (format string, period time.Duration, err error) {
+	format = exportCSV
+	for _, field := range strings.Fields(args) {
+		switch f := strings.ToLower(field); f {
+		case exportCSV, exportJSON:
+			format = f
+		default:
+			p, ok := parsePeriod(f)
+			if !ok {
+				return "", 0, fmt.Errorf("invalid format or period: %q", field)
+			}
+			period = p
+		}
+	}
+	return format, period, nil
+}
+
+// forecastEncoder writes the forecasts one by one.
+type forecastEncoder interface {
+	Encode(f storage.WeatherForecast) error
+	// Close writes the rest of the document.
+	Close() error
+}
+
+// newForecastEncoder returns the forecast encoder of the format.
+func newForecastEncoder(w io.Writer, format string) forecastEncoder {
+	if format == exportJSON {
+		return &jsonForecastEncoder{w: w}
+	}
+	return &csvForecastEncoder{w: csv.NewWriter(w)}
+}
+
+// exportRecord is the exported forecast.
+type exportRecord struct {
+	MadeAt time.Time `json:"made_at"`
+	City   string    `json:"city"`
+	Desc   string    `json:"description"`
+	Temp   float64   `json:"temp"`
+	Hum    int64     `json:"hum"`
+	Wind   float64   `json:"wind"`
+
+	Country   string  `json:"country"`
+	FeelsLike float64 `json:"feels_like"`
+	Pressure  int64   `json:"pressure"`
+	Clouds    int64   `json:"clouds"`
+	WindDeg   int64   `json:"wind_deg"`
+	WindGust  float64 `json:"wind_gust"`
+	Rain      float64 `json:"rain"`
+	Snow      float64 `json:"snow"`
+}
+
+// csvHeader is the header of the exported CSV document.
+var csvHeader = []string{
+	"made_at", "city", "description", "temp", "hum", "wind",
+	"country", "feels_like", "pressure", "clouds", "wind_deg", "wind_gust", "rain", "snow",
+}
+
+// csvForecastEncoder writes the forecasts as the CSV rows with the header.
+type csvForecastEncoder struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+func (e *csvForecastEncoder) Encode(f storage.WeatherForecast) error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	return e.w.Write([]string{
+		f.MadeAt.UTC().Format(time.RFC3339),
+		f.City,
+		f.Desc,
+		strconv.FormatFloat(f.Temp, 'f', 2, 64),
+		strconv.FormatInt(f.Hum, 10),
+		strconv.FormatFloat(f.Wind, 'f', 2, 64),
+		f.Country,
+		strconv.FormatFloat(f.FeelsLike, 'f', 2, 64),
+		strconv.FormatInt(f.Pressure, 10),
+		strconv.FormatInt(f.Clouds, 10),
+		strconv.FormatInt(f.WindDeg, 10),
+		strconv.FormatFloat(f.WindGust, 'f', 2, 64),
+		strconv.FormatFloat(f.Rain, 'f', 2, 64),
+		strconv.FormatFloat(f.Snow, 'f', 2, 64),
+	})
+}
+
+func (e *csvForecastEncoder) Close() error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvForecastEncoder) writeHeader() error {
+	if e.headerWritten {
+		return nil
+	}
+	e.headerWritten = true
+	return e.w.Write(csvHeader)
+}
+
+// jsonForecastEncoder writes the forecasts as the JSON array.
+type jsonForecastEncoder struct {
+	w io.Writer
+	n int
+}
+
+func (e *jsonForecastEncoder) Encode(f storage.WeatherForecast) error {
+	data, err := json.Marshal(exportRecord{
+		MadeAt: f.MadeAt.UTC(),
+		City:   f.City,
+		Desc:   f.Desc,
+		Temp:   f.Temp,
+		Hum:    f.Hum,
+		Wind:   f.Wind,
+
+		Country:   f.Country,
+		FeelsLike: f.FeelsLike,
+		Pressure:  f.Pressure,
+		Clouds:    f.Clouds,
+		WindDeg:   f.WindDeg,
+		WindGust:  f.WindGust,
+		Rain:      f.Rain,
+		Snow:      f.Snow,
+	})
+	if err != nil {
+		return err
+	}
+
+	sep := ",\n  "
+	if e.n == 0 {
+		sep = "[\n  "
+	}
+	e.n++
+	if _, err = io.WriteString(e.w, sep); err != nil {
+		return err
+	}
+	_, err = e.w.Write(data)
+	return err
+}
+
+func (e *jsonForecastEncoder) Close() error {
+	end := "\n]\n"
+	if e.n == 0 {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(e.w, end)
+	return err
+}
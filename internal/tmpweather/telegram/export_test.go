@@ -0,0 +1,109 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/alukart32/tmp-weather/internal/tmpweather/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMsgHandler_export(t *testing.T) {
+	repo := storage.NewMemoryRepo()
+	now := time.Now()
+
+	for i, f := range []storage.WeatherForecast{
+		{ChatID: 1, City: "Berlin", Desc: "clear", Temp: 10.5, Hum: 50, Wind: 2, MadeAt: now.Add(-48 * time.Hour)},
+		{ChatID: 1, City: "Paris", Desc: "rain, light", Temp: 12, Hum: 80, Wind: 3.25, MadeAt: now.Add(-time.Hour)},
+		{ChatID: 2, City: "London", Desc: "fog", Temp: 8, Hum: 90, Wind: 1, MadeAt: now},
+	} {
+		f.MsgID = i
+		require.NoError(t, repo.Insert(context.TODO(), f))
+	}
+
+	tests := []struct {
+		name     string
+		chatID   int64
+		args     string
+		wantText string
+		wantDoc  string
+		wantRows int
+	}{
+		{
+			name:     "CSV by default",
+			chatID:   1,
+			wantText: "forecasts: 2",
+			wantDoc:  "forecasts.csv",
+			wantRows: 2,
+		},
+		{
+			name:     "JSON of the period",
+			chatID:   1,
+			args:     "day JSON",
+			wantText: "forecasts: 1 since",
+			wantDoc:  "forecasts.json",
+			wantRows: 1,
+		},
+		{
+			name:     "CSV of the period",
+			chatID:   1,
+			args:     "12h csv",
+			wantText: "forecasts: 1 since",
+			wantDoc:  "forecasts.csv",
+			wantRows: 1,
+		},
+		{
+			name:     "No data",
+			chatID:   3,
+			wantText: "no export data",
+		},
+		{
+			name:     "Invalid format",
+			chatID:   1,
+			args:     "xml",
+			wantText: "invalid format or period, try again",
+		},
+	}
+
+	p := &MsgHandler{ForecastRepo: repo}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, doc := p.export(context.TODO(), tt.chatID, tt.args)
+			assert.Contains(t, text, tt.wantText)
+			if len(tt.wantDoc) == 0 {
+				assert.Nil(t, doc)
+				return
+			}
+			require.NotNil(t, doc)
+			assert.Equal(t, tt.wantDoc, doc.name)
+
+			data, err := io.ReadAll(doc.file)
+			require.NoError(t, err)
+			require.NoError(t, doc.Close())
+			_, err = os.Stat(doc.file.Name())
+			assert.ErrorIs(t, err, os.ErrNotExist)
+
+			if doc.name == "forecasts.json" {
+				var records []exportRecord
+				require.NoError(t, json.Unmarshal(data, &records))
+				require.Len(t, records, tt.wantRows)
+				assert.Equal(t, "Paris", records[0].City)
+				assert.Equal(t, 3.25, records[0].Wind)
+				return
+			}
+
+			records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+			require.NoError(t, err)
+			require.Len(t, records, tt.wantRows+1)
+			assert.Equal(t, csvHeader, records[0])
+			assert.Equal(t, "rain, light", records[len(records)-1][2])
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/alukart32/tmp-weather/internal/pkg/zerologx"
+	"github.com/alukart32/tmp-weather/internal/tmpweather/storage"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// favoritesMaxLen is the max number of the chat favorite cities.
+const favoritesMaxLen = 10
+
+// favoriteCallback is the callback data prefix of the favorite city buttons.
+const favoriteCallback = "fav"
+
+// favoritesKeyboardCols is the number of the favorite city buttons in a row.
+const favoritesKeyboardCols = 2
+
+// fav handles the "add|remove|list [city]" arguments of the fav command and returns the msg text.
+func (p *MsgHandler) fav(ctx context.Context, chatID int64, args string) string {
+	logger := zerologx.Ctx(ctx)
+
+	action, city, _ := strings.Cut(strings.TrimSpace(args), " ")
+	city = strings.TrimSpace(city)
+	if action != "list" && !validFavorite(city) {
+		logger.Info().
+			Str("cmd", "fav").
+			Str("action", action).
+			Msg("invalid name")
+		return "invalid city, try again"
+	}
+
+	switch action {
+	case "add":
+		cities, err := p.ChatRepo.Favorites(ctx, chatID)
+		if err == nil && len(cities) >= favoritesMaxLen && !containsFold(cities, city) {
+			return fmt.Sprintf("too many favorites, %d at most", favoritesMaxLen)
+		}
+		if err == nil {
+			err = p.ChatRepo.AddFavorite(ctx, chatID, city)
+		}
+		if err != nil {
+			logger.Error().
+				Str("cmd", "fav").
+				Err(err).Send()
+			return "could not add favorite, try again"
+		}
+		return fmt.Sprintf("%s is added to favorites", city)
+	case "remove":
+		err := p.ChatRepo.RemoveFavorite(ctx, chatID, city)
+		if err != nil {
+			logger.Error().
+				Str("cmd", "fav").
+				Err(err).Send()
+			if errors.Is(err, storage.ErrNoData) {
+				return fmt.Sprintf("%s is not a favorite", city)
+			}
+			return "could not remove favorite, try again"
+		}
+		return fmt.Sprintf("%s is removed from favorites", city)
+	case "list":
+		cities, err := p.ChatRepo.Favorites(ctx, chatID)
+		if err != nil {
+			logger.Error().
+				Str("cmd", "fav").
+				Err(err).Send()
+			return "could not list favorites, try again"
+		}
+		if len(cities) == 0 {
+			return "no favorites"
+		}
+		return "Favorites\n\n" + strings.Join(cities, "\n")
+	default:
+		logger.Info().
+			Str("cmd", "fav").
+			Str("action", action).
+			Msg("unknown action")
+		return `unknown action, use "/fav add|remove city_name" or "/fav list"`
+	}
+}
+
+// favoritesKeyboard returns the msg text and the keyboard of the chat favorite cities,
+// if there are any.
+func (p *MsgHandler) favoritesKeyboard(ctx context.Context, chatID int64) (string, *tgbotapi.InlineKeyboardMarkup) {
+	cities, err := p.ChatRepo.Favorites(ctx, chatID)
+	if err != nil {
+		logger := zerologx.Ctx(ctx)
+		logger.Error().
+			Str("cmd", "info").
+			Err(err).Send()
+		return "could not list favorites, try again", nil
+	}
+	if len(cities) == 0 {
+		return `Enter "/info city_name" to forecast or "/fav add city_name" to add a favorite city`, nil
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for i, city := range cities {
+		if i%favoritesKeyboardCols == 0 {
+			rows = append(rows, nil)
+		}
+		button := tgbotapi.NewInlineKeyboardButtonData(city, favoriteCallbackData(city))
+		rows[len(rows)-1] = append(rows[len(rows)-1], button)
+	}
+
+	markup := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	return "Choose the city to forecast", &markup
+}
+
+// validFavorite reports whether the city name is valid and fits into the callback data.
+func validFavorite(city string) bool {
+	return len(city) != 0 &&
+		cityNameReg.MatchString(city) &&
+		len(favoriteCallbackData(city)) <= callbackDataMaxLen
+}
+
+// favoriteCallbackData returns the callback data of the favorite city button.
+func favoriteCallbackData(city string) string {
+	return favoriteCallback + ":" + city
+}
+
+// parseFavoriteCallbackData parses the callback data of the favorite city button.
+func parseFavoriteCallbackData(data string) (string, bool) {
+	city, ok := strings.CutPrefix(data, favoriteCallback+":")
+	if !ok || len(city) == 0 {
+		return "", false
+	}
+	return city, true
+}
+
+// containsFold reports whether the cities contain the city under case-folding.
+func containsFold(cities []string, city string) bool {
+	for _, c := range cities {
+		if strings.EqualFold(c, city) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,154 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/alukart32/tmp-weather/internal/tmpweather/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMsgHandler_fav(t *testing.T) {
+	repo := storage.NewMemoryRepo()
+	for i := 0; i < favoritesMaxLen-1; i++ {
+		require.NoError(t, repo.AddFavorite(context.TODO(), 2, fmt.Sprintf("City %c", 'A'+i)))
+	}
+
+	tests := []struct {
+		name     string
+		chatID   int64
+		args     string
+		wantText string
+	}{
+		{
+			name:     "Empty list",
+			chatID:   1,
+			args:     "list",
+			wantText: "no favorites",
+		},
+		{
+			name:     "Add",
+			chatID:   1,
+			args:     "add New York",
+			wantText: "New York is added to favorites",
+		},
+		{
+			name:     "List",
+			chatID:   1,
+			args:     "list",
+			wantText: "Favorites\n\nNew York",
+		},
+		{
+			name:     "Remove",
+			chatID:   1,
+			args:     "remove new york",
+			wantText: "new york is removed from favorites",
+		},
+		{
+			name:     "Remove not a favorite",
+			chatID:   1,
+			args:     "remove New York",
+			wantText: "New York is not a favorite",
+		},
+		{
+			name:     "Add the last one",
+			chatID:   2,
+			args:     "add Paris",
+			wantText: "Paris is added to favorites",
+		},
+		{
+			name:     "Add an already added one at the limit",
+			chatID:   2,
+			args:     "add paris",
+			wantText: "paris is added to favorites",
+		},
+		{
+			name:     "Too many favorites",
+			chatID:   2,
+			args:     "add Berlin",
+			wantText: "too many favorites",
+		},
+		{
+			name:     "Empty city",
+			chatID:   1,
+			args:     "add",
+			wantText: "invalid city, try again",
+		},
+		{
+			name:     "Too long city",
+			chatID:   1,
+			args:     "add " + strings.Repeat("a", callbackDataMaxLen),
+			wantText: "invalid city, try again",
+		},
+		{
+			name:     "Unknown action",
+			chatID:   1,
+			args:     "clear Paris",
+			wantText: "unknown action",
+		},
+	}
+
+	p := &MsgHandler{ChatRepo: repo}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Contains(t, p.fav(context.TODO(), tt.chatID, tt.args), tt.wantText)
+		})
+	}
+}
+
+func TestMsgHandler_favoritesKeyboard(t *testing.T) {
+	repo := storage.NewMemoryRepo()
+	for _, city := range []string{"Paris", "Berlin", "London"} {
+		require.NoError(t, repo.AddFavorite(context.TODO(), 1, city))
+	}
+	p := &MsgHandler{ChatRepo: repo}
+
+	text, markup := p.favoritesKeyboard(context.TODO(), 1)
+	assert.Equal(t, "Choose the city to forecast", text)
+	require.NotNil(t, markup)
+	require.Len(t, markup.InlineKeyboard, 2)
+	assert.Len(t, markup.InlineKeyboard[0], 2)
+	assert.Len(t, markup.InlineKeyboard[1], 1)
+
+	button := markup.InlineKeyboard[1][0]
+	assert.Equal(t, "London", button.Text)
+	city, ok := parseFavoriteCallbackData(*button.CallbackData)
+	assert.True(t, ok)
+	assert.Equal(t, "London", city)
+
+	text, markup = p.favoritesKeyboard(context.TODO(), 2)
+	assert.Contains(t, text, "/fav add")
+	assert.Nil(t, markup)
+}
+
+func TestParseFavoriteCallbackData(t *testing.T) {
+	tests := []struct {
+		data     string
+		wantCity string
+		wantOK   bool
+	}{
+		{data: "fav:San Francisco", wantCity: "San Francisco", wantOK: true},
+		{data: "fav:"},
+		{data: "hist:older:5:Paris"},
+		{data: "favorite:Paris"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.data, func(t *testing.T) {
+			city, ok := parseFavoriteCallbackData(tt.data)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.wantCity, city)
+		})
+	}
+}
+
+func TestMsgHandler_info_invalidCity(t *testing.T) {
+	// The forecaster is not called for invalid names.
+	p := &MsgHandler{}
+	for _, city := range []string{"", "Paris1", "/"} {
+		assert.Equal(t, "invalid city, try again", p.info(context.TODO(), infoRequest{city: city}))
+	}
+}
@@ -0,0 +1,44 @@
+package telegram
+
+import (
+	"context"
+	"strings"
+
+	"github.com/alukart32/tmp-weather/internal/pkg/zerologx"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// forecast returns the msg text of the city forecast of the next days. The chat home city is used
+// without the city name.
+func (p *MsgHandler) forecast(ctx context.Context, chat *tgbotapi.Chat, args string) string {
+	logger := zerologx.Ctx(ctx)
+
+	city := strings.TrimSpace(args)
+	if len(city) == 0 {
+		home, err := p.homeCity(ctx, chat)
+		if err != nil {
+			logger.Error().
+				Str("cmd", "forecast").
+				Err(err).Send()
+		}
+		city = home
+	}
+	if len(city) == 0 {
+		return `enter "/forecast city_name" or set your home city`
+	}
+	if !cityNameReg.MatchString(city) {
+		logger.Info().
+			Str("cmd", "forecast").
+			Msg("invalid name")
+		return "invalid city, try again"
+	}
+
+	daily, err := p.Forecaster.Daily(ctx, city)
+	if err != nil {
+		logger.Error().
+			Str("cmd", "forecast").
+			Err(err).Send()
+		return forecastErrMsg(err)
+	}
+	return daily.ToMsg()
+}
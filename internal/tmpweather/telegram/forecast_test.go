@@ -0,0 +1,18 @@
+package telegram
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alukart32/tmp-weather/internal/tmpweather/storage"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMsgHandler_forecast_noCity(t *testing.T) {
+	p := &MsgHandler{ChatRepo: storage.NewMemoryRepo()}
+	chat := &tgbotapi.Chat{ID: 1, Type: "private"}
+
+	assert.Equal(t, `enter "/forecast city_name" or set your home city`, p.forecast(context.TODO(), chat, " "))
+	assert.Equal(t, "invalid city, try again", p.forecast(context.TODO(), chat, "Paris1"))
+}
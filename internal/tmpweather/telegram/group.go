@@ -0,0 +1,222 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alukart32/tmp-weather/internal/pkg/zerologx"
+	"github.com/alukart32/tmp-weather/internal/tmpweather/storage"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// groupCommands are the commands that group admins can allow or disallow.
+var groupCommands = []string{"info", "forecast", "compare", "air", "sun", "past", "fav", "home", "history", "stat", "chart", "export"}
+
+// digestTimeLayout is the layout of the digest time.
+const digestTimeLayout = "15:04"
+
+// isGroup reports whether the chat is a group or a supergroup.
+func isGroup(chat *tgbotapi.Chat) bool {
+	return chat != nil && (chat.IsGroup() || chat.IsSuperGroup())
+}
+
+// addressedToBot reports whether the command is addressed to the bot: /cmd@bot_name.
+func (p *MsgHandler) addressedToBot(m *tgbotapi.Message) bool {
+	_, name, ok := strings.Cut(m.CommandWithAt(), "@")
+	return ok && strings.EqualFold(name, p.Bot.Self.UserName)
+}
+
+// groupAllows reports whether the command is allowed in the group.
+// The command is allowed if the group settings are unavailable.
+func (p *MsgHandler) groupAllows(ctx context.Context, chatID int64, cmd string) bool {
+	if !contains(groupCommands, cmd) {
+		return true
+	}
+
+	settings, err := p.ChatRepo.GroupSettings(ctx, chatID)
+	if err != nil {
+		logger := zerologx.Ctx(ctx)
+		logger.Error().
+			Str("cmd", cmd).
+			Err(err).
+			Msg("get group settings")
+		return true
+	}
+	return len(settings.Commands) == 0 || contains(settings.Commands, cmd)
+}
+
+// groupAllowsCallback reports whether the callback buttons are allowed in the group:
+// the history pages by /history, the favorite cities by both /fav and /info.
+func (p *MsgHandler) groupAllowsCallback(ctx context.Context, chatID int64, data string) bool {
+	if _, ok := parseHistoryCallbackData(data); ok {
+		return p.groupAllows(ctx, chatID, "history")
+	}
+	if _, ok := parseFavoriteCallbackData(data); ok {
+		return p.groupAllows(ctx, chatID, "fav") && p.groupAllows(ctx, chatID, "info")
+	}
+	return true
+}
+
+// group shows or changes the group settings by the
+// "[home city_name|clear | digest HH:MM [air]|off | commands all|cmd...]" arguments and returns the msg text.
+// Only group admins can change the settings.
+func (p *MsgHandler) group(ctx context.Context, m *tgbotapi.Message, args string) string {
+	logger := zerologx.Ctx(ctx)
+
+	if !isGroup(m.Chat) {
+		return "group settings are available in groups only"
+	}
+
+	settings, err := p.ChatRepo.GroupSettings(ctx, m.Chat.ID)
+	if err != nil {
+		logger.Error().
+			Str("cmd", "group").
+			Err(err).Send()
+		return "could not get group settings, try again"
+	}
+
+	setting, value, _ := strings.Cut(strings.TrimSpace(args), " ")
+	value = strings.TrimSpace(value)
+	if len(setting) == 0 {
+		return groupSettingsMsg(settings)
+	}
+
+	admin, err := p.isGroupAdmin(m)
+	if err != nil {
+		logger.Error().
+			Str("cmd", "group").
+			Err(err).
+			Msg("check admin")
+		return "could not check admin rights, try again"
+	}
+	if !admin {
+		return "only group admins can change the settings"
+	}
+
+	switch strings.ToLower(setting) {
+	case "home":
+		if strings.EqualFold(value, homeClear) {
+			value = ""
+		} else if len(value) == 0 || !cityNameReg.MatchString(value) {
+			return "invalid city, try again"
+		}
+		settings.HomeCity = value
+	case "digest":
+		digest, air, ok := parseDigest(value)
+		if !ok {
+			return "invalid digest time, use HH:MM [air] or off"
+		}
+		settings.DigestTime, settings.DigestAir = digest, air
+	case "commands":
+		commands, ok := parseGroupCommands(value)
+		if !ok {
+			return fmt.Sprintf("invalid commands, use all or some of: %s", strings.Join(groupCommands, ", "))
+		}
+		settings.Commands = commands
+	default:
+		return "unknown setting, use home, digest or commands"
+	}
+
+	if err := p.ChatRepo.SaveGroupSettings(ctx, settings); err != nil {
+		logger.Error().
+			Str("cmd", "group").
+			Err(err).Send()
+		return "could not save group settings, try again"
+	}
+	return groupSettingsMsg(settings)
+}
+
+// isGroupAdmin reports whether the message is sent by the group admin.
+func (p *MsgHandler) isGroupAdmin(m *tgbotapi.Message) (bool, error) {
+	// Anonymous admins send messages on behalf of the group.
+	if m.SenderChat != nil && m.SenderChat.ID == m.Chat.ID {
+		return true, nil
+	}
+	if m.From == nil {
+		return false, nil
+	}
+
+	member, err := p.Bot.GetChatMember(tgbotapi.GetChatMemberConfig{
+		ChatConfigWithUser: tgbotapi.ChatConfigWithUser{ChatID: m.Chat.ID, UserID: m.From.ID},
+	})
+	if err != nil {
+		return false, err
+	}
+	return member.IsCreator() || member.IsAdministrator(), nil
+}
+
+// parseGroupCommands parses the "all" or the space or comma separated commands.
+// All commands are allowed by nil.
+func parseGroupCommands(s string) ([]string, bool) {
+	if strings.EqualFold(s, "all") {
+		return nil, true
+	}
+
+	var commands []string
+	for _, cmd := range strings.FieldsFunc(s, func(r rune) bool { return r == ' ' || r == ',' }) {
+		cmd = strings.ToLower(strings.TrimPrefix(cmd, "/"))
+		if !contains(groupCommands, cmd) {
+			return nil, false
+		}
+		if !contains(commands, cmd) {
+			commands = append(commands, cmd)
+		}
+	}
+	return commands, len(commands) != 0
+}
+
+// parseDigest parses the "HH:MM [air]" UTC digest time, optionally with the air quality,
+// or "off", which is the empty time.
+func parseDigest(s string) (digest string, air bool, ok bool) {
+	if strings.EqualFold(s, "off") {
+		return "", false, true
+	}
+	clock, option, _ := strings.Cut(s, " ")
+	switch option = strings.TrimSpace(option); {
+	case len(option) == 0:
+	case strings.EqualFold(option, "air"):
+		air = true
+	default:
+		return "", false, false
+	}
+	t, err := time.Parse(digestTimeLayout, clock)
+	if err != nil {
+		return "", false, false
+	}
+	return t.Format(digestTimeLayout), air, true
+}
+
+// digestTimeMsg returns the msg text of the digest time, optionally with the air quality.
+func digestTimeMsg(digest string, air bool) string {
+	if air {
+		return digest + " UTC with the air quality"
+	}
+	return digest + " UTC"
+}
+
+// groupSettingsMsg returns the msg text of the group settings.
+func groupSettingsMsg(s storage.GroupSettings) string {
+	home, digest, commands := s.HomeCity, "off", "all"
+	if len(home) == 0 {
+		home = "not set"
+	}
+	if len(s.DigestTime) != 0 {
+		digest = digestTimeMsg(s.DigestTime, s.DigestAir)
+	}
+	if len(s.Commands) != 0 {
+		commands = strings.Join(s.Commands, ", ")
+	}
+	return fmt.Sprintf("Group settings\n\nhome city: %s\ndigest: %s\ncommands: %s", home, digest, commands)
+}
+
+// contains reports whether the values contain v.
+func contains(values []string, v string) bool {
+	for _, s := range values {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
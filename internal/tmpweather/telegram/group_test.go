@@ -0,0 +1,213 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alukart32/tmp-weather/internal/tmpweather/storage"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Users of the fake group.
+const (
+	groupAdminID  = 1
+	groupMemberID = 2
+)
+
+// newGroupBot returns the bot of the fake telegram bot API server
+// where groupAdminID is the group admin.
+func newGroupBot(t *testing.T) *tgbotapi.BotAPI {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/getMe"):
+			fmt.Fprint(w, `{"ok":true,"result":{"id":100,"is_bot":true,"first_name":"bot","username":"TmpWeatherBot"}}`)
+		case strings.HasSuffix(r.URL.Path, "/getChatMember"):
+			status := "member"
+			if r.FormValue("user_id") == fmt.Sprint(groupAdminID) {
+				status = "administrator"
+			}
+			fmt.Fprintf(w, `{"ok":true,"result":{"status":%q,"user":{"id":%s}}}`, status, r.FormValue("user_id"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	bot, err := tgbotapi.NewBotAPIWithClient("token", server.URL+"/bot%s/%s", server.Client())
+	require.NoError(t, err)
+	return bot
+}
+
+// newCommand returns the command message of the user in the chat.
+func newCommand(chat *tgbotapi.Chat, userID int64, text string) *tgbotapi.Message {
+	cmd, _, _ := strings.Cut(text, " ")
+	return &tgbotapi.Message{
+		MessageID: 1,
+		From:      &tgbotapi.User{ID: userID},
+		Chat:      chat,
+		Text:      text,
+		Entities:  []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(cmd)}},
+	}
+}
+
+func TestMsgHandler_addressedToBot(t *testing.T) {
+	p := &MsgHandler{Bot: newGroupBot(t)}
+	chat := &tgbotapi.Chat{ID: -1, Type: "supergroup"}
+
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{text: "/info@TmpWeatherBot Paris", want: true},
+		{text: "/info@tmpweatherbot Paris", want: true},
+		{text: "/info@OtherBot Paris"},
+		{text: "/info Paris"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			assert.Equal(t, tt.want, p.addressedToBot(newCommand(chat, groupMemberID, tt.text)))
+		})
+	}
+}
+
+func TestMsgHandler_group(t *testing.T) {
+	repo := storage.NewMemoryRepo()
+	p := &MsgHandler{Bot: newGroupBot(t), ChatRepo: repo}
+	group := &tgbotapi.Chat{ID: -1, Type: "group"}
+
+	tests := []struct {
+		name     string
+		chat     *tgbotapi.Chat
+		userID   int64
+		args     string
+		wantText string
+	}{
+		{
+			name:     "Private chat",
+			chat:     &tgbotapi.Chat{ID: 1, Type: "private"},
+			userID:   groupAdminID,
+			args:     "home Paris",
+			wantText: "group settings are available in groups only",
+		},
+		{
+			name:     "Show by member",
+			chat:     group,
+			userID:   groupMemberID,
+			wantText: "home city: not set\ndigest: off\ncommands: all",
+		},
+		{
+			name:     "Change by member",
+			chat:     group,
+			userID:   groupMemberID,
+			args:     "home Paris",
+			wantText: "only group admins can change the settings",
+		},
+		{
+			name:     "Home city",
+			chat:     group,
+			userID:   groupAdminID,
+			args:     "home New York",
+			wantText: "home city: New York",
+		},
+		{
+			name:     "Digest time with air quality",
+			chat:     group,
+			userID:   groupAdminID,
+			args:     "digest 8:30 Air",
+			wantText: "digest: 08:30 UTC with the air quality\n",
+		},
+		{
+			name:     "Digest time",
+			chat:     group,
+			userID:   groupAdminID,
+			args:     "digest 8:30",
+			wantText: "digest: 08:30 UTC\n",
+		},
+		{
+			name:     "Invalid digest time",
+			chat:     group,
+			userID:   groupAdminID,
+			args:     "digest 25:00",
+			wantText: "invalid digest time",
+		},
+		{
+			name:     "Invalid digest option",
+			chat:     group,
+			userID:   groupAdminID,
+			args:     "digest 08:30 pollen",
+			wantText: "invalid digest time",
+		},
+		{
+			name:     "Commands",
+			chat:     group,
+			userID:   groupAdminID,
+			args:     "commands /info, stat info",
+			wantText: "commands: info, stat",
+		},
+		{
+			name:     "Unknown commands",
+			chat:     group,
+			userID:   groupAdminID,
+			args:     "commands info group",
+			wantText: "invalid commands",
+		},
+		{
+			name:     "Unknown setting",
+			chat:     group,
+			userID:   groupAdminID,
+			args:     "lang en",
+			wantText: "unknown setting",
+		},
+		{
+			name:     "Show by member after changes",
+			chat:     group,
+			userID:   groupMemberID,
+			wantText: "home city: New York\ndigest: 08:30 UTC\ncommands: info, stat",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newCommand(tt.chat, tt.userID, "/group@TmpWeatherBot "+tt.args)
+			assert.Contains(t, p.group(context.TODO(), m, tt.args), tt.wantText)
+		})
+	}
+
+	// The group settings are used by the commands.
+	home, err := p.homeCity(context.TODO(), group)
+	require.NoError(t, err)
+	assert.Equal(t, "New York", home)
+
+	home, err = p.homeCity(context.TODO(), &tgbotapi.Chat{ID: -1, Type: "private"})
+	require.NoError(t, err)
+	assert.Empty(t, home)
+
+	assert.True(t, p.groupAllows(context.TODO(), group.ID, "info"))
+	assert.False(t, p.groupAllows(context.TODO(), group.ID, "chart"))
+	assert.True(t, p.groupAllows(context.TODO(), group.ID, "group"))
+	assert.True(t, p.groupAllows(context.TODO(), -2, "chart"))
+
+	// The buttons are allowed by their commands.
+	assert.False(t, p.groupAllowsCallback(context.TODO(), group.ID, favoriteCallbackData("Paris")))
+	assert.False(t, p.groupAllowsCallback(context.TODO(), group.ID, historyCallbackData("older", 5, "")))
+	assert.True(t, p.groupAllowsCallback(context.TODO(), group.ID, "unknown"))
+	assert.True(t, p.groupAllowsCallback(context.TODO(), -2, favoriteCallbackData("Paris")))
+}
+
+func TestMsgHandler_isGroupAdmin_anonymous(t *testing.T) {
+	p := &MsgHandler{}
+	group := &tgbotapi.Chat{ID: -1, Type: "supergroup"}
+
+	m := newCommand(group, 1087968824, "/group@TmpWeatherBot home clear")
+	m.SenderChat = group
+
+	admin, err := p.isGroupAdmin(m)
+	require.NoError(t, err)
+	assert.True(t, admin)
+}
@@ -0,0 +1,118 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/alukart32/tmp-weather/internal/pkg/zerologx"
+	"github.com/alukart32/tmp-weather/internal/tmpweather/storage"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// historyPageSize is the number of forecasts on the history page.
+const historyPageSize = 5
+
+// historyCallback is the callback data prefix of the history navigation buttons.
+const historyCallback = "hist"
+
+// callbackDataMaxLen is the telegram limit of the callback data length.
+const callbackDataMaxLen = 64
+
+// historyRequest defines the history page of the chat.
+type historyRequest struct {
+	chatID int64
+	city   string
+	before int64 // forecasts older than the ID
+	after  int64 // forecasts newer than the ID
+}
+
+// history returns the msg text of the history page and its navigation keyboard, if any.
+func (p *MsgHandler) history(ctx context.Context, r historyRequest) (string, *tgbotapi.InlineKeyboardMarkup) {
+	logger := zerologx.Ctx(ctx)
+
+	if !cityNameReg.MatchString(r.city) {
+		logger.Info().
+			Str("cmd", "history").
+			Msg("invalid name")
+		return "invalid city, try again", nil
+	}
+
+	page, err := p.ForecastRepo.History(ctx, storage.HistoryQuery{
+		ChatID: r.chatID,
+		City:   r.city,
+		Before: r.before,
+		After:  r.after,
+		Limit:  historyPageSize,
+	})
+	if err != nil {
+		logger.Error().
+			Str("cmd", "history").
+			Err(err).Send()
+		if errors.Is(err, storage.ErrNoData) {
+			return "no history data", nil
+		}
+		return "could not get history, try again", nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString("History")
+	if len(r.city) != 0 {
+		fmt.Fprintf(&sb, ": %v", r.city)
+	}
+	sb.WriteString("\n\n")
+	sb.WriteString(page.ToMsg())
+
+	var buttons []tgbotapi.InlineKeyboardButton
+	if page.HasNewer {
+		data := historyCallbackData("newer", page.Forecasts[0].ID, r.city)
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData("« prev", data))
+	}
+	if page.HasOlder {
+		data := historyCallbackData("older", page.Forecasts[len(page.Forecasts)-1].ID, r.city)
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData("next »", data))
+	}
+	// Too long city names do not fit into the callback data.
+	for _, b := range buttons {
+		if len(*b.CallbackData) > callbackDataMaxLen {
+			return sb.String(), nil
+		}
+	}
+	if len(buttons) == 0 {
+		return sb.String(), nil
+	}
+
+	markup := tgbotapi.NewInlineKeyboardMarkup(buttons)
+	return sb.String(), &markup
+}
+
+// historyCallbackData returns the callback data of the history navigation button.
+func historyCallbackData(direction string, id int64, city string) string {
+	return strings.Join([]string{historyCallback, direction, strconv.FormatInt(id, 10), city}, ":")
+}
+
+// parseHistoryCallbackData parses the callback data of the history navigation button.
+func parseHistoryCallbackData(data string) (historyRequest, bool) {
+	parts := strings.SplitN(data, ":", 4)
+	if len(parts) != 4 || parts[0] != historyCallback {
+		return historyRequest{}, false
+	}
+
+	id, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil || id <= 0 {
+		return historyRequest{}, false
+	}
+
+	r := historyRequest{city: parts[3]}
+	switch parts[1] {
+	case "newer":
+		r.after = id
+	case "older":
+		r.before = id
+	default:
+		return historyRequest{}, false
+	}
+	return r, true
+}
@@ -0,0 +1,78 @@
+package telegram
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alukart32/tmp-weather/internal/tmpweather/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMsgHandler_history(t *testing.T) {
+	ctx := context.Background()
+	repo := storage.NewMemoryRepo()
+	madeAt := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+	for i := 1; i <= 7; i++ {
+		city := "Berlin"
+		if i%2 == 0 {
+			city = "Paris"
+		}
+		require.NoError(t, repo.Insert(ctx, storage.WeatherForecast{
+			ChatID: 1,
+			MsgID:  i,
+			City:   city,
+			Desc:   "clear sky",
+			Temp:   float64(i),
+			Hum:    50,
+			Wind:   2,
+			MadeAt: madeAt.Add(time.Duration(i) * time.Hour),
+		}))
+	}
+	p := &MsgHandler{ForecastRepo: repo}
+
+	text, markup := p.history(ctx, historyRequest{chatID: 1})
+	assert.Contains(t, text, "History\n\n")
+	assert.Contains(t, text, "Berlin: 7.00 C")
+	assert.NotContains(t, text, "Paris: 2.00 C", "the second page")
+	require.NotNil(t, markup)
+	require.Len(t, markup.InlineKeyboard[0], 1)
+	older := *markup.InlineKeyboard[0][0].CallbackData
+
+	r, ok := parseHistoryCallbackData(older)
+	require.True(t, ok)
+	r.chatID = 1
+	text, markup = p.history(ctx, r)
+	assert.Contains(t, text, "Paris: 2.00 C")
+	require.NotNil(t, markup)
+	assert.Len(t, markup.InlineKeyboard[0], 1, "only the newer page button")
+
+	// The city filter is case-insensitive.
+	text, markup = p.history(ctx, historyRequest{chatID: 1, city: "berlin"})
+	assert.Contains(t, text, "History: berlin\n\n")
+	assert.Contains(t, text, "Berlin: 1.00 C")
+	assert.NotContains(t, text, "Paris")
+	assert.Nil(t, markup, "4 forecasts fit into the page")
+
+	text, _ = p.history(ctx, historyRequest{chatID: 2})
+	assert.Equal(t, "no history data", text)
+
+	text, _ = p.history(ctx, historyRequest{chatID: 1, city: "Berlin1"})
+	assert.Equal(t, "invalid city, try again", text)
+}
+
+func TestParseHistoryCallbackData(t *testing.T) {
+	r, ok := parseHistoryCallbackData(historyCallbackData("older", 42, "New York"))
+	require.True(t, ok)
+	assert.Equal(t, historyRequest{before: 42, city: "New York"}, r)
+
+	r, ok = parseHistoryCallbackData(historyCallbackData("newer", 7, ""))
+	require.True(t, ok)
+	assert.Equal(t, historyRequest{after: 7}, r)
+
+	for _, data := range []string{"", "hist:older:0:", "hist:up:1:", "fav:older:1:", "hist:older:x:"} {
+		_, ok := parseHistoryCallbackData(data)
+		assert.False(t, ok, data)
+	}
+}
@@ -0,0 +1,78 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/alukart32/tmp-weather/internal/pkg/zerologx"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// homeClear is the home command argument that clears the home city.
+const homeClear = "clear"
+
+// home shows, sets or clears the private chat home city by the "[city_name|clear]" arguments
+// and returns the msg text.
+func (p *MsgHandler) home(ctx context.Context, chatID int64, args string) string {
+	logger := zerologx.Ctx(ctx)
+
+	city := strings.TrimSpace(args)
+	if len(city) == 0 {
+		home, err := p.homeCity(ctx, &tgbotapi.Chat{ID: chatID, Type: "private"})
+		if err != nil {
+			logger.Error().
+				Str("cmd", "home").
+				Err(err).Send()
+			return "could not get home city, try again"
+		}
+		if len(home) == 0 {
+			return `no home city, enter "/home city_name" to set it`
+		}
+		return fmt.Sprintf("home city: %s", home)
+	}
+
+	if strings.EqualFold(city, homeClear) {
+		city = ""
+	} else if !cityNameReg.MatchString(city) {
+		logger.Info().
+			Str("cmd", "home").
+			Msg("invalid name")
+		return "invalid city, try again"
+	}
+
+	// The other chat settings are kept.
+	settings, err := p.ChatRepo.ChatSettings(ctx, chatID)
+	if err == nil {
+		settings.HomeCity = city
+		err = p.ChatRepo.SaveChatSettings(ctx, settings)
+	}
+	if err != nil {
+		logger.Error().
+			Str("cmd", "home").
+			Err(err).Send()
+		return "could not set home city, try again"
+	}
+	if len(city) == 0 {
+		return "home city is cleared"
+	}
+	return fmt.Sprintf("home city is set to %s", city)
+}
+
+// homeCity returns the chat home city, empty if it is not set. The group home city is set by the group admins.
+// It is the default city of /info, /forecast, /air, /past, /sun, the daily digest and the empty inline query.
+func (p *MsgHandler) homeCity(ctx context.Context, chat *tgbotapi.Chat) (string, error) {
+	if isGroup(chat) {
+		settings, err := p.ChatRepo.GroupSettings(ctx, chat.ID)
+		if err != nil {
+			return "", err
+		}
+		return settings.HomeCity, nil
+	}
+
+	settings, err := p.ChatRepo.ChatSettings(ctx, chat.ID)
+	if err != nil {
+		return "", err
+	}
+	return settings.HomeCity, nil
+}
@@ -0,0 +1,60 @@
+package telegram
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alukart32/tmp-weather/internal/tmpweather/storage"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMsgHandler_home(t *testing.T) {
+	repo := storage.NewMemoryRepo()
+	p := &MsgHandler{ChatRepo: repo}
+
+	tests := []struct {
+		name     string
+		args     string
+		wantText string
+		wantHome string
+	}{
+		{
+			name:     "No home city",
+			wantText: "no home city",
+		},
+		{
+			name:     "Set",
+			args:     " New York ",
+			wantText: "home city is set to New York",
+			wantHome: "New York",
+		},
+		{
+			name:     "Show",
+			wantText: "home city: New York",
+			wantHome: "New York",
+		},
+		{
+			name:     "Invalid city",
+			args:     "Paris1",
+			wantText: "invalid city, try again",
+			wantHome: "New York",
+		},
+		{
+			name:     "Clear",
+			args:     "Clear",
+			wantText: "home city is cleared",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Contains(t, p.home(context.TODO(), 1, tt.args), tt.wantText)
+
+			home, err := p.homeCity(context.TODO(), &tgbotapi.Chat{ID: 1, Type: "private"})
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantHome, home)
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+
+	"github.com/alukart32/tmp-weather/internal/pkg/zerologx"
+	"github.com/alukart32/tmp-weather/internal/tmpweather/storage"
+	"github.com/alukart32/tmp-weather/internal/tmpweather/weather"
+)
+
+// infoRequest defines the forecast of the city requested by the chat message.
+type infoRequest struct {
+	chatID int64
+	msgID  int
+	city   string
+}
+
+// info forecasts the city, stores the forecast and returns its msg text.
+func (p *MsgHandler) info(ctx context.Context, r infoRequest) string {
+	logger := zerologx.Ctx(ctx)
+
+	// The empty name matches cityNameReg.
+	if len(r.city) == 0 || !cityNameReg.MatchString(r.city) {
+		logger.Info().
+			Str("cmd", "info").
+			Msg("invalid name")
+		return "invalid city, try again"
+	}
+
+	forecast, err := p.Forecaster.Forecast(ctx, r.city)
+	if err != nil {
+		logger.Error().
+			Str("cmd", "info").
+			Err(err).Send()
+
+		return forecastErrMsg(err)
+	}
+	logger.Debug().Object("forecast", forecast).Msg("forecast respond")
+
+	err = p.ForecastWriter.Insert(ctx, storedForecast(r, forecast))
+	if err != nil {
+		logger.Error().
+			Str("cmd", "info").
+			Err(err).Send()
+	}
+
+	return forecast.ToMsg()
+}
+
+// storedForecast returns the stored forecast of the request.
+func storedForecast(r infoRequest, f weather.Forecast) storage.WeatherForecast {
+	stored := storage.WeatherForecast{
+		ChatID:     r.chatID,
+		MsgID:      r.msgID,
+		City:       r.city,
+		Country:    f.Sys.Country,
+		Temp:       f.Main.Temp,
+		FeelsLike:  f.Main.FeelsLike,
+		Hum:        f.Main.Humidity,
+		Pressure:   f.Main.Pressure,
+		Visibility: f.Visibility,
+		Clouds:     f.Clouds.All,
+		Wind:       f.Wind.Speed,
+		WindDeg:    f.Wind.Deg,
+		WindGust:   f.Wind.Gust,
+		Rain:       f.Rain.OneHour,
+		Snow:       f.Snow.OneHour,
+		MadeAt:     f.MadeAt,
+	}
+	if len(f.Weather) != 0 {
+		stored.Desc = f.Weather[0].Description
+		stored.ConditionID = f.Weather[0].ID
+		stored.Icon = f.Weather[0].Icon
+	}
+	return stored
+}
+
+// forecastErrMsg returns the msg text of the forecaster error.
+func forecastErrMsg(err error) string {
+	switch {
+	case errors.Is(err, weather.ErrCityNotFound):
+		return "unknown city, try again"
+	case errors.Is(err, weather.ErrRateLimited):
+		return "too many forecasts right now, try again in a minute"
+	case errors.Is(err, weather.ErrUnavailable):
+		return "weather service is unavailable, try again later"
+	case errors.Is(err, weather.ErrUnauthorized):
+		return "weather service access is misconfigured, please tell the bot admin"
+	case errors.Is(err, weather.ErrCorruptedCall):
+		return "weather service did not respond, try again"
+	case errors.Is(err, weather.ErrExternal), errors.Is(err, weather.ErrInvalidResponse):
+		return "weather service error, try again later"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "forecast took too long, try again"
+	default:
+		return "internal error, try again"
+	}
+}
@@ -0,0 +1,66 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/alukart32/tmp-weather/internal/tmpweather/weather"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForecastErrMsg(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{
+			name: "City not found",
+			err:  &weather.APIError{StatusCode: http.StatusNotFound, Message: "city not found", Err: weather.ErrCityNotFound},
+			want: "unknown city, try again",
+		},
+		{
+			name: "Quota exceeded",
+			err:  &weather.APIError{StatusCode: http.StatusTooManyRequests, Err: weather.ErrRateLimited},
+			want: "too many forecasts right now, try again in a minute",
+		},
+		{
+			name: "Invalid API key",
+			err:  &weather.APIError{StatusCode: http.StatusUnauthorized, Err: weather.ErrUnauthorized},
+			want: "weather service access is misconfigured, please tell the bot admin",
+		},
+		{
+			name: "Wrapped transport error",
+			err:  fmt.Errorf("%w: connection refused", weather.ErrCorruptedCall),
+			want: "weather service did not respond, try again",
+		},
+		{
+			name: "Invalid response",
+			err:  fmt.Errorf("%w: unexpected EOF", weather.ErrInvalidResponse),
+			want: "weather service error, try again later",
+		},
+		{
+			name: "Breaker open",
+			err:  weather.ErrUnavailable,
+			want: "weather service is unavailable, try again later",
+		},
+		{
+			name: "Timeout",
+			err:  context.DeadlineExceeded,
+			want: "forecast took too long, try again",
+		},
+		{
+			name: "Unknown",
+			err:  weather.ErrStopped,
+			want: "internal error, try again",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, forecastErrMsg(tt.err))
+		})
+	}
+}
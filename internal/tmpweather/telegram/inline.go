@@ -0,0 +1,222 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alukart32/tmp-weather/internal/pkg/otelx"
+	"github.com/alukart32/tmp-weather/internal/tmpweather/weather"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Inline query settings.
+const (
+	// inlineDebounce is the typing pause after which the inline query is forecasted.
+	inlineDebounce = 700 * time.Millisecond
+	// inlineCacheTTL is how long the forecasts of the inline queries are cached.
+	inlineCacheTTL = 5 * time.Minute
+	// inlineCacheMaxLen is the max number of the cached forecasts.
+	inlineCacheMaxLen = 1000
+)
+
+// handleInlineQuery answers the inline query with the forecast card of the city.
+//
+// Telegram sends a query on each typed char, so the query is forecasted in the background
+// after the user stops typing and the newer queries of the user supersede it.
+func (p *MsgHandler) handleInlineQuery(ctx context.Context, update tgbotapi.Update) {
+	query := update.InlineQuery
+	if query.From == nil {
+		return
+	}
+	userID := query.From.ID
+	seq := p.inlineDebouncer.add(userID)
+
+	p.inline.Add(1)
+	go func() {
+		defer p.inline.Done()
+
+		if !p.inlineDebouncer.wait(ctx, p.stop, userID, seq) {
+			return
+		}
+
+		// The query is sent by the user, so its private chat settings are used.
+		ctx, span, logger := startRequest(ctx, update.UpdateID, "inline", userID, 0)
+
+		// The user shares the commands limit with its private chat.
+		if ok, wait, _ := p.chatLimiter.allow(userID); !ok {
+			logger.Info().
+				Str("cmd", "inline").
+				Dur("wait", wait).
+				Msg("rate limited")
+			otelx.End(span, nil)
+			return
+		}
+
+		// The empty query is answered with the user home city.
+		city := strings.TrimSpace(query.Query)
+		personal := len(city) == 0
+		if personal {
+			home, err := p.homeCity(ctx, &tgbotapi.Chat{ID: userID, Type: "private"})
+			if err != nil {
+				logger.Error().
+					Str("cmd", "inline").
+					Err(err).Send()
+			}
+			city = home
+		}
+
+		answer := tgbotapi.InlineConfig{
+			InlineQueryID: query.ID,
+			Results:       []interface{}{},
+			CacheTime:     int(inlineCacheTTL.Seconds()),
+			IsPersonal:    personal,
+		}
+		if len(city) != 0 && cityNameReg.MatchString(city) {
+			forecast, err := p.inlineForecast(ctx, city)
+			if err != nil {
+				logger.Info().
+					Str("cmd", "inline").
+					Str("city", city).
+					Err(err).Send()
+			} else {
+				answer.Results = append(answer.Results, inlineResult(city, forecast))
+			}
+		}
+
+		_, err := p.Bot.Request(answer)
+		otelx.End(span, err)
+	}()
+}
+
+// inlineForecast returns the cached forecast of the city or forecasts it.
+func (p *MsgHandler) inlineForecast(ctx context.Context, city string) (weather.Forecast, error) {
+	now := time.Now()
+	if forecast, ok := p.inlineCache.get(city, now); ok {
+		return forecast, nil
+	}
+
+	forecast, err := p.Forecaster.Forecast(ctx, city)
+	if err != nil {
+		return weather.Forecast{}, err
+	}
+	p.inlineCache.put(city, forecast, now)
+	return forecast, nil
+}
+
+// inlineResult returns the inline query result card of the city forecast.
+func inlineResult(city string, f weather.Forecast) tgbotapi.InlineQueryResultArticle {
+	// The result ID is up to 64 bytes.
+	h := fnv.New64a()
+	h.Write([]byte(strings.ToLower(city)))
+	id := strconv.FormatUint(h.Sum64(), 16)
+
+	title := fmt.Sprintf("%s: %.0f C", city, f.Main.Temp)
+	if len(f.Weather) != 0 {
+		title += ", " + f.Weather[0].Description
+	}
+	article := tgbotapi.NewInlineQueryResultArticle(id, title, city+"\n\n"+f.ToMsg())
+	article.Description = fmt.Sprintf("feels like: %.0f C, hum: %d %%, wind: %.1f m/s",
+		f.Main.FeelsLike, f.Main.Humidity, f.Wind.Speed)
+	return article
+}
+
+// debouncer lets only the latest of the keyed calls proceed after the delay.
+type debouncer struct {
+	delay time.Duration
+
+	mtx    sync.Mutex
+	seq    uint64
+	latest map[int64]uint64 // the latest call sequence number by key
+}
+
+// add registers the call of the key and returns its sequence number.
+func (d *debouncer) add(key int64) uint64 {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	if d.latest == nil {
+		d.latest = make(map[int64]uint64)
+	}
+	d.seq++
+	d.latest[key] = d.seq
+	return d.seq
+}
+
+// wait waits the delay and reports whether the call is still the latest one of the key.
+// It returns false if ctx is done or stop is closed first.
+func (d *debouncer) wait(ctx context.Context, stop <-chan struct{}, key int64, seq uint64) bool {
+	timer := time.NewTimer(d.delay)
+	defer timer.Stop()
+
+	proceed := true
+	select {
+	case <-ctx.Done():
+		proceed = false
+	case <-stop:
+		proceed = false
+	case <-timer.C:
+	}
+
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	if d.latest[key] != seq {
+		return false
+	}
+	delete(d.latest, key)
+	return proceed
+}
+
+// forecastCache caches the forecasts by the case-insensitive city name.
+type forecastCache struct {
+	ttl    time.Duration
+	maxLen int
+
+	mtx     sync.Mutex
+	entries map[string]forecastCacheEntry
+}
+
+// forecastCacheEntry is the cached forecast.
+type forecastCacheEntry struct {
+	forecast weather.Forecast
+	expires  time.Time
+}
+
+// get returns the city forecast if it is cached and not expired at now.
+func (c *forecastCache) get(city string, now time.Time) (weather.Forecast, bool) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	e, ok := c.entries[strings.ToLower(city)]
+	if !ok || !now.Before(e.expires) {
+		return weather.Forecast{}, false
+	}
+	return e.forecast, true
+}
+
+// put caches the city forecast made at now.
+// The expired forecasts are evicted if the cache is full, the forecast is skipped if it is still full.
+func (c *forecastCache) put(city string, f weather.Forecast, now time.Time) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if c.entries == nil {
+		c.entries = make(map[string]forecastCacheEntry)
+	}
+	if len(c.entries) >= c.maxLen {
+		for k, e := range c.entries {
+			if !now.Before(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= c.maxLen {
+			return
+		}
+	}
+	c.entries[strings.ToLower(city)] = forecastCacheEntry{forecast: f, expires: now.Add(c.ttl)}
+}
@@ -0,0 +1,112 @@
+package telegram
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alukart32/tmp-weather/internal/tmpweather/weather"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDebouncer(t *testing.T) {
+	t.Run("Latest call proceeds", func(t *testing.T) {
+		d := &debouncer{delay: 20 * time.Millisecond}
+
+		// Typing "Par", "Pari", "Paris" by the user 1 and "Rome" by the user 2.
+		calls := []struct {
+			key  int64
+			seq  uint64
+			want bool
+		}{
+			{key: 1},
+			{key: 1},
+			{key: 2, want: true},
+			{key: 1, want: true},
+		}
+		for i := range calls {
+			calls[i].seq = d.add(calls[i].key)
+		}
+
+		var wg sync.WaitGroup
+		got := make([]bool, len(calls))
+		for i, c := range calls {
+			wg.Add(1)
+			go func(i int, key int64, seq uint64) {
+				defer wg.Done()
+				got[i] = d.wait(context.TODO(), nil, key, seq)
+			}(i, c.key, c.seq)
+		}
+		wg.Wait()
+
+		for i, c := range calls {
+			assert.Equal(t, c.want, got[i], "call %d", i)
+		}
+		assert.Empty(t, d.latest)
+	})
+
+	t.Run("Stopped", func(t *testing.T) {
+		d := &debouncer{delay: time.Hour}
+		stop := make(chan struct{})
+		close(stop)
+
+		seq := d.add(1)
+		assert.False(t, d.wait(context.TODO(), stop, 1, seq))
+		assert.Empty(t, d.latest)
+	})
+}
+
+func TestForecastCache(t *testing.T) {
+	now := time.Now()
+	c := &forecastCache{ttl: time.Minute, maxLen: 2}
+
+	forecast := func(temp float64) weather.Forecast {
+		var f weather.Forecast
+		f.Main.Temp = temp
+		return f
+	}
+
+	_, ok := c.get("Paris", now)
+	assert.False(t, ok)
+
+	c.put("Paris", forecast(10), now)
+	f, ok := c.get("PARIS", now.Add(30*time.Second))
+	require.True(t, ok)
+	assert.Equal(t, 10.0, f.Main.Temp)
+
+	_, ok = c.get("Paris", now.Add(time.Minute))
+	assert.False(t, ok, "expired")
+
+	// The full cache skips new forecasts until the old ones expire.
+	c.put("Berlin", forecast(20), now)
+	c.put("Rome", forecast(30), now)
+	_, ok = c.get("Rome", now)
+	assert.False(t, ok)
+
+	c.put("Rome", forecast(30), now.Add(time.Minute))
+	f, ok = c.get("Rome", now.Add(time.Minute))
+	require.True(t, ok)
+	assert.Equal(t, 30.0, f.Main.Temp)
+	assert.Len(t, c.entries, 1)
+}
+
+func TestInlineResult(t *testing.T) {
+	var f weather.Forecast
+	f.Main.Temp = 12.4
+	f.Main.FeelsLike = 10.6
+	f.Main.Humidity = 50
+	f.Wind.Speed = 3.25
+	f.Weather = append(f.Weather, weather.Condition{Description: "clear sky"})
+
+	article := inlineResult("Paris", f)
+	assert.Equal(t, "Paris: 12 C, clear sky", article.Title)
+	assert.Equal(t, "feels like: 11 C, hum: 50 %, wind: 3.2 m/s", article.Description)
+	assert.Equal(t, inlineResult("paris", f).ID, article.ID)
+	assert.LessOrEqual(t, len(article.ID), 64)
+
+	// The forecast may have no weather conditions.
+	f.Weather = nil
+	assert.Equal(t, "Paris: 12 C", inlineResult("Paris", f).Title)
+}
@@ -0,0 +1,295 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/alukart32/tmp-weather/internal/pkg/ratelimit"
+	"github.com/alukart32/tmp-weather/internal/pkg/zerologx"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Telegram limits of the outgoing messages:
+// https://core.telegram.org/bots/faq#my-bot-is-hitting-limits-how-do-i-avoid-this.
+var (
+	sendLimit     = ratelimit.Limit{N: 30, Per: time.Second, Burst: 30}
+	sendChatLimit = ratelimit.Limit{N: 1, Per: time.Second, Burst: 3}
+)
+
+// chatWarnedMaxLen is the number of the warned chats after which the expired warnings are evicted.
+const chatWarnedMaxLen = 1024
+
+// chatLimiter limits the commands of each chat.
+type chatLimiter struct {
+	limiter *ratelimit.Keyed
+
+	mtx    sync.Mutex
+	warned map[int64]time.Time // the chats warned until the time
+}
+
+// newChatLimiter returns a new chatLimiter of n commands per minute with bursts of up to burst commands,
+// or nil if n is not positive.
+func newChatLimiter(n, burst int) *chatLimiter {
+	if n <= 0 {
+		return nil
+	}
+	return &chatLimiter{
+		limiter: ratelimit.NewKeyed(ratelimit.Limit{N: n, Per: time.Minute, Burst: burst}),
+		warned:  make(map[int64]time.Time),
+	}
+}
+
+// allow reports whether the chat command is allowed. Otherwise, it returns the time to wait
+// and whether the chat should be warned: it is warned once until the wait is over.
+func (l *chatLimiter) allow(chatID int64) (ok bool, wait time.Duration, warn bool) {
+	if l == nil {
+		return true, 0, false
+	}
+
+	ok, wait = l.limiter.AllowAt(chatID)
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	if ok {
+		delete(l.warned, chatID)
+		return true, 0, false
+	}
+
+	now := time.Now()
+	if until, warned := l.warned[chatID]; warned && now.Before(until) {
+		return false, wait, false
+	}
+	if len(l.warned) >= chatWarnedMaxLen {
+		for id, until := range l.warned {
+			if !now.Before(until) {
+				delete(l.warned, id)
+			}
+		}
+	}
+	l.warned[chatID] = now.Add(wait)
+	return false, wait, true
+}
+
+// slowDownMsg returns the msg text of the rate limited chat.
+func slowDownMsg(wait time.Duration) string {
+	return fmt.Sprintf("slow down, try again in %d s", int(math.Ceil(wait.Seconds())))
+}
+
+// sendChatBufferLen is the max number of the queued messages of a chat.
+const sendChatBufferLen = 20
+
+// sendMinWait is the min wait for a chat turn.
+const sendMinWait = time.Millisecond
+
+var (
+	errSendQueueFull   = errors.New("chat send queue is full")
+	errSendQueueClosed = errors.New("send queue is closed")
+)
+
+// queuedMsg is the outgoing message in the send queue.
+type queuedMsg struct {
+	ctx     context.Context // the request context of the message
+	msg     tgbotapi.Chattable
+	release func() // called once the message is sent or dropped, may be nil
+}
+
+// sendQueue sends the outgoing messages by the telegram limits. The messages are buffered by chat
+// and sent by one goroutine, so a busy chat waits for its own limit without delaying the other chats.
+type sendQueue struct {
+	send        func(tgbotapi.Chattable) error
+	limiter     *ratelimit.Bucket
+	chatLimiter *ratelimit.Keyed
+
+	mtx    sync.Mutex
+	chats  map[int64][]queuedMsg // the queued messages by chat
+	order  []int64               // the chats with queued messages in turn
+	closed bool
+
+	ready     chan struct{} // signaled when a message is queued or the queue is closed
+	abortOnce sync.Once
+	aborted   chan struct{} // closed when the queued messages are dropped
+	done      chan struct{} // closed when run returns
+}
+
+// newSendQueue returns a new sendQueue of the send func.
+func newSendQueue(send func(tgbotapi.Chattable) error) *sendQueue {
+	return &sendQueue{
+		send:        send,
+		limiter:     ratelimit.NewBucket(sendLimit),
+		chatLimiter: ratelimit.NewKeyed(sendChatLimit),
+		chats:       make(map[int64][]queuedMsg),
+		ready:       make(chan struct{}, 1),
+		aborted:     make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+}
+
+// push queues the chat message. The release func is called once the message is sent or dropped,
+// also if it is not queued.
+func (q *sendQueue) push(ctx context.Context, chatID int64, msg tgbotapi.Chattable, release func()) error {
+	q.mtx.Lock()
+	var err error
+	switch {
+	case q.closed:
+		err = errSendQueueClosed
+	case len(q.chats[chatID]) >= sendChatBufferLen:
+		err = errSendQueueFull
+	default:
+		if len(q.chats[chatID]) == 0 {
+			q.order = append(q.order, chatID)
+		}
+		q.chats[chatID] = append(q.chats[chatID], queuedMsg{ctx: ctx, msg: msg, release: release})
+	}
+	q.mtx.Unlock()
+
+	if err != nil {
+		if release != nil {
+			release()
+		}
+		return err
+	}
+	q.signal()
+	return nil
+}
+
+// close stops queueing the messages, run returns once the queued ones are sent.
+func (q *sendQueue) close() {
+	q.mtx.Lock()
+	q.closed = true
+	q.mtx.Unlock()
+	q.signal()
+}
+
+// abort makes run return without sending the queued messages.
+func (q *sendQueue) abort() {
+	q.abortOnce.Do(func() { close(q.aborted) })
+}
+
+// signal wakes up run.
+func (q *sendQueue) signal() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// run sends the queued messages until ctx is done, the queue is aborted or closed and empty.
+func (q *sendQueue) run(ctx context.Context) {
+	defer close(q.done)
+	defer q.drop()
+
+	for {
+		m, wait, ok := q.next()
+		if !ok {
+			if wait < 0 {
+				return
+			}
+			// Wait for the chat turn or a new message, which may be of another chat.
+			if !q.sleep(ctx, wait) {
+				return
+			}
+			continue
+		}
+
+		if d := q.limiter.Reserve(); d > 0 && !q.sleep(ctx, d) {
+			q.release(m)
+			return
+		}
+		if err := q.send(m.msg); err != nil {
+			logger := zerologx.Ctx(m.ctx)
+			logger.Error().
+				Str("cmd", "send").
+				Err(err).Send()
+		}
+		q.release(m)
+	}
+}
+
+// next pops the message of the first chat in turn allowed by its limit. Otherwise, it returns the time to wait
+// for the earliest chat turn: 0 if the queue is empty and negative if it is also closed.
+func (q *sendQueue) next() (m queuedMsg, wait time.Duration, ok bool) {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	if len(q.order) == 0 {
+		if q.closed {
+			return queuedMsg{}, -1, false
+		}
+		return queuedMsg{}, 0, false
+	}
+
+	for i, chatID := range q.order {
+		allowed, d := q.chatLimiter.AllowAt(chatID)
+		if !allowed {
+			// The wait is rounded down, a zero one would park run until the next push.
+			if d < sendMinWait {
+				d = sendMinWait
+			}
+			if wait == 0 || d < wait {
+				wait = d
+			}
+			continue
+		}
+
+		msgs := q.chats[chatID]
+		m = msgs[0]
+		q.order = append(q.order[:i], q.order[i+1:]...)
+		if len(msgs) == 1 {
+			delete(q.chats, chatID)
+		} else {
+			// The chat takes its next turn after the other chats.
+			q.chats[chatID] = msgs[1:]
+			q.order = append(q.order, chatID)
+		}
+		return m, 0, true
+	}
+	return queuedMsg{}, wait, false
+}
+
+// sleep waits for d, forever if it is 0, or until a message is queued.
+// It reports false if ctx is done or the queue is aborted first.
+func (q *sendQueue) sleep(ctx context.Context, d time.Duration) bool {
+	var timeout <-chan time.Time
+	if d > 0 {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-q.aborted:
+		return false
+	case <-q.ready:
+	case <-timeout:
+	}
+	return true
+}
+
+// drop releases the queued messages without sending them.
+func (q *sendQueue) drop() {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	for _, msgs := range q.chats {
+		for _, m := range msgs {
+			q.release(m)
+		}
+	}
+	q.chats = make(map[int64][]queuedMsg)
+	q.order = nil
+	q.closed = true
+}
+
+// release calls the release func of the message.
+func (q *sendQueue) release(m queuedMsg) {
+	if m.release != nil {
+		m.release()
+	}
+}
@@ -0,0 +1,140 @@
+package telegram
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChatLimiter_allow(t *testing.T) {
+	l := newChatLimiter(1, 2)
+
+	for i := 0; i < 2; i++ {
+		ok, _, warn := l.allow(1)
+		assert.True(t, ok)
+		assert.False(t, warn)
+	}
+
+	ok, wait, warn := l.allow(1)
+	assert.False(t, ok)
+	assert.Greater(t, wait, time.Duration(0))
+	assert.True(t, warn, "first limited command is warned")
+
+	ok, _, warn = l.allow(1)
+	assert.False(t, ok)
+	assert.False(t, warn, "chat is warned once")
+
+	ok, _, _ = l.allow(2)
+	assert.True(t, ok, "chats are limited separately")
+}
+
+func TestChatLimiter_allow_noLimit(t *testing.T) {
+	l := newChatLimiter(0, 0)
+	assert.Nil(t, l)
+
+	for i := 0; i < 100; i++ {
+		ok, _, _ := l.allow(1)
+		assert.True(t, ok)
+	}
+}
+
+func TestSlowDownMsg(t *testing.T) {
+	assert.Equal(t, "slow down, try again in 3 s", slowDownMsg(2100*time.Millisecond))
+}
+
+// sentMsgs records the sent messages.
+type sentMsgs struct {
+	mtx  sync.Mutex
+	msgs []tgbotapi.MessageConfig
+	at   []time.Time
+}
+
+func (s *sentMsgs) send(c tgbotapi.Chattable) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.msgs = append(s.msgs, c.(tgbotapi.MessageConfig))
+	s.at = append(s.at, time.Now())
+	return nil
+}
+
+// sentAt returns the send time of the first message of the text.
+func (s *sentMsgs) sentAt(text string) (time.Time, bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for i, m := range s.msgs {
+		if m.Text == text {
+			return s.at[i], true
+		}
+	}
+	return time.Time{}, false
+}
+
+func TestSendQueue_chatsAreIndependent(t *testing.T) {
+	var sent sentMsgs
+	q := newSendQueue(sent.send)
+	go q.run(context.TODO())
+	defer q.abort()
+
+	// The chat A burst is 3 messages, the 4th one waits for a second.
+	start := time.Now()
+	for _, text := range []string{"a1", "a2", "a3", "a4", "a5"} {
+		require.NoError(t, q.push(context.TODO(), 1, tgbotapi.NewMessage(1, text), nil))
+	}
+	require.NoError(t, q.push(context.TODO(), 2, tgbotapi.NewMessage(2, "b1"), nil))
+
+	require.Eventually(t, func() bool {
+		_, ok := sent.sentAt("b1")
+		return ok
+	}, 500*time.Millisecond, 5*time.Millisecond)
+	at, _ := sent.sentAt("b1")
+	assert.Less(t, at.Sub(start), 500*time.Millisecond, "chat B is not delayed by chat A")
+
+	_, ok := sent.sentAt("a4")
+	assert.False(t, ok, "chat A waits for its limit")
+}
+
+func TestSendQueue_close(t *testing.T) {
+	var sent sentMsgs
+	q := newSendQueue(sent.send)
+	go q.run(context.TODO())
+
+	var released int
+	for _, text := range []string{"a1", "a2"} {
+		require.NoError(t, q.push(context.TODO(), 1, tgbotapi.NewMessage(1, text), func() { released++ }))
+	}
+	q.close()
+	<-q.done
+
+	assert.Len(t, sent.msgs, 2, "queued messages are sent")
+	assert.Equal(t, 2, released)
+	assert.ErrorIs(t, q.push(context.TODO(), 1, tgbotapi.NewMessage(1, "a3"), func() { released++ }), errSendQueueClosed)
+	assert.Equal(t, 3, released, "the not queued message is released")
+}
+
+func TestSendQueue_abort(t *testing.T) {
+	var sent sentMsgs
+	q := newSendQueue(sent.send)
+	go q.run(context.TODO())
+
+	var released int
+	for i := 0; i < sendChatBufferLen; i++ {
+		require.NoError(t, q.push(context.TODO(), 1, tgbotapi.NewMessage(1, "a"), func() { released++ }))
+	}
+	assert.ErrorIs(t, q.push(context.TODO(), 1, tgbotapi.NewMessage(1, "a"), nil), errSendQueueFull)
+
+	// The waiting for the chat limit is interrupted.
+	q.close()
+	q.abort()
+	select {
+	case <-q.done:
+	case <-time.After(time.Second):
+		t.Fatal("send queue is not aborted")
+	}
+	assert.Less(t, len(sent.msgs), sendChatBufferLen)
+	assert.Equal(t, sendChatBufferLen, released, "dropped messages are released")
+}
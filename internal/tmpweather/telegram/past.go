@@ -0,0 +1,170 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alukart32/tmp-weather/internal/pkg/zerologx"
+	"github.com/alukart32/tmp-weather/internal/tmpweather/archive"
+	"github.com/alukart32/tmp-weather/internal/tmpweather/storage"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// pastUsage is the reply to invalid /past arguments.
+const pastUsage = `enter "/past city_name YYYY-MM-DD", the home city is used without the name`
+
+// past returns the msg text of the city weather on a past date. The weather is taken from the archive
+// or, if it has no data, from the city forecasts made on that date. The chat home city is used without the city name.
+func (p *MsgHandler) past(ctx context.Context, chat *tgbotapi.Chat, args string) string {
+	logger := zerologx.Ctx(ctx)
+
+	city, date, err := parsePastArgs(args)
+	if err != nil {
+		logger.Info().
+			Str("cmd", "past").
+			Err(err).
+			Msg("invalid args")
+		return pastUsage
+	}
+	if len(city) == 0 {
+		home, err := p.homeCity(ctx, chat)
+		if err != nil {
+			logger.Error().
+				Str("cmd", "past").
+				Err(err).Send()
+		}
+		city = home
+	}
+	if len(city) == 0 {
+		return pastUsage
+	}
+	if !cityNameReg.MatchString(city) {
+		logger.Info().
+			Str("cmd", "past").
+			Msg("invalid name")
+		return "invalid city, try again"
+	}
+	if date.Before(archive.FirstDate) {
+		return fmt.Sprintf("the date must be since %s", archive.FirstDate.Format(time.DateOnly))
+	}
+	if !date.Before(time.Now().UTC().Truncate(24 * time.Hour)) {
+		return "the date must be in the past"
+	}
+
+	// The day is taken in UTC if the city time zone is unknown.
+	loc := time.UTC
+	var archiveErr error
+	if p.Archive != nil {
+		var l archive.Location
+		l, archiveErr = p.Archive.Geocode(ctx, city)
+		if archiveErr == nil {
+			loc = l.TimeLocation()
+
+			var day archive.Day
+			day, archiveErr = p.Archive.Day(ctx, l, date)
+			if archiveErr == nil {
+				logger.Debug().Object("day", day).Msg("archive respond")
+				return archiveDayMsg(day)
+			}
+		}
+		if !errors.Is(archiveErr, archive.ErrNoData) && !errors.Is(archiveErr, archive.ErrCityNotFound) {
+			logger.Error().
+				Str("cmd", "past").
+				Err(archiveErr).Send()
+		}
+	}
+
+	y, m, d := date.Date()
+	since := time.Date(y, m, d, 0, 0, 0, 0, loc)
+	forecasts, err := p.ForecastRepo.CityForecasts(ctx, storage.CityQuery{City: city, Since: since, Until: since.AddDate(0, 0, 1)})
+	switch {
+	case err == nil:
+		return storedDayMsg(since, forecasts)
+	case !errors.Is(err, storage.ErrNoData):
+		logger.Error().
+			Str("cmd", "past").
+			Err(err).Send()
+		return "internal error, try again"
+	case errors.Is(archiveErr, archive.ErrCityNotFound):
+		return "unknown city, try again"
+	case archiveErr != nil && !errors.Is(archiveErr, archive.ErrNoData):
+		return "weather archive is unavailable, try again later"
+	}
+	return "no weather data for the date"
+}
+
+// parsePastArgs parses the /past arguments: the optional city name and the date YYYY-MM-DD.
+func parsePastArgs(args string) (city string, date time.Time, err error) {
+	args = strings.TrimSpace(args)
+	i := strings.LastIndexByte(args, ' ')
+	date, err = time.Parse(time.DateOnly, args[i+1:])
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if i < 0 {
+		return "", date, nil
+	}
+	return strings.TrimSpace(args[:i]), date, nil
+}
+
+// archiveDayMsg returns the msg text of the archive day weather.
+func archiveDayMsg(d archive.Day) string {
+	var sb strings.Builder
+
+	name := d.Location.Name
+	if len(d.Location.CountryCode) != 0 {
+		name += ", " + d.Location.CountryCode
+	}
+	fmt.Fprintf(&sb, "%s\n%s, Open-Meteo archive\n\n", name, d.Date.Format(time.DateOnly))
+	fmt.Fprintf(&sb, "%s\n\n", d.Description())
+	fmt.Fprintf(&sb, "temp: %.1f..%.1f C, mean %.1f C\n", d.TempMin, d.TempMax, d.TempMean)
+	fmt.Fprintf(&sb, "precipitation: %.1f mm\n", d.Precipitation)
+	if d.Snowfall != 0 {
+		fmt.Fprintf(&sb, "snowfall: %.1f cm\n", d.Snowfall)
+	}
+	fmt.Fprintf(&sb, "max wind: %.1f m/s\n", d.WindMax)
+
+	return sb.String()
+}
+
+// storedDayMsg returns the msg text of the day weather summarized from the city forecasts.
+func storedDayMsg(date time.Time, forecasts []storage.WeatherForecast) string {
+	minTemp, maxTemp, maxWind := forecasts[0].Temp, forecasts[0].Temp, forecasts[0].Wind
+	var sumTemp float64
+	var sumHum int64
+	descs := make(map[string]int)
+	desc := forecasts[0].Desc
+	for _, f := range forecasts {
+		if f.Temp < minTemp {
+			minTemp = f.Temp
+		}
+		if f.Temp > maxTemp {
+			maxTemp = f.Temp
+		}
+		if f.Wind > maxWind {
+			maxWind = f.Wind
+		}
+		sumTemp += f.Temp
+		sumHum += f.Hum
+		descs[f.Desc]++
+		// The most frequent description, the earliest one of equally frequent.
+		if descs[f.Desc] > descs[desc] {
+			desc = f.Desc
+		}
+	}
+	n := len(forecasts)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s\n%s, our forecasts: %d\n\n", forecasts[0].City, date.Format(time.DateOnly), n)
+	if len(desc) != 0 {
+		fmt.Fprintf(&sb, "%s\n\n", desc)
+	}
+	fmt.Fprintf(&sb, "temp: %.1f..%.1f C, mean %.1f C\n", minTemp, maxTemp, sumTemp/float64(n))
+	fmt.Fprintf(&sb, "hum: %d %%\n", sumHum/int64(n))
+	fmt.Fprintf(&sb, "max wind: %.1f m/s\n", maxWind)
+
+	return sb.String()
+}
@@ -0,0 +1,82 @@
+package telegram
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alukart32/tmp-weather/internal/tmpweather/storage"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePastArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     string
+		wantCity string
+		wantDate string
+		wantErr  bool
+	}{
+		{name: "No args", wantErr: true},
+		{name: "Date", args: "2024-01-02", wantDate: "2024-01-02"},
+		{name: "City and date", args: " New York 2024-01-02 ", wantCity: "New York", wantDate: "2024-01-02"},
+		{name: "No date", args: "Berlin", wantErr: true},
+		{name: "Invalid date", args: "Berlin 2024-02-30", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			city, date, err := parsePastArgs(tt.args)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantCity, city)
+			assert.Equal(t, tt.wantDate, date.Format(time.DateOnly))
+		})
+	}
+}
+
+func TestMsgHandler_past(t *testing.T) {
+	ctx := context.Background()
+	repo := storage.NewMemoryRepo()
+	day := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	for i, f := range []storage.WeatherForecast{
+		{City: "Berlin", Desc: "clear sky", Temp: 2, Hum: 60, Wind: 3, MadeAt: day.Add(8 * time.Hour)},
+		{City: "Berlin", Desc: "light rain", Temp: 6.5, Hum: 80, Wind: 5.5, MadeAt: day.Add(12 * time.Hour)},
+		{City: "Berlin", Desc: "light rain", Temp: 4, Hum: 70, Wind: 4, MadeAt: day.Add(18 * time.Hour)},
+		{City: "Berlin", Desc: "snow", Temp: -1, Hum: 90, Wind: 1, MadeAt: day.Add(-time.Hour)},
+	} {
+		f.ChatID, f.MsgID = 1, i
+		require.NoError(t, repo.Insert(ctx, f))
+	}
+	p := &MsgHandler{ForecastRepo: repo, ChatRepo: repo}
+	chat := &tgbotapi.Chat{ID: 1, Type: "private"}
+
+	tests := []struct {
+		name string
+		args string
+		want string
+	}{
+		{name: "Invalid args", args: "Berlin yesterday", want: pastUsage},
+		{name: "No home city", args: "2024-01-02", want: pastUsage},
+		{name: "Future date", args: "Berlin 2999-01-01", want: "the date must be in the past"},
+		{name: "Before archive", args: "Berlin 1900-01-01", want: "the date must be since 1940-01-01"},
+		{name: "No data", args: "Paris 2024-01-02", want: "no weather data for the date"},
+		{
+			name: "Our forecasts",
+			args: "berlin 2024-01-02",
+			want: "Berlin\n2024-01-02, our forecasts: 3\n\nlight rain\n\n" +
+				"temp: 2.0..6.5 C, mean 4.2 C\nhum: 70 %\nmax wind: 5.5 m/s\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, p.past(ctx, chat, tt.args))
+		})
+	}
+}
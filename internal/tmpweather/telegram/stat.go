@@ -0,0 +1,70 @@
+package telegram
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// statPeriods are the named periods of the stat command.
+var statPeriods = map[string]time.Duration{
+	"day":   24 * time.Hour,
+	"week":  7 * 24 * time.Hour,
+	"month": 30 * 24 * time.Hour,
+	"year":  365 * 24 * time.Hour,
+}
+
+// maxPeriod is the max period of the stat, chart and export commands.
+const maxPeriod = 10 * 365 * 24 * time.Hour
+
+// parseStatArgs parses the "[city] [period]" arguments of the stat command.
+// The zero period means all the time.
+func parseStatArgs(args string) (city string, period time.Duration, err error) {
+	fields := strings.Fields(args)
+	if n := len(fields); n != 0 {
+		if p, ok := parsePeriod(fields[n-1]); ok {
+			period = p
+			fields = fields[:n-1]
+		}
+	}
+
+	city = strings.Join(fields, " ")
+	if !cityNameReg.MatchString(city) {
+		return "", 0, fmt.Errorf("invalid city: %q", city)
+	}
+	return city, period, nil
+}
+
+// parsePeriod parses the named period or the number of hours, days or weeks: 12h, 7d, 2w.
+// The periods above maxPeriod are invalid.
+func parsePeriod(s string) (time.Duration, bool) {
+	s = strings.ToLower(s)
+	if p, ok := statPeriods[s]; ok {
+		return p, true
+	}
+	if len(s) < 2 {
+		return 0, false
+	}
+
+	n, err := strconv.Atoi(s[:len(s)-1])
+	if err != nil || n <= 0 {
+		return 0, false
+	}
+	var unit time.Duration
+	switch s[len(s)-1] {
+	case 'h':
+		unit = time.Hour
+	case 'd':
+		unit = 24 * time.Hour
+	case 'w':
+		unit = 7 * 24 * time.Hour
+	default:
+		return 0, false
+	}
+	// The bound is checked before the multiplication to not overflow.
+	if n > int(maxPeriod/unit) {
+		return 0, false
+	}
+	return time.Duration(n) * unit, true
+}
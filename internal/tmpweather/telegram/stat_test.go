@@ -0,0 +1,44 @@
+package telegram
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseStatArgs(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       string
+		wantCity   string
+		wantPeriod time.Duration
+		wantErr    bool
+	}{
+		{name: "No args"},
+		{name: "City", args: "Moscow", wantCity: "Moscow"},
+		{name: "City with spaces", args: "New York", wantCity: "New York"},
+		{name: "Named period", args: "week", wantPeriod: 7 * 24 * time.Hour},
+		{name: "City and hours", args: "Berlin 12h", wantCity: "Berlin", wantPeriod: 12 * time.Hour},
+		{name: "City and days", args: "New York 7d", wantCity: "New York", wantPeriod: 7 * 24 * time.Hour},
+		{name: "Weeks", args: "2W", wantPeriod: 14 * 24 * time.Hour},
+		{name: "Invalid city", args: "Berlin1 7d", wantErr: true},
+		{name: "Invalid period", args: "Berlin 0d", wantErr: true},
+		{name: "Max period", args: "Berlin 3650d", wantCity: "Berlin", wantPeriod: maxPeriod},
+		{name: "Too long period", args: "Paris 99999999w", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			city, period, err := parseStatArgs(tt.args)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantCity, city)
+			assert.Equal(t, tt.wantPeriod, period)
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alukart32/tmp-weather/internal/pkg/zerologx"
+	"github.com/alukart32/tmp-weather/internal/tmpweather/astro"
+	"github.com/alukart32/tmp-weather/internal/tmpweather/weather"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// sun returns the msg text of the city sun times today. The chat home city is used without the city name.
+// The forecast gives the city coordinates and time zone, the times are computed locally.
+func (p *MsgHandler) sun(ctx context.Context, chat *tgbotapi.Chat, args string) string {
+	logger := zerologx.Ctx(ctx)
+
+	city := strings.TrimSpace(args)
+	if len(city) == 0 {
+		home, err := p.homeCity(ctx, chat)
+		if err != nil {
+			logger.Error().
+				Str("cmd", "sun").
+				Err(err).Send()
+		}
+		city = home
+	}
+	if len(city) == 0 {
+		return `enter "/sun city_name" or set your home city`
+	}
+	if !cityNameReg.MatchString(city) {
+		logger.Info().
+			Str("cmd", "sun").
+			Msg("invalid name")
+		return "invalid city, try again"
+	}
+
+	forecast, err := p.Forecaster.Forecast(ctx, city)
+	if err != nil {
+		logger.Error().
+			Str("cmd", "sun").
+			Err(err).Send()
+		return forecastErrMsg(err)
+	}
+
+	return sunMsg(forecast, time.Now())
+}
+
+// sunMsg returns the msg text of the sun times of the forecast city on the date in the city time zone.
+func sunMsg(f weather.Forecast, date time.Time) string {
+	date = date.In(f.Location())
+	day := astro.SunDay(date, f.Coord.Lat, f.Coord.Lon)
+
+	var sb strings.Builder
+	name := f.Name
+	if len(f.Sys.Country) != 0 {
+		name += ", " + f.Sys.Country
+	}
+	fmt.Fprintf(&sb, "%s\n%s, UTC%s\n\n", name, date.Format("2006-01-02"), date.Format("-07:00"))
+
+	switch {
+	case day.PolarDay:
+		sb.WriteString("polar day, the sun does not set\n")
+	case day.PolarNight:
+		sb.WriteString("polar night, the sun does not rise\n")
+	default:
+		fmt.Fprintf(&sb, "sunrise: %s\n", day.Sunrise.Format("15:04"))
+		fmt.Fprintf(&sb, "sunset: %s\n", day.Sunset.Format("15:04"))
+	}
+	fmt.Fprintf(&sb, "day length: %s\n", dayLength(day.DayLength()))
+	fmt.Fprintf(&sb, "solar noon: %s\n\n", day.Noon.Format("15:04"))
+
+	switch {
+	case !day.CivilDawn.IsZero():
+		fmt.Fprintf(&sb, "civil twilight: %s-%s, %s-%s\n",
+			day.CivilDawn.Format("15:04"), orTime(day.Sunrise, day.Noon),
+			orTime(day.Sunset, day.Noon), day.CivilDusk.Format("15:04"))
+	case !day.PolarNight:
+		// The sun does not go below the civil twilight altitude: white nights.
+		sb.WriteString("civil twilight: all night\n")
+	}
+
+	switch {
+	case !day.GoldenMorningEnd.IsZero() && !day.PolarDay:
+		fmt.Fprintf(&sb, "golden hour: %s-%s, %s-%s\n",
+			day.Sunrise.Format("15:04"), day.GoldenMorningEnd.Format("15:04"),
+			day.GoldenEveningStart.Format("15:04"), day.Sunset.Format("15:04"))
+	case !day.GoldenMorningEnd.IsZero():
+		fmt.Fprintf(&sb, "golden hour: until %s, from %s\n",
+			day.GoldenMorningEnd.Format("15:04"), day.GoldenEveningStart.Format("15:04"))
+	case !day.Sunrise.IsZero():
+		// The sun does not rise above the golden hour altitude.
+		sb.WriteString("golden hour: all day\n")
+	}
+
+	return sb.String()
+}
+
+// orTime returns the time t as HH:MM, the fallback time if t is zero.
+func orTime(t, fallback time.Time) string {
+	if t.IsZero() {
+		return fallback.Format("15:04")
+	}
+	return t.Format("15:04")
+}
+
+// dayLength returns the duration as 13h 21m.
+func dayLength(d time.Duration) string {
+	d = d.Round(time.Minute)
+	return fmt.Sprintf("%dh %02dm", int(d.Hours()), int(d.Minutes())%60)
+}
@@ -0,0 +1,60 @@
+package telegram
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alukart32/tmp-weather/internal/tmpweather/weather"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSunMsg(t *testing.T) {
+	var zocca weather.Forecast
+	zocca.Name, zocca.Sys.Country = "Zocca", "IT"
+	zocca.Coord.Lat, zocca.Coord.Lon = 44.34, 10.99
+	zocca.Timezone = 2 * 60 * 60
+
+	var tromso weather.Forecast
+	tromso.Name = "Tromso"
+	tromso.Coord.Lat, tromso.Coord.Lon = 69.65, 18.96
+	tromso.Timezone = 60 * 60
+
+	tests := []struct {
+		name     string
+		forecast weather.Forecast
+		date     time.Time
+		want     []string
+	}{
+		{
+			name:     "Day",
+			forecast: zocca,
+			date:     time.Date(2022, 8, 30, 10, 0, 0, 0, time.UTC),
+			want: []string{
+				"Zocca, IT\n2022-08-30, UTC+02:00\n",
+				"sunrise: 06:3", "sunset: 19:5", "day length: 13h 2",
+				"civil twilight: 06:0", "golden hour: 06:3",
+			},
+		},
+		{
+			name:     "Polar night",
+			forecast: tromso,
+			date:     time.Date(2022, 12, 21, 12, 0, 0, 0, time.UTC),
+			want:     []string{"polar night, the sun does not rise\n", "day length: 0h 00m\n", "civil twilight: "},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := sunMsg(tt.forecast, tt.date)
+			for _, want := range tt.want {
+				assert.Contains(t, msg, want)
+			}
+		})
+	}
+
+	tromso.Timezone = 2 * 60 * 60
+	msg := sunMsg(tromso, time.Date(2022, 6, 21, 12, 0, 0, 0, time.UTC))
+	assert.Contains(t, msg, "polar day, the sun does not set\n")
+	assert.Contains(t, msg, "day length: 24h 00m\n")
+	assert.NotContains(t, msg, "sunrise")
+}
@@ -0,0 +1,207 @@
+package weather
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alukart32/tmp-weather/internal/pkg/otelx"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// AirForecaster defines an air quality forecaster by city name.
+type AirForecaster struct {
+	forecaster CityForecaster // the calls are made by its workers
+}
+
+// AirQuality accepts the city name and returns the current air quality.
+// The city coordinates are resolved by the geocoding API first, so it makes two API calls
+// on one CityForecaster worker. ErrStopped is returned once the CityForecaster is stopped.
+func (f AirForecaster) AirQuality(ctx context.Context, cityName string) (air AirQuality, err error) {
+	ctx, span := tracer.Start(ctx, "AirForecaster.AirQuality")
+	span.SetAttributes(attribute.String("city", cityName))
+	defer func() { otelx.End(span, err) }()
+
+	if f.forecaster.client == nil {
+		return AirQuality{}, ErrStopped
+	}
+
+	var res AirQuality
+	err = f.forecaster.do(ctx, func(ctx context.Context, c *client) error {
+		loc, err := c.geocode(ctx, cityName)
+		if err != nil {
+			return err
+		}
+		res, err = c.airQuality(ctx, loc)
+		return err
+	})
+	if err != nil {
+		return AirQuality{}, err
+	}
+	return res, nil
+}
+
+// Location represents the geocoded city.
+type Location struct {
+	Name    string
+	Country string
+	State   string
+	Lat     float64
+	Lon     float64
+}
+
+// geocode returns the location of the city name.
+func (c *client) geocode(ctx context.Context, cityName string) (Location, error) {
+	q := url.Values{}
+	q.Set("q", cityName)
+	q.Set("limit", "1")
+
+	var locs []Location
+	if err := c.get(ctx, geocodingEndpoint, q, cityName, &locs); err != nil {
+		return Location{}, err
+	}
+	if len(locs) == 0 {
+		return Location{}, ErrCityNotFound
+	}
+	return locs[0], nil
+}
+
+// airQuality returns the current air quality at the location.
+func (c *client) airQuality(ctx context.Context, loc Location) (AirQuality, error) {
+	q := url.Values{}
+	q.Set("lat", strconv.FormatFloat(loc.Lat, 'f', -1, 64))
+	q.Set("lon", strconv.FormatFloat(loc.Lon, 'f', -1, 64))
+
+	var resp struct {
+		List []struct {
+			Dt   int64
+			Main struct {
+				AQI AQI
+			}
+			Components AirComponents
+		}
+	}
+	if err := c.get(ctx, airPollutionEndpoint, q, loc.Name, &resp); err != nil {
+		return AirQuality{}, err
+	}
+	if len(resp.List) == 0 || !resp.List[0].Main.AQI.valid() {
+		return AirQuality{}, fmt.Errorf("%w: no air quality data", ErrInvalidResponse)
+	}
+
+	data := resp.List[0]
+	return AirQuality{
+		Location:   loc,
+		MeasuredAt: time.Unix(data.Dt, 0),
+		AQI:        data.Main.AQI,
+		Components: data.Components,
+	}, nil
+}
+
+// AQI is the openweathermap air quality index from 1 (good) to 5 (very poor):
+// https://openweathermap.org/api/air-pollution.
+type AQI int
+
+// Air quality indexes.
+const (
+	AQIGood AQI = iota + 1
+	AQIFair
+	AQIModerate
+	AQIPoor
+	AQIVeryPoor
+)
+
+// aqiCategories are the category names by AQI.
+var aqiCategories = [...]string{
+	AQIGood:     "good",
+	AQIFair:     "fair",
+	AQIModerate: "moderate",
+	AQIPoor:     "poor",
+	AQIVeryPoor: "very poor",
+}
+
+// aqiGuidance are the health guidance texts by AQI.
+var aqiGuidance = [...]string{
+	AQIGood:     "Air quality is good, enjoy outdoor activities.",
+	AQIFair:     "Air quality is acceptable. Unusually sensitive people should consider reducing prolonged outdoor exertion.",
+	AQIModerate: "Sensitive groups, e.g. people with heart or lung disease, children and older adults, should reduce prolonged outdoor exertion.",
+	AQIPoor:     "Everyone should reduce prolonged outdoor exertion, sensitive groups should avoid it.",
+	AQIVeryPoor: "Avoid outdoor activities and keep windows closed, sensitive groups should stay indoors.",
+}
+
+// valid reports whether the index is known.
+func (a AQI) valid() bool {
+	return a >= AQIGood && a <= AQIVeryPoor
+}
+
+// String returns the AQI category name.
+func (a AQI) String() string {
+	if !a.valid() {
+		return "unknown"
+	}
+	return aqiCategories[a]
+}
+
+// Guidance returns the health guidance text of the AQI.
+func (a AQI) Guidance() string {
+	if !a.valid() {
+		return ""
+	}
+	return aqiGuidance[a]
+}
+
+// AirComponents are the pollutant concentrations, μg/m3.
+type AirComponents struct {
+	CO   float64 `json:"co"`
+	NO   float64 `json:"no"`
+	NO2  float64 `json:"no2"`
+	O3   float64 `json:"o3"`
+	SO2  float64 `json:"so2"`
+	PM25 float64 `json:"pm2_5"`
+	PM10 float64 `json:"pm10"`
+	NH3  float64 `json:"nh3"`
+}
+
+// AirQuality represents the current air quality of the city.
+type AirQuality struct {
+	Location   Location
+	MeasuredAt time.Time
+	AQI        AQI
+	Components AirComponents
+}
+
+// ToMsg converts the AirQuality to the msg format of the telegram bot.
+func (a AirQuality) ToMsg() string {
+	var sb strings.Builder
+
+	name := a.Location.Name
+	if len(a.Location.Country) != 0 {
+		name += ", " + a.Location.Country
+	}
+	fmt.Fprintf(&sb, "%s\n\n", name)
+	fmt.Fprintf(&sb, "air quality: %s (%d of 5)\n\n", a.AQI, a.AQI)
+	fmt.Fprintf(&sb, "PM2.5: %.1f μg/m3\n", a.Components.PM25)
+	fmt.Fprintf(&sb, "PM10: %.1f μg/m3\n", a.Components.PM10)
+	fmt.Fprintf(&sb, "O3: %.1f μg/m3\n", a.Components.O3)
+	fmt.Fprintf(&sb, "NO2: %.1f μg/m3\n\n", a.Components.NO2)
+	sb.WriteString(a.AQI.Guidance())
+	sb.WriteString("\n")
+
+	return sb.String()
+}
+
+// MarshalZerologObject adds AirQuality to the logger as an object.
+func (a AirQuality) MarshalZerologObject(e *zerolog.Event) {
+	e.
+		Str("city", a.Location.Name).
+		Str("country", a.Location.Country).
+		Time("measuredAt", a.MeasuredAt).
+		Int("aqi", int(a.AQI)).
+		Float64("pm2_5", a.Components.PM25).
+		Float64("pm10", a.Components.PM10).
+		Float64("o3", a.Components.O3).
+		Float64("no2", a.Components.NO2)
+}
@@ -0,0 +1,93 @@
+package weather
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAirForecaster_AirQuality(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		switch r.URL.Path {
+		case geocodingEndpoint:
+			if q.Get("q") != "London" {
+				_, _ = w.Write([]byte(`[]`))
+				return
+			}
+			_, _ = w.Write([]byte(`[{"name":"London","local_names":{"en":"London"},"lat":51.5073219,"lon":-0.1276474,"country":"GB","state":"England"}]`))
+		case airPollutionEndpoint:
+			assert.Equal(t, "51.5073219", q.Get("lat"))
+			assert.Equal(t, "-0.1276474", q.Get("lon"))
+			_, _ = w.Write([]byte(`{"coord":{"lon":-0.1276,"lat":51.5073},"list":[{"main":{"aqi":3},` +
+				`"components":{"co":201.94,"no":0.02,"no2":26.05,"o3":68.66,"so2":5.07,"pm2_5":21.46,"pm10":24.41,"nh3":0.12},` +
+				`"dt":1606147200}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := newClient(Config{APIToken: "token", Timeout: time.Second})
+	c.api = srv.URL
+	forecaster := CityForecaster{msgs: make(chan forecastRequest), client: c}
+	forecaster.stopped = workers(ctx, c, 1, forecaster.msgs)
+	f := forecaster.Air()
+
+	air, err := f.AirQuality(context.TODO(), "London")
+	require.NoError(t, err)
+	assert.Equal(t, "GB", air.Location.Country)
+	assert.Equal(t, AQIModerate, air.AQI)
+	assert.Equal(t, 21.46, air.Components.PM25)
+	assert.Equal(t, int64(1606147200), air.MeasuredAt.Unix())
+	assert.Equal(t, `London, GB
+
+air quality: moderate (3 of 5)
+
+PM2.5: 21.5 μg/m3
+PM10: 24.4 μg/m3
+O3: 68.7 μg/m3
+NO2: 26.1 μg/m3
+
+`+AQIModerate.Guidance()+"\n", air.ToMsg())
+
+	_, err = f.AirQuality(context.TODO(), "Atlantis")
+	assert.ErrorIs(t, err, ErrCityNotFound)
+
+	_, err = AirForecaster{}.AirQuality(context.TODO(), "London")
+	assert.ErrorIs(t, err, ErrStopped)
+
+	// No calls are made after the forecaster is stopped.
+	cancel()
+	<-forecaster.stopped
+	_, err = f.AirQuality(context.TODO(), "London")
+	assert.ErrorIs(t, err, ErrStopped)
+}
+
+func TestAQI(t *testing.T) {
+	tests := []struct {
+		aqi  AQI
+		want string
+	}{
+		{aqi: AQIGood, want: "good"},
+		{aqi: AQIFair, want: "fair"},
+		{aqi: AQIModerate, want: "moderate"},
+		{aqi: AQIPoor, want: "poor"},
+		{aqi: AQIVeryPoor, want: "very poor"},
+		{aqi: 0, want: "unknown"},
+		{aqi: 6, want: "unknown"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, tt.aqi.String())
+		assert.Equal(t, tt.aqi.valid(), len(tt.aqi.Guidance()) != 0, tt.want)
+	}
+}
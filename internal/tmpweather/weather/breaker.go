@@ -0,0 +1,135 @@
+package weather
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/alukart32/tmp-weather/internal/pkg/otelx"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var meter = otelx.Meter("github.com/alukart32/tmp-weather/internal/tmpweather/weather")
+
+var weatherMetrics = newWeatherMetrics()
+
+// weatherMetricsSet holds the openweathermap client instruments.
+type weatherMetricsSet struct {
+	retries      metric.Int64Counter
+	breakerState metric.Int64ObservableGauge
+}
+
+func newWeatherMetrics() weatherMetricsSet {
+	var (
+		m   weatherMetricsSet
+		err error
+	)
+	m.retries, err = meter.Int64Counter("tmpweather.weather.retries",
+		metric.WithDescription("Retried openweathermap calls"))
+	if err != nil {
+		otel.Handle(err)
+	}
+	m.breakerState, err = meter.Int64ObservableGauge("tmpweather.weather.breaker.state",
+		metric.WithDescription("Openweathermap circuit breaker state: 0 - closed, 1 - half-open, 2 - open"))
+	if err != nil {
+		otel.Handle(err)
+	}
+	return m
+}
+
+// breakerState is the state of the circuit breaker.
+type breakerState int
+
+// Circuit breaker states.
+const (
+	breakerClosed   breakerState = iota // calls pass
+	breakerHalfOpen                     // a probe call passes
+	breakerOpen                         // calls fail fast
+)
+
+// breaker is the circuit breaker of the provider calls. It opens after threshold failed calls in a row,
+// fails the calls fast for timeout and then lets a probe call pass: the breaker closes if it succeeds
+// and opens again otherwise. It is safe for concurrent use.
+type breaker struct {
+	threshold int
+	timeout   time.Duration
+	now       func() time.Time
+
+	mtx      sync.Mutex
+	state    breakerState
+	failures int       // failed calls in a row
+	until    time.Time // the time the next probe call passes
+}
+
+// newBreaker returns a new closed breaker, or nil if threshold is not positive.
+// The nil breaker lets all calls pass.
+func newBreaker(threshold int, timeout time.Duration) *breaker {
+	if threshold <= 0 {
+		return nil
+	}
+	b := &breaker{
+		threshold: threshold,
+		timeout:   timeout,
+		now:       time.Now,
+	}
+
+	_, err := meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		o.ObserveInt64(weatherMetrics.breakerState, int64(b.current()))
+		return nil
+	}, weatherMetrics.breakerState)
+	if err != nil {
+		otel.Handle(err)
+	}
+	return b
+}
+
+// allow reports whether the call can pass.
+func (b *breaker) allow() bool {
+	if b == nil {
+		return true
+	}
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if b.state == breakerClosed {
+		return true
+	}
+	now := b.now()
+	if now.Before(b.until) {
+		return false
+	}
+	// The probe call is let pass once per timeout in case its result is never recorded.
+	b.state = breakerHalfOpen
+	b.until = now.Add(b.timeout)
+	return true
+}
+
+// record records the result of the passed call.
+func (b *breaker) record(ok bool) {
+	if b == nil {
+		return
+	}
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if ok {
+		b.state = breakerClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.state == breakerHalfOpen || b.failures >= b.threshold {
+		b.state = breakerOpen
+		b.until = b.now().Add(b.timeout)
+	}
+}
+
+// current returns the breaker state.
+func (b *breaker) current() breakerState {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	return b.state
+}
@@ -0,0 +1,253 @@
+package weather
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/alukart32/tmp-weather/internal/pkg/ratelimit"
+	"github.com/alukart32/tmp-weather/internal/pkg/zerologx"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// owmAPI is the openweathermap API.
+const owmAPI = "https://api.openweathermap.org"
+
+// openweathermap API endpoints.
+const (
+	weatherEndpoint      = "/data/2.5/weather"       // https://openweathermap.org/current
+	forecastEndpoint     = "/data/2.5/forecast"      // https://openweathermap.org/forecast5
+	geocodingEndpoint    = "/geo/1.0/direct"         // https://openweathermap.org/api/geocoding-api
+	airPollutionEndpoint = "/data/2.5/air_pollution" // https://openweathermap.org/api/air-pollution
+)
+
+// maxRetryWait is the max time to wait before a retry. The call is not retried
+// if the provider asks to wait longer.
+const maxRetryWait = 10 * time.Second
+
+// client is the openweathermap client. It is safe for concurrent use.
+type client struct {
+	http       *http.Client
+	api        string
+	apiToken   string
+	retries    int
+	retryDelay time.Duration
+	breaker    *breaker
+	limiter    *ratelimit.Bucket // API calls limiter, nil if there is no limit
+}
+
+// newClient returns a new client of the config.
+func newClient(cfg Config) *client {
+	c := &client{
+		http: &http.Client{
+			Timeout: cfg.Timeout,
+			Transport: otelhttp.NewTransport(&http.Transport{
+				MaxIdleConns: 15,
+			}),
+		},
+		api:        owmAPI,
+		apiToken:   cfg.APIToken,
+		retries:    cfg.Retries,
+		retryDelay: cfg.RetryDelay,
+		breaker:    newBreaker(cfg.BreakerThreshold, cfg.BreakerTimeout),
+	}
+	if cfg.RateLimit > 0 {
+		// Bursts are limited to spread the calls over the minute.
+		c.limiter = ratelimit.NewBucket(ratelimit.Limit{
+			N:     cfg.RateLimit,
+			Per:   time.Minute,
+			Burst: cfg.RateLimit / 10,
+		})
+	}
+	return c
+}
+
+// callResult is the result of one openweathermap call.
+type callResult struct {
+	err        error
+	responded  bool          // the provider response is received
+	retry      bool          // the call can be retried
+	retryAfter time.Duration // the retry delay asked by the provider
+}
+
+// forecast returns the city forecast.
+func (c *client) forecast(ctx context.Context, cityName string) (Forecast, error) {
+	q := url.Values{}
+	q.Set("units", "metric")
+	q.Set("q", cityName)
+
+	var forecast Forecast
+	err := c.get(ctx, weatherEndpoint, q, cityName, &forecast)
+	if err != nil {
+		return Forecast{}, err
+	}
+	if len(forecast.Weather) == 0 {
+		return Forecast{}, fmt.Errorf("%w: no weather conditions", ErrInvalidResponse)
+	}
+	forecast.MadeAt = time.Now()
+	return forecast, nil
+}
+
+// get calls the endpoint with the query and decodes the response into v, city is logged.
+// Timeouts, 429 and 5xx responses are retried with jittered exponential backoff.
+// It fails fast with ErrUnavailable while the breaker is open. Otherwise, each attempt takes a token
+// of the calls limit, ErrCallsLimited is returned above it.
+func (c *client) get(ctx context.Context, endpoint string, q url.Values, city string, v any) error {
+	logger := zerologx.Ctx(ctx)
+
+	// The open breaker does not take the calls limit.
+	if !c.breaker.allow() {
+		return ErrUnavailable
+	}
+	if !c.allow() {
+		return ErrCallsLimited
+	}
+
+	delay := c.retryDelay
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && !c.allow() {
+			// The failed attempt is not retried.
+			c.breaker.record(false)
+			return ErrCallsLimited
+		}
+		res := c.call(ctx, endpoint, q, city, v)
+		if res.err != nil && ctx.Err() != nil {
+			// The call is cancelled by the caller, it says nothing about the provider.
+			return res.err
+		}
+		if !res.retry {
+			switch {
+			case res.responded:
+				// The provider is up, even if the city is not found.
+				c.breaker.record(true)
+			case errors.Is(res.err, ErrCorruptedCall):
+				// The provider is unreachable, e.g. the connection is refused or the DNS lookup failed.
+				c.breaker.record(false)
+			}
+			return res.err
+		}
+
+		wait := jitter(delay)
+		if res.retryAfter > wait {
+			wait = res.retryAfter
+		}
+		if attempt >= c.retries || wait > maxRetryWait {
+			c.breaker.record(false)
+			return res.err
+		}
+		logger.Warn().
+			Str("op", "call "+endpoint).
+			Str("city", city).
+			Int("attempt", attempt+1).
+			Dur("wait", wait).
+			Err(res.err).Msg("retry")
+		weatherMetrics.retries.Add(ctx, 1)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+		delay *= 2
+	}
+}
+
+// allow takes a token of the calls limit and reports whether it is taken.
+func (c *client) allow() bool {
+	return c.limiter == nil || c.limiter.Allow()
+}
+
+// call makes one openweathermap call and decodes the response into v.
+func (c *client) call(ctx context.Context, endpoint string, q url.Values, city string, v any) callResult {
+	logger := zerologx.Ctx(ctx)
+
+	query := url.Values{"appid": {c.apiToken}}
+	for k, vs := range q {
+		query[k] = vs
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.api+endpoint+"?"+query.Encode(), nil)
+	if err != nil {
+		return callResult{err: err}
+	}
+
+	logger.Info().
+		Str("op", "call "+endpoint).
+		Str("city", city).Send()
+	resp, err := c.http.Do(req)
+	if err != nil {
+		logger.Info().
+			Str("op", "respond "+endpoint).
+			Str("city", city).
+			Err(err).Send()
+		return callResult{err: fmt.Errorf("%w: %v", ErrCorruptedCall, err), retry: isTimeout(err)}
+	}
+	defer resp.Body.Close()
+	logger.Info().
+		Str("op", "respond "+endpoint).
+		Str("city", city).
+		Int("respCode", resp.StatusCode).Send()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return callResult{err: fmt.Errorf("%w: read response body: %v", ErrCorruptedCall, err), retry: isTimeout(err)}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		res := callResult{err: newAPIError(resp.StatusCode, body), responded: true}
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError {
+			res.retry = true
+			res.retryAfter = retryAfter(resp.Header)
+		}
+		return res
+	}
+
+	if err = json.Unmarshal(body, v); err != nil {
+		return callResult{err: fmt.Errorf("%w: unmarshal response body: %v", ErrInvalidResponse, err), responded: true}
+	}
+	return callResult{responded: true}
+}
+
+// isTimeout reports whether the transport error is a timeout.
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// retryAfter returns the delay of the Retry-After header in seconds or HTTP date, 0 if it is not set.
+func retryAfter(h http.Header) time.Duration {
+	v := h.Get("Retry-After")
+	if len(v) == 0 {
+		return 0
+	}
+	if s, err := strconv.Atoi(v); err == nil && s > 0 {
+		return time.Duration(s) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// jitter returns a random delay in [d/2, d) to spread the retries of the concurrent calls.
+func jitter(d time.Duration) time.Duration {
+	if d <= 1 {
+		return d
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)))
+}
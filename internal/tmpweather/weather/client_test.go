@@ -0,0 +1,275 @@
+package weather
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testForecast = `{"main":{"temp":10.5,"feels_like":9,"humidity":50},"weather":[{"description":"clear sky"}],"wind":{"speed":2}}`
+
+// testClient returns a client of the test server responding with the statuses in order,
+// the last status is repeated.
+func testClient(t *testing.T, retries, threshold int, header http.Header, statuses ...int) (*client, *int32) {
+	t.Helper()
+
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(atomic.AddInt32(&calls, 1))
+		if n > len(statuses) {
+			n = len(statuses)
+		}
+		for k, v := range header {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(statuses[n-1])
+		switch statuses[n-1] {
+		case http.StatusOK:
+			_, _ = w.Write([]byte(testForecast))
+		case http.StatusUnauthorized:
+			_, _ = w.Write([]byte(`{"cod":401,"message":"Invalid API key."}`))
+		default:
+			_, _ = w.Write([]byte(`{"cod":"` + strconv.Itoa(statuses[n-1]) + `","message":"` + http.StatusText(statuses[n-1]) + `"}`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	c := newClient(Config{
+		APIToken:         "token",
+		Timeout:          time.Second,
+		Retries:          retries,
+		RetryDelay:       time.Millisecond,
+		BreakerThreshold: threshold,
+		BreakerTimeout:   time.Hour,
+	})
+	c.api = srv.URL
+	return c, &calls
+}
+
+func TestClient_forecast(t *testing.T) {
+	tests := []struct {
+		name      string
+		retries   int
+		statuses  []int
+		wantErr   error
+		wantCalls int32
+	}{
+		{
+			name:      "OK",
+			retries:   2,
+			statuses:  []int{http.StatusOK},
+			wantCalls: 1,
+		},
+		{
+			name:      "5xx retried",
+			retries:   2,
+			statuses:  []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusOK},
+			wantCalls: 3,
+		},
+		{
+			name:      "429 retried",
+			retries:   2,
+			statuses:  []int{http.StatusTooManyRequests, http.StatusOK},
+			wantCalls: 2,
+		},
+		{
+			name:      "Retries exhausted",
+			retries:   2,
+			statuses:  []int{http.StatusInternalServerError},
+			wantErr:   ErrExternal,
+			wantCalls: 3,
+		},
+		{
+			name:      "Not found is not retried",
+			retries:   2,
+			statuses:  []int{http.StatusNotFound},
+			wantErr:   ErrCityNotFound,
+			wantCalls: 1,
+		},
+		{
+			name:      "Invalid API key",
+			retries:   2,
+			statuses:  []int{http.StatusUnauthorized},
+			wantErr:   ErrUnauthorized,
+			wantCalls: 1,
+		},
+		{
+			name:      "No retries",
+			statuses:  []int{http.StatusTooManyRequests},
+			wantErr:   ErrRateLimited,
+			wantCalls: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, calls := testClient(t, tt.retries, 0, nil, tt.statuses...)
+
+			forecast, err := c.forecast(context.TODO(), "Berlin")
+			assert.Equal(t, tt.wantCalls, atomic.LoadInt32(calls))
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, 10.5, forecast.Main.Temp)
+			assert.Equal(t, "clear sky", forecast.Weather[0].Description)
+		})
+	}
+}
+
+func TestClient_forecast_apiError(t *testing.T) {
+	c, _ := testClient(t, 0, 0, nil, http.StatusUnauthorized)
+
+	_, err := c.forecast(context.TODO(), "Berlin")
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
+	assert.Equal(t, "Invalid API key.", apiErr.Message)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	assert.EqualError(t, err, "openweathermap: 401 Invalid API key.")
+}
+
+func TestClient_forecast_invalidResponse(t *testing.T) {
+	for _, body := range []string{`{"main":`, `{"main":{"temp":1}}`} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(body))
+		}))
+		c := newClient(Config{Timeout: time.Second})
+		c.api = srv.URL
+
+		_, err := c.forecast(context.TODO(), "Berlin")
+		assert.ErrorIs(t, err, ErrInvalidResponse, body)
+		srv.Close()
+	}
+}
+
+func TestClient_forecast_retryAfter(t *testing.T) {
+	t.Run("Honored", func(t *testing.T) {
+		c, calls := testClient(t, 1, 0, http.Header{"Retry-After": {"1"}},
+			http.StatusTooManyRequests, http.StatusOK)
+
+		start := time.Now()
+		_, err := c.forecast(context.TODO(), "Berlin")
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, time.Since(start), time.Second)
+		assert.Equal(t, int32(2), atomic.LoadInt32(calls))
+	})
+	t.Run("Too long", func(t *testing.T) {
+		c, calls := testClient(t, 1, 0, http.Header{"Retry-After": {"3600"}},
+			http.StatusTooManyRequests, http.StatusOK)
+
+		_, err := c.forecast(context.TODO(), "Berlin")
+		assert.ErrorIs(t, err, ErrRateLimited)
+		assert.Equal(t, int32(1), atomic.LoadInt32(calls))
+	})
+}
+
+func TestClient_forecast_rateLimit(t *testing.T) {
+	c, calls := testClient(t, 2, 0, nil, http.StatusInternalServerError)
+	c.limiter = newClient(Config{RateLimit: 10}).limiter // a call of burst
+
+	// The retries take the tokens too.
+	_, err := c.forecast(context.TODO(), "Berlin")
+	assert.ErrorIs(t, err, ErrRateLimited)
+	assert.Equal(t, int32(1), atomic.LoadInt32(calls))
+
+	_, err = c.forecast(context.TODO(), "Berlin")
+	assert.ErrorIs(t, err, ErrRateLimited)
+	assert.Equal(t, int32(1), atomic.LoadInt32(calls), "limited call is not made")
+}
+
+func TestClient_forecast_breaker(t *testing.T) {
+	c, calls := testClient(t, 0, 2, nil, http.StatusInternalServerError)
+
+	for i := 0; i < 2; i++ {
+		_, err := c.forecast(context.TODO(), "Berlin")
+		assert.ErrorIs(t, err, ErrExternal)
+	}
+	_, err := c.forecast(context.TODO(), "Berlin")
+	assert.ErrorIs(t, err, ErrUnavailable)
+	assert.Equal(t, int32(2), atomic.LoadInt32(calls), "open breaker fails fast")
+}
+
+func TestClient_forecast_breakerUnreachable(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	require.NoError(t, l.Close())
+
+	c := newClient(Config{Timeout: time.Second, BreakerThreshold: 2, BreakerTimeout: time.Hour})
+	c.api = "http://" + addr
+
+	// The connection is refused.
+	for i := 0; i < 2; i++ {
+		_, err := c.forecast(context.TODO(), "Berlin")
+		assert.ErrorIs(t, err, ErrCorruptedCall)
+	}
+	_, err = c.forecast(context.TODO(), "Berlin")
+	assert.ErrorIs(t, err, ErrUnavailable)
+}
+
+func TestClient_forecast_breakerKeepsLimit(t *testing.T) {
+	c, calls := testClient(t, 0, 1, nil, http.StatusInternalServerError)
+	c.limiter = newClient(Config{RateLimit: 30}).limiter // 3 calls of burst
+
+	_, err := c.forecast(context.TODO(), "Berlin")
+	assert.ErrorIs(t, err, ErrExternal)
+	for i := 0; i < 5; i++ {
+		_, err = c.forecast(context.TODO(), "Berlin")
+		assert.ErrorIs(t, err, ErrUnavailable)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(calls))
+
+	// The failed fast calls have not taken the limit.
+	assert.True(t, c.limiter.Allow())
+	assert.True(t, c.limiter.Allow())
+}
+
+func TestBreaker(t *testing.T) {
+	now := time.Now()
+	b := newBreaker(2, time.Minute)
+	b.now = func() time.Time { return now }
+
+	assert.True(t, b.allow())
+	b.record(false)
+	assert.True(t, b.allow())
+	b.record(true)
+	b.record(false)
+	assert.Equal(t, breakerClosed, b.current(), "success resets failures")
+
+	b.record(false)
+	assert.Equal(t, breakerOpen, b.current())
+	assert.False(t, b.allow())
+
+	now = now.Add(time.Minute)
+	assert.True(t, b.allow(), "probe passes after timeout")
+	assert.Equal(t, breakerHalfOpen, b.current())
+	assert.False(t, b.allow(), "only one probe passes")
+
+	b.record(false)
+	assert.Equal(t, breakerOpen, b.current(), "failed probe opens breaker")
+
+	now = now.Add(time.Minute)
+	assert.True(t, b.allow())
+	b.record(true)
+	assert.Equal(t, breakerClosed, b.current(), "successful probe closes breaker")
+	assert.True(t, b.allow())
+}
+
+func TestRetryAfter(t *testing.T) {
+	assert.Equal(t, time.Duration(0), retryAfter(http.Header{}))
+	assert.Equal(t, 5*time.Second, retryAfter(http.Header{"Retry-After": {"5"}}))
+	assert.Equal(t, time.Duration(0), retryAfter(http.Header{"Retry-After": {"soon"}}))
+
+	d := retryAfter(http.Header{"Retry-After": {time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)}})
+	assert.InDelta(t, time.Hour.Seconds(), d.Seconds(), 2)
+}
@@ -0,0 +1,143 @@
+package weather
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// dailyForecastDays is the max number of days of the daily forecast, the API forecasts 5 days ahead.
+const dailyForecastDays = 5
+
+// DailyForecast represents the city weather forecast of the next days summed up
+// from the 3 hour steps of https://openweathermap.org/forecast5#JSON.
+type DailyForecast struct {
+	Name     string
+	Country  string
+	Timezone int64 // shift from UTC, s
+	Days     []DayForecast
+}
+
+// DayForecast represents the weather forecast of a day in the city time zone.
+type DayForecast struct {
+	Date        time.Time // the day start in the city time zone
+	Description string    // the weather closest to the day noon
+	MinTemp     float64
+	MaxTemp     float64
+	Pop         float64 // max probability of precipitation, 0 to 1
+}
+
+// fiveDayForecast is the 5 day forecast of https://openweathermap.org/forecast5#JSON.
+type fiveDayForecast struct {
+	List []struct {
+		Dt   int64 // unix time of the forecasted data
+		Main struct {
+			TempMin float64 `json:"temp_min"`
+			TempMax float64 `json:"temp_max"`
+		}
+		Weather []Condition
+		Pop     float64
+	}
+	City struct {
+		Name     string
+		Country  string
+		Timezone int64 // shift from UTC, s
+	}
+}
+
+// daily returns the city forecast of the next days.
+func (c *client) daily(ctx context.Context, cityName string) (DailyForecast, error) {
+	q := url.Values{}
+	q.Set("units", "metric")
+	q.Set("q", cityName)
+
+	var resp fiveDayForecast
+	if err := c.get(ctx, forecastEndpoint, q, cityName, &resp); err != nil {
+		return DailyForecast{}, err
+	}
+	if len(resp.List) == 0 {
+		return DailyForecast{}, fmt.Errorf("%w: no forecast steps", ErrInvalidResponse)
+	}
+	return resp.days(), nil
+}
+
+// days sums up the forecast steps by the days in the city time zone.
+func (f fiveDayForecast) days() DailyForecast {
+	daily := DailyForecast{
+		Name:     f.City.Name,
+		Country:  f.City.Country,
+		Timezone: f.City.Timezone,
+	}
+	loc := daily.Location()
+
+	// The distance of the described step to the day noon.
+	var noonDist time.Duration
+	for _, step := range f.List {
+		at := time.Unix(step.Dt, 0).In(loc)
+		date := time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, loc)
+
+		n := len(daily.Days)
+		if n == 0 || !daily.Days[n-1].Date.Equal(date) {
+			if n == dailyForecastDays {
+				break
+			}
+			daily.Days = append(daily.Days, DayForecast{
+				Date:    date,
+				MinTemp: step.Main.TempMin,
+				MaxTemp: step.Main.TempMax,
+			})
+			noonDist = -1
+			n++
+		}
+
+		day := &daily.Days[n-1]
+		if step.Main.TempMin < day.MinTemp {
+			day.MinTemp = step.Main.TempMin
+		}
+		if step.Main.TempMax > day.MaxTemp {
+			day.MaxTemp = step.Main.TempMax
+		}
+		if step.Pop > day.Pop {
+			day.Pop = step.Pop
+		}
+		dist := at.Sub(date.Add(12 * time.Hour))
+		if dist < 0 {
+			dist = -dist
+		}
+		if len(step.Weather) != 0 && (noonDist < 0 || dist < noonDist) {
+			day.Description = step.Weather[0].Description
+			noonDist = dist
+		}
+	}
+	return daily
+}
+
+// Location returns the city time zone.
+func (f DailyForecast) Location() *time.Location {
+	return time.FixedZone("", int(f.Timezone))
+}
+
+// ToMsg converts the DailyForecast to the msg format of the telegram bot.
+func (f DailyForecast) ToMsg() string {
+	var sb strings.Builder
+
+	name := f.Name
+	if len(f.Country) != 0 {
+		name += ", " + f.Country
+	}
+	fmt.Fprintf(&sb, "%s\n\n", name)
+	for _, d := range f.Days {
+		fmt.Fprintf(&sb, "%s: %.0f..%.0f C", d.Date.Format("Mon 01-02"), d.MinTemp, d.MaxTemp)
+		if len(d.Description) != 0 {
+			fmt.Fprintf(&sb, ", %s", d.Description)
+		}
+		if d.Pop >= 0.1 {
+			fmt.Fprintf(&sb, ", precipitation %.0f%%", d.Pop*100)
+		}
+		sb.WriteString("\n")
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,62 @@
+package weather
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCityForecaster_Daily(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != forecastEndpoint || r.URL.Query().Get("q") != "Paris" {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"cod":"404","message":"city not found"}`))
+			return
+		}
+		// 2024-05-01 21:00 to 2024-05-02 21:00 UTC, the city is UTC+02:00.
+		_, _ = w.Write([]byte(`{"list":[` +
+			`{"dt":1714597200,"main":{"temp_min":14.1,"temp_max":15.2},"weather":[{"description":"clear sky"}],"pop":0},` +
+			`{"dt":1714618800,"main":{"temp_min":10.4,"temp_max":11.0},"weather":[{"description":"mist"}],"pop":0.05},` +
+			`{"dt":1714644000,"main":{"temp_min":17.3,"temp_max":18.6},"weather":[{"description":"light rain"}],"pop":0.8},` +
+			`{"dt":1714654800,"main":{"temp_min":19.0,"temp_max":20.4},"weather":[{"description":"few clouds"}],"pop":0.2},` +
+			`{"dt":1714683600,"main":{"temp_min":13.0,"temp_max":13.5},"weather":[{"description":"clear sky"}],"pop":0}],` +
+			`"city":{"name":"Paris","country":"FR","timezone":7200}}`))
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := newClient(Config{APIToken: "token", Timeout: time.Second})
+	c.api = srv.URL
+	f := CityForecaster{msgs: make(chan forecastRequest), client: c}
+	f.stopped = workers(ctx, c, 1, f.msgs)
+
+	daily, err := f.Daily(ctx, "Paris")
+	require.NoError(t, err)
+	require.Len(t, daily.Days, 2)
+	assert.Equal(t, "2024-05-01T00:00:00+02:00", daily.Days[0].Date.Format(time.RFC3339))
+	assert.Equal(t, DayForecast{
+		Date:        daily.Days[1].Date,
+		Description: "light rain",
+		MinTemp:     10.4,
+		MaxTemp:     20.4,
+		Pop:         0.8,
+	}, daily.Days[1])
+	assert.Equal(t, "Paris, FR\n\n"+
+		"Wed 05-01: 14..15 C, clear sky\n"+
+		"Thu 05-02: 10..20 C, light rain, precipitation 80%\n", daily.ToMsg())
+
+	_, err = f.Daily(ctx, "Atlantis")
+	assert.ErrorIs(t, err, ErrCityNotFound)
+
+	cancel()
+	<-f.stopped
+	_, err = f.Daily(context.Background(), "Paris")
+	assert.ErrorIs(t, err, ErrStopped)
+}
@@ -0,0 +1,76 @@
+package weather
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrStopped is returned when the forecaster is stopped.
+var ErrStopped = errors.New("forecaster stopped")
+
+// ErrRateLimited is returned when the API calls rate limit or the provider quota is exceeded.
+var ErrRateLimited = errors.New("rate limited")
+
+// ErrCallsLimited is the ErrRateLimited of the local API calls limit, see Config.RateLimit.
+// Unlike the provider quota, it is lifted as the limit refills.
+var ErrCallsLimited = fmt.Errorf("%w: calls limit exceeded", ErrRateLimited)
+
+// ErrUnavailable is returned while the provider is considered down, see Config.BreakerThreshold.
+var ErrUnavailable = errors.New("provider unavailable")
+
+// openweathermap request error classes. The errors of the provider responses are *APIError,
+// check the class with errors.Is.
+var (
+	ErrCityNotFound    = errors.New("city not found")
+	ErrUnauthorized    = errors.New("invalid api key")
+	ErrExternal        = errors.New("external error")
+	ErrCorruptedCall   = errors.New("corrupted call")
+	ErrInvalidResponse = errors.New("invalid response")
+)
+
+// APIError is the openweathermap error response.
+type APIError struct {
+	StatusCode int
+	// Message is the provider error message, e.g. "city not found".
+	Message string
+	// Err is the error class: ErrCityNotFound, ErrUnauthorized, ErrRateLimited or ErrExternal.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *APIError) Error() string {
+	if len(e.Message) == 0 {
+		return fmt.Sprintf("openweathermap: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("openweathermap: %d %s", e.StatusCode, e.Message)
+}
+
+// Unwrap returns the error class.
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// newAPIError returns the APIError of the response status code and body:
+// https://openweathermap.org/faq#api-errors.
+func newAPIError(statusCode int, body []byte) *APIError {
+	var resp struct {
+		Message string `json:"message"`
+	}
+	// The message is optional, the error class is defined by the status code.
+	_ = json.Unmarshal(body, &resp)
+
+	e := &APIError{StatusCode: statusCode, Message: resp.Message}
+	switch {
+	case statusCode == http.StatusNotFound:
+		e.Err = ErrCityNotFound
+	case statusCode == http.StatusUnauthorized:
+		e.Err = ErrUnauthorized
+	case statusCode == http.StatusTooManyRequests:
+		e.Err = ErrRateLimited
+	default:
+		e.Err = ErrExternal
+	}
+	return e
+}
@@ -0,0 +1,186 @@
+package weather
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alukart32/tmp-weather/internal/tmpweather/astro"
+	"github.com/rs/zerolog"
+)
+
+// Forecast represents the openweathermap weather forecast: https://openweathermap.org/current#current_JSON.
+type Forecast struct {
+	MadeAt time.Time
+	Coord  struct {
+		Lon float64
+		Lat float64
+	}
+	Weather []Condition
+	Main    struct {
+		Temp      float64
+		FeelsLike float64 `json:"feels_like"`
+		TempMin   float64 `json:"temp_min"`
+		TempMax   float64 `json:"temp_max"`
+		Pressure  int64   // sea level pressure, hPa
+		Humidity  int64
+		SeaLevel  int64 `json:"sea_level"`  // hPa
+		GrndLevel int64 `json:"grnd_level"` // hPa
+	}
+	Visibility int64 // m, max 10 km
+	Wind       struct {
+		Speed float64
+		Deg   int64 // meteorological direction
+		Gust  float64
+	}
+	Clouds struct {
+		All int64 // cloudiness, %
+	}
+	Rain Precipitation
+	Snow Precipitation
+	Dt   int64 // unix time of the data calculation
+	Sys  struct {
+		Country string
+		Sunrise int64 // unix time
+		Sunset  int64 // unix time
+	}
+	Timezone int64 // shift from UTC, s
+	ID       int64 // city ID
+	Name     string
+	Err      error
+}
+
+// Condition represents the weather condition: https://openweathermap.org/weather-conditions.
+type Condition struct {
+	ID          int64
+	Main        string // group of weather parameters, e.g. Rain
+	Description string
+	Icon        string
+}
+
+// Precipitation represents the precipitation volume, mm.
+type Precipitation struct {
+	OneHour    float64 `json:"1h"`
+	ThreeHours float64 `json:"3h"`
+}
+
+// Location returns the city time zone.
+func (f Forecast) Location() *time.Location {
+	return time.FixedZone("", int(f.Timezone))
+}
+
+// Sunrise returns the sunrise time in the city time zone, zero if it is unknown.
+func (f Forecast) Sunrise() time.Time {
+	if f.Sys.Sunrise == 0 {
+		return time.Time{}
+	}
+	return time.Unix(f.Sys.Sunrise, 0).In(f.Location())
+}
+
+// Sunset returns the sunset time in the city time zone, zero if it is unknown.
+func (f Forecast) Sunset() time.Time {
+	if f.Sys.Sunset == 0 {
+		return time.Time{}
+	}
+	return time.Unix(f.Sys.Sunset, 0).In(f.Location())
+}
+
+// ObservedAt returns the time of the weather data, MadeAt if it is unknown.
+func (f Forecast) ObservedAt() time.Time {
+	if f.Dt == 0 {
+		return f.MadeAt
+	}
+	return time.Unix(f.Dt, 0)
+}
+
+// Daytime returns "day" or "night" by the sun altitude at the city when the weather is observed,
+// empty if the time or the city coordinates are unknown.
+func (f Forecast) Daytime() string {
+	at := f.ObservedAt()
+	if at.IsZero() || (f.Coord.Lat == 0 && f.Coord.Lon == 0) {
+		return ""
+	}
+	if astro.IsDay(at, f.Coord.Lat, f.Coord.Lon) {
+		return "day"
+	}
+	return "night"
+}
+
+// windDirections are the compass points of the wind direction.
+var windDirections = [...]string{"N", "NE", "E", "SE", "S", "SW", "W", "NW"}
+
+// WindDirection returns the compass point the wind blows from, e.g. NW.
+func (f Forecast) WindDirection() string {
+	deg := f.Wind.Deg % 360
+	if deg < 0 {
+		deg += 360
+	}
+	return windDirections[(deg*2+45)/90%8]
+}
+
+// ToMsg converts the Forecast to the msg format of the telegram bot.
+func (f Forecast) ToMsg() string {
+	var sb strings.Builder
+
+	// https://openweathermap.org/weather-data
+	if len(f.Weather) != 0 {
+		sb.WriteString(f.Weather[0].Description)
+		if daytime := f.Daytime(); len(daytime) != 0 {
+			fmt.Fprintf(&sb, " (%s)", daytime)
+		}
+		sb.WriteString("\n\n")
+	}
+	fmt.Fprintf(&sb, "temp: %.2f C\n", f.Main.Temp)
+	fmt.Fprintf(&sb, "feels like: %.2f C\n\n", f.Main.FeelsLike)
+	fmt.Fprintf(&sb, "hum: %d %%\n", f.Main.Humidity)
+	if f.Main.Pressure != 0 {
+		fmt.Fprintf(&sb, "pressure: %d hPa\n", f.Main.Pressure)
+	}
+	fmt.Fprintf(&sb, "clouds: %d %%\n", f.Clouds.All)
+	if f.Visibility != 0 {
+		fmt.Fprintf(&sb, "visibility: %.1f km\n", float64(f.Visibility)/1000)
+	}
+	fmt.Fprintf(&sb, "wind: %.2f m/s", f.Wind.Speed)
+	if f.Wind.Speed != 0 {
+		fmt.Fprintf(&sb, ", %s", f.WindDirection())
+	}
+	if f.Wind.Gust != 0 {
+		fmt.Fprintf(&sb, ", gusts %.2f m/s", f.Wind.Gust)
+	}
+	sb.WriteString("\n")
+	if f.Rain.OneHour != 0 {
+		fmt.Fprintf(&sb, "rain: %.2f mm/h\n", f.Rain.OneHour)
+	}
+	if f.Snow.OneHour != 0 {
+		fmt.Fprintf(&sb, "snow: %.2f mm/h\n", f.Snow.OneHour)
+	}
+	if sunrise, sunset := f.Sunrise(), f.Sunset(); !sunrise.IsZero() && !sunset.IsZero() {
+		fmt.Fprintf(&sb, "\nsunrise: %s, sunset: %s\n", sunrise.Format("15:04"), sunset.Format("15:04"))
+	}
+
+	return sb.String()
+}
+
+// MarshalZerologObject adds Forecast to the logger as an object.
+func (f Forecast) MarshalZerologObject(e *zerolog.Event) {
+	e.Time("madeAt", f.MadeAt).
+		Str("city", f.Name).
+		Str("country", f.Sys.Country).
+		Float64("lat", f.Coord.Lat).
+		Float64("lon", f.Coord.Lon)
+	if len(f.Weather) != 0 {
+		e.Int64("conditionID", f.Weather[0].ID).
+			Str("description", f.Weather[0].Description)
+	}
+	e.Float64("temp", f.Main.Temp).
+		Float64("feelsLike", f.Main.FeelsLike).
+		Int64("hum", f.Main.Humidity).
+		Int64("pressure", f.Main.Pressure).
+		Int64("visibility", f.Visibility).
+		Int64("clouds", f.Clouds.All).
+		Float64("wind", f.Wind.Speed).
+		Int64("windDeg", f.Wind.Deg).
+		Float64("windGust", f.Wind.Gust).
+		Float64("rain", f.Rain.OneHour).
+		Float64("snow", f.Snow.OneHour)
+}
@@ -0,0 +1,106 @@
+package weather
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// owmPayload is the example response of https://openweathermap.org/current#current_JSON.
+const owmPayload = `{
+  "coord": {"lon": 10.99, "lat": 44.34},
+  "weather": [{"id": 501, "main": "Rain", "description": "moderate rain", "icon": "10d"}],
+  "base": "stations",
+  "main": {
+    "temp": 298.48, "feels_like": 298.74, "temp_min": 297.56, "temp_max": 300.05,
+    "pressure": 1015, "humidity": 64, "sea_level": 1015, "grnd_level": 933
+  },
+  "visibility": 10000,
+  "wind": {"speed": 0.62, "deg": 349, "gust": 1.18},
+  "rain": {"1h": 3.16},
+  "clouds": {"all": 100},
+  "dt": 1661870592,
+  "sys": {"type": 2, "id": 2075663, "country": "IT", "sunrise": 1661834187, "sunset": 1661882248},
+  "timezone": 7200,
+  "id": 3163858,
+  "name": "Zocca",
+  "cod": 200
+}`
+
+func TestForecast_decode(t *testing.T) {
+	var f Forecast
+	require.NoError(t, json.Unmarshal([]byte(owmPayload), &f))
+
+	assert.Equal(t, 44.34, f.Coord.Lat)
+	assert.Equal(t, []Condition{{ID: 501, Main: "Rain", Description: "moderate rain", Icon: "10d"}}, f.Weather)
+	assert.Equal(t, 298.74, f.Main.FeelsLike)
+	assert.Equal(t, int64(1015), f.Main.Pressure)
+	assert.Equal(t, int64(933), f.Main.GrndLevel)
+	assert.Equal(t, int64(10000), f.Visibility)
+	assert.Equal(t, int64(349), f.Wind.Deg)
+	assert.Equal(t, 1.18, f.Wind.Gust)
+	assert.Equal(t, 3.16, f.Rain.OneHour)
+	assert.Zero(t, f.Snow.OneHour)
+	assert.Equal(t, int64(100), f.Clouds.All)
+	assert.Equal(t, "IT", f.Sys.Country)
+	assert.Equal(t, "Zocca", f.Name)
+
+	assert.Equal(t, "06:36", f.Sunrise().Format("15:04"))
+	assert.Equal(t, "19:57", f.Sunset().Format("15:04"))
+
+	assert.Equal(t, "day", f.Daytime())
+	assert.Equal(t, `moderate rain (day)
+
+temp: 298.48 C
+feels like: 298.74 C
+
+hum: 64 %
+pressure: 1015 hPa
+clouds: 100 %
+visibility: 10.0 km
+wind: 0.62 m/s, N, gusts 1.18 m/s
+rain: 3.16 mm/h
+
+sunrise: 06:36, sunset: 19:57
+`, f.ToMsg())
+}
+
+func TestForecast_WindDirection(t *testing.T) {
+	tests := []struct {
+		deg  int64
+		want string
+	}{
+		{deg: 0, want: "N"},
+		{deg: 22, want: "N"},
+		{deg: 23, want: "NE"},
+		{deg: 90, want: "E"},
+		{deg: 200, want: "S"},
+		{deg: 250, want: "W"},
+		{deg: 337, want: "NW"},
+		{deg: 338, want: "N"},
+		{deg: 360, want: "N"},
+	}
+
+	for _, tt := range tests {
+		var f Forecast
+		f.Wind.Deg = tt.deg
+		assert.Equal(t, tt.want, f.WindDirection(), tt.deg)
+	}
+}
+
+func TestForecast_Daytime(t *testing.T) {
+	var f Forecast
+	assert.Empty(t, f.Daytime(), "unknown coordinates")
+
+	f.Coord.Lat, f.Coord.Lon = 44.34, 10.99
+	assert.Empty(t, f.Daytime(), "unknown time")
+
+	f.MadeAt = time.Date(2022, 8, 30, 12, 0, 0, 0, time.UTC)
+	assert.Equal(t, "day", f.Daytime())
+
+	f.Dt = time.Date(2022, 8, 30, 22, 0, 0, 0, time.UTC).Unix()
+	assert.Equal(t, "night", f.Daytime(), "observed time first")
+}
@@ -1,199 +1,168 @@
 // Package weather provides a weather forecaster.
 //
 // The weather forecaster executes a request, which uses the name of the city
-// to get the current weather: https://openweathermap.org/current#name.
+// to get the current weather: https://openweathermap.org/current#name,
+// or the 5 day forecast: https://openweathermap.org/forecast5#name.
 package weather
 
 import (
 	"context"
-	"encoding/json"
-	"errors"
-	"fmt"
-	"io"
-	"net/http"
-	"os"
-	"strings"
+	"sync"
 	"time"
 
+	"github.com/alukart32/tmp-weather/internal/pkg/otelx"
 	"github.com/alukart32/tmp-weather/internal/pkg/zerologx"
-	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
 )
 
+var tracer = otelx.Tracer("github.com/alukart32/tmp-weather/internal/tmpweather/weather")
+
+// Config is the representation of openweathermap client settings.
+type Config struct {
+	APIToken string        `yaml:"api_token" toml:"api_token" env:"OPENWEATHERMAP_API_TOKEN"`
+	Timeout  time.Duration `yaml:"timeout" toml:"timeout" env:"OPENWEATHERMAP_TIMEOUT"`
+	// Workers is the number of concurrent API calls.
+	Workers int `yaml:"workers" toml:"workers" env:"OPENWEATHERMAP_WORKERS"`
+	// RateLimit is the max number of calls per minute of the API plan, 0 means no limit.
+	RateLimit int `yaml:"rate_limit" toml:"rate_limit" env:"OPENWEATHERMAP_RATE_LIMIT"`
+	// Retries is the number of retries of a call with a timeout, 429 or 5xx response.
+	Retries int `yaml:"retries" toml:"retries" env:"OPENWEATHERMAP_RETRIES"`
+	// RetryDelay is the backoff delay of the first retry, it doubles on each retry.
+	RetryDelay time.Duration `yaml:"retry_delay" toml:"retry_delay" env:"OPENWEATHERMAP_RETRY_DELAY"`
+	// BreakerThreshold is the number of failed calls in a row that open the circuit breaker, 0 disables it.
+	BreakerThreshold int `yaml:"breaker_threshold" toml:"breaker_threshold" env:"OPENWEATHERMAP_BREAKER_THRESHOLD"`
+	// BreakerTimeout is how long the open circuit breaker fails the calls fast before a probe call.
+	BreakerTimeout time.Duration `yaml:"breaker_timeout" toml:"breaker_timeout" env:"OPENWEATHERMAP_BREAKER_TIMEOUT"`
+}
+
 // CityForecaster defines a weather forecaster by city name.
 type CityForecaster struct {
-	msgs chan string         // incoming city names
-	res  chan forecastResult // forecast data
+	msgs    chan forecastRequest // incoming API call requests
+	stopped chan struct{}        // closed when the workers stop
+	client  *client
 }
 
-// NewCityForecaster returns a new CityForecaster.
-func NewCityForecaster(ctx context.Context) CityForecaster {
+// NewCityForecaster returns a new CityForecaster. The forecaster stops when ctx is done.
+func NewCityForecaster(ctx context.Context, cfg Config) CityForecaster {
 	forecaster := CityForecaster{
-		msgs: make(chan string),
+		msgs:   make(chan forecastRequest),
+		client: newClient(cfg),
 	}
-
-	forecaster.res = worker(ctx, forecaster.msgs)
+	forecaster.stopped = workers(ctx, forecaster.client, cfg.Workers, forecaster.msgs)
 	return forecaster
 }
 
+// Air returns the air quality forecaster sharing the forecaster workers and API calls limit.
+// It stops with the forecaster.
+func (f *CityForecaster) Air() AirForecaster {
+	return AirForecaster{forecaster: *f}
+}
+
 // Forecast accepts the city name and returns the weather forecast.
-func (f *CityForecaster) Forecast(ctx context.Context, cityName string) (Forecast, error) {
-	select {
-	case <-ctx.Done():
-	case f.msgs <- cityName:
+// It is safe to call concurrently, up to Config.Workers calls are made at once.
+func (f *CityForecaster) Forecast(ctx context.Context, cityName string) (forecast Forecast, err error) {
+	ctx, span := tracer.Start(ctx, "CityForecaster.Forecast")
+	span.SetAttributes(attribute.String("city", cityName))
+	defer func() { otelx.End(span, err) }()
+
+	var res Forecast
+	err = f.do(ctx, func(ctx context.Context, c *client) (err error) {
+		res, err = c.forecast(ctx, cityName)
+		return err
+	})
+	if err != nil {
+		return Forecast{}, err
 	}
+	return res, nil
+}
 
-	res := <-f.res
-	return res.Forecast, res.Err
+// Daily accepts the city name and returns the weather forecast of the next days.
+// It is safe to call concurrently, the call shares the Forecast workers.
+func (f *CityForecaster) Daily(ctx context.Context, cityName string) (forecast DailyForecast, err error) {
+	ctx, span := tracer.Start(ctx, "CityForecaster.Daily")
+	span.SetAttributes(attribute.String("city", cityName))
+	defer func() { otelx.End(span, err) }()
+
+	var res DailyForecast
+	err = f.do(ctx, func(ctx context.Context, c *client) (err error) {
+		res, err = c.daily(ctx, cityName)
+		return err
+	})
+	if err != nil {
+		return DailyForecast{}, err
+	}
+	return res, nil
 }
 
-// openweathermap request errors.
-var (
-	ErrCityNotFound  = errors.New("city not found")
-	ErrExternal      = errors.New("external error")
-	ErrCorruptedCall = errors.New("corrupted call")
-)
+// do makes the API call on a worker and returns its error. The call results are read
+// by the caller only if the call is done, the worker does not block if the caller has gone.
+func (f *CityForecaster) do(ctx context.Context, call func(ctx context.Context, c *client) error) error {
+	res := make(chan error, 1)
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-f.stopped:
+		return ErrStopped
+	case f.msgs <- forecastRequest{ctx: ctx, call: call, res: res}:
+	}
 
-// forecastResult represents the respond forecast.
-type forecastResult struct {
-	Forecast
-	Err error
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case err := <-res:
+		return err
+	}
 }
 
-// worker sends forecast requests to openweathermap and returns a response.
-func worker(ctx context.Context, in chan string) chan forecastResult {
-	out := make(chan forecastResult)
+// forecastRequest represents the API call request of the worker.
+type forecastRequest struct {
+	ctx  context.Context
+	call func(ctx context.Context, c *client) error
+	res  chan<- error
+}
 
-	go func() {
-		defer close(out)
+// workers start n workers that make the requested openweathermap calls and respond to the request result channels.
+// The returned channel is closed when all workers stop.
+func workers(ctx context.Context, client *client, n int, in chan forecastRequest) chan struct{} {
+	stopped := make(chan struct{})
 
+	if len(client.apiToken) == 0 {
 		logger := zerologx.Get()
-
-		apiToken := os.Getenv("OPENWEATHERMAP_API_TOKEN")
-		if len(apiToken) == 0 {
-			logger.Error().Msg("invalid openweathermap api key")
-			return
-		}
-		api := "https://api.openweathermap.org/data/2.5/weather"
-
-		client := &http.Client{
-			Timeout: time.Second * 1,
-			Transport: &http.Transport{
-				MaxIdleConns: 15,
-			},
-		}
-
-		for {
-			select {
-			case <-ctx.Done():
-			case cityName, ok := <-in:
-				if !ok {
-					return
-				}
-
-				url := fmt.Sprintf("%s?units=metric&q=%s&appid=%s", api, cityName, apiToken)
-				req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-				if err != nil {
-					out <- forecastResult{Forecast: Forecast{}, Err: err}
-				}
-
-				logger.Info().
-					Str("op", "get forecast").
-					Str("city", cityName).Send()
-				resp, err := client.Do(req)
-				logger.Info().
-					Str("op", "forecast respond").
-					Str("city", cityName).
-					Int("respCode", resp.StatusCode).Send()
-				if err != nil {
-					out <- forecastResult{
-						Forecast: Forecast{},
-						Err:      ErrCorruptedCall,
-					}
-					break
-				}
-				defer resp.Body.Close()
-
-				switch resp.StatusCode {
-				case http.StatusNotFound:
-					err = ErrCityNotFound
-				case http.StatusBadRequest, http.StatusBadGateway:
-					err = ErrExternal
-				default:
-				}
-				if err != nil {
-					out <- forecastResult{
-						Forecast: Forecast{},
-						Err:      err,
-					}
-					break
-				}
-
-				body, err := io.ReadAll(resp.Body)
-				if err != nil {
-					out <- forecastResult{
-						Forecast: Forecast{},
-						Err:      fmt.Errorf("read response body: %v", err),
-					}
-					break
-				}
-
-				var forecast Forecast
-				if err = json.Unmarshal(body, &forecast); err != nil {
-					out <- forecastResult{
-						Forecast: Forecast{},
-						Err:      fmt.Errorf("unmarshal response body: %v", err),
-					}
-				}
-				forecast.MadeAt = time.Now()
-
-				out <- forecastResult{
-					Forecast: forecast,
-				}
-			}
-		}
-	}()
-
-	return out
-}
-
-// Forecast represents the openweathermap weather forecast: https://openweathermap.org/current#current_JSON.
-type Forecast struct {
-	MadeAt time.Time
-	Main   struct {
-		Temp      float64
-		FeelsLike float64 `json:"feels_like"`
-		Humidity  int64
+		logger.Error().Msg("invalid openweathermap api key")
+		close(stopped)
+		return stopped
 	}
-	Weather []struct {
-		Description string
+	if n < 1 {
+		n = 1
 	}
-	Wind struct {
-		Speed float64
-	}
-	Err error
-}
-
-// ToMsg converts the Forecast to the msg format of the telegram bot.
-func (f Forecast) ToMsg() string {
-	var sb strings.Builder
 
-	// https://openweathermap.org/weather-data
-	fmt.Fprintf(&sb, "%v\n\n", f.Weather[0].Description)
-	fmt.Fprintf(&sb, "temp: %.2f C\n", f.Main.Temp)
-	fmt.Fprintf(&sb, "feels like: %.2f C\n\n", f.Main.FeelsLike)
-	fmt.Fprintf(&sb, "hum: %d %%\n", f.Main.Humidity)
-	fmt.Fprintf(&sb, "wind: %.2f m/s\n", f.Wind.Speed)
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			worker(ctx, client, in)
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(stopped)
+	}()
 
-	return sb.String()
+	return stopped
 }
 
-// MarshalZerologObject adds Forecast to the logger as an object.
-func (f Forecast) MarshalZerologObject(e *zerolog.Event) {
-	e.
-		Time("madeAt", f.MadeAt).
-		Str("description", f.Weather[0].Description).
-		Float64("temp", f.Main.Temp).
-		Float64("feelsLike", f.Main.FeelsLike).
-		Int64("hum", f.Main.Humidity).
-		Float64("wind", f.Wind.Speed)
+// worker makes the requested openweathermap calls until ctx is done.
+func worker(ctx context.Context, client *client, in chan forecastRequest) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case r, ok := <-in:
+			if !ok {
+				return
+			}
+			r.res <- r.call(r.ctx, client)
+		}
+	}
 }
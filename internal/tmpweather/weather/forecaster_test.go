@@ -0,0 +1,66 @@
+package weather
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCityForecaster_Forecast_concurrent(t *testing.T) {
+	const n = 3
+	// The server responds only when all workers are calling it at once.
+	var arrived sync.WaitGroup
+	arrived.Add(n)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		arrived.Done()
+		arrived.Wait()
+		city := r.URL.Query().Get("q")
+		_, _ = fmt.Fprintf(w, `{"name":%q,"main":{"temp":%d},"weather":[{"description":"clear sky"}]}`, city, len(city))
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := newClient(Config{APIToken: "token", Timeout: time.Second})
+	c.api = srv.URL
+	f := CityForecaster{msgs: make(chan forecastRequest), client: c}
+	f.stopped = workers(ctx, c, n, f.msgs)
+
+	cities := []string{"Rome", "Berlin", "London"}
+	forecasts := make([]Forecast, len(cities))
+	errs := make([]error, len(cities))
+	var wg sync.WaitGroup
+	for i, city := range cities {
+		wg.Add(1)
+		go func(i int, city string) {
+			defer wg.Done()
+			forecasts[i], errs[i] = f.Forecast(ctx, city)
+		}(i, city)
+	}
+	wg.Wait()
+
+	for i, city := range cities {
+		require.NoError(t, errs[i], city)
+		assert.Equal(t, city, forecasts[i].Name)
+		assert.Equal(t, float64(len(city)), forecasts[i].Main.Temp)
+	}
+
+	cancel()
+	<-f.stopped
+	_, err := f.Forecast(context.Background(), "Rome")
+	assert.ErrorIs(t, err, ErrStopped)
+}
+
+func TestCityForecaster_Forecast_noToken(t *testing.T) {
+	f := NewCityForecaster(context.Background(), Config{Timeout: time.Second})
+	_, err := f.Forecast(context.Background(), "Rome")
+	assert.ErrorIs(t, err, ErrStopped)
+}